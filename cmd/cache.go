@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"gin-admin/internal/config"
+	"gin-admin/pkg/cachex"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+func CacheCmd() *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "workdir",
+			Aliases:     []string{"d"},
+			Usage:       "Working directory",
+			DefaultText: "configs",
+			Value:       "configs",
+		},
+		&cli.StringFlag{
+			Name:        "config",
+			Aliases:     []string{"c"},
+			Usage:       "Runtime configuration files or directory (relative to workdir, multiple separated by commas)",
+			DefaultText: "dev",
+			Value:       "dev",
+		},
+		&cli.StringFlag{
+			Name:     "file",
+			Aliases:  []string{"f"},
+			Usage:    "Backup file",
+			Required: true,
+		},
+	}
+
+	return &cli.Command{
+		Name:  "cache",
+		Usage: "Maintain the badger cache",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "backup",
+				Usage: "Write a snapshot of the badger cache to a file",
+				Flags: flags,
+				Action: func(c *cli.Context) error {
+					return withBadgerCache(c, func(ctx context.Context, b cachex.Backuper) error {
+						file, err := os.Create(c.String("file"))
+						if err != nil {
+							return err
+						}
+						defer file.Close()
+
+						if err := b.Backup(ctx, file); err != nil {
+							return err
+						}
+						fmt.Printf("cache backed up to %s \n", c.String("file"))
+						return file.Sync()
+					})
+				},
+			},
+			{
+				Name:  "restore",
+				Usage: "Replace the content of the badger cache with a backup file",
+				Flags: flags,
+				Action: func(c *cli.Context) error {
+					return withBadgerCache(c, func(ctx context.Context, b cachex.Backuper) error {
+						file, err := os.Open(c.String("file"))
+						if err != nil {
+							return err
+						}
+						defer file.Close()
+
+						if err := b.Restore(ctx, file); err != nil {
+							return err
+						}
+						fmt.Printf("cache restored from %s \n", c.String("file"))
+						return nil
+					})
+				},
+			},
+		},
+	}
+}
+
+// withBadgerCache opens the configured badger cache. Badger locks its directory, so the server
+// using the same path has to be stopped first. A running server backs up and restores the namespaces
+// browsable by administrators through the /api/v1/caches/backup and /api/v1/caches/restore endpoints,
+// the commands cover the whole cache.
+func withBadgerCache(c *cli.Context, fn func(ctx context.Context, b cachex.Backuper) error) error {
+	if err := config.Load(c.String("workdir"), strings.Split(c.String("config"), ",")...); err != nil {
+		return err
+	}
+
+	cfg := config.C.Storage.Cache
+	if cfg.Type != "badger" {
+		return fmt.Errorf("cache type is %q, backup and restore only support badger", cfg.Type)
+	}
+
+	ctx := context.Background()
+	cache := cachex.NewBadgerCache(cachex.BadgerConfig{Path: cfg.Badger.Path})
+	defer cache.Close(ctx)
+
+	return fn(ctx, cache.(cachex.Backuper))
+}
@@ -35,7 +35,7 @@ func StartCmd() *cli.Command {
 			&cli.StringFlag{
 				Name:    "static",
 				Aliases: []string{"s"},
-				Usage:   "Static files directory",
+				Usage:   "Static files directory, overrides the UI embedded from web/dist",
 			},
 			&cli.BoolFlag{
 				Name:  "daemon",
@@ -34,6 +34,9 @@ type General struct {
 		IdleTimeout     int
 		CertFile        string
 		KeyFile         string
+		TrustedProxies  []string // Only these proxies may set the client IP with RemoteIPHeaders
+		RemoteIPHeaders []string
+		TrustedPlatform string // e.g. CF-Connecting-IP behind Cloudflare
 	}
 	Root struct {
 		ID       string
@@ -43,6 +46,46 @@ type General struct {
 	}
 }
 
+// RedisConfig is the connection config shared by every Redis user, see redisx.Config.
+type RedisConfig struct {
+	Mode             string   // single/sentinel/cluster
+	Addr             string   // single mode
+	Addrs            []string // Sentinel addresses in sentinel mode, seed nodes in cluster mode
+	MasterName       string   // sentinel mode
+	SentinelUsername string
+	SentinelPassword string
+	Username         string
+	Password         string
+	DB               int
+	TLS              struct {
+		Enable             bool
+		ServerName         string
+		CAFile             string
+		CertFile           string
+		KeyFile            string
+		InsecureSkipVerify bool
+	}
+	PoolSize        int
+	MinIdleConns    int
+	MaxIdleConns    int
+	PoolTimeout     int // seconds
+	ConnMaxIdleTime int // seconds
+	ConnMaxLifetime int // seconds
+	DialTimeout     int // milliseconds
+	ReadTimeout     int // milliseconds
+	WriteTimeout    int // milliseconds
+}
+
+// inherit copies the connection from src when no address is configured, keeping the DB.
+func (c *RedisConfig) inherit(src RedisConfig) {
+	if c.Addr != "" || len(c.Addrs) > 0 {
+		return
+	}
+	db := c.DB
+	*c = src
+	c.DB = db
+}
+
 type Storage struct {
 	Cache struct {
 		Type      string
@@ -51,14 +94,11 @@ type Storage struct {
 			CleanupInterval int
 		}
 		Badger struct {
-			Path string
-		}
-		Redis struct {
-			Addr     string
-			Username string
-			Password string
-			DB       int
+			Path           string
+			GCInterval     int     // seconds, value log GC interval, 0 to disable
+			GCDiscardRatio float64 // Rewrite a value log file when this fraction of it is garbage
 		}
+		Redis RedisConfig
 	}
 	DB struct {
 		Debug        bool
@@ -77,19 +117,46 @@ type Storage struct {
 			Tables   []string
 		}
 	}
+	OSS struct {
+		Type  string // local/minio/s3
+		Local struct {
+			Root       string
+			Domain     string
+			BucketName string
+			Prefix     string
+			SignSecret string
+		}
+		Minio struct {
+			Domain          string
+			Endpoint        string
+			AccessKeyID     string
+			SecretAccessKey string
+			BucketName      string
+			Prefix          string
+		}
+		S3 struct {
+			Domain          string
+			Region          string
+			AccessKeyID     string
+			SecretAccessKey string
+			BucketName      string
+			Prefix          string
+		}
+	}
 }
 
 type Util struct {
 	Captcha struct {
-		Length    int
-		Width     int
-		Height    int
-		CacheType string
-		Redis     struct {
-			Addr      string
-			Username  string
-			Password  string
-			DB        int
+		Type          string // digit/math
+		Length        int
+		Width         int
+		Height        int
+		Expiration    int // seconds
+		FailThreshold int // Require a captcha only after this many failed logins from an IP, 0 to always require it
+		FailWindow    int // seconds, how long failed logins are counted, 15 minutes by default
+		CacheType     string
+		Redis         struct {
+			RedisConfig
 			KeyPrefix string
 		}
 	}
@@ -102,6 +169,56 @@ type Util struct {
 		LogMethods     []string
 		DefaultCollect bool
 	}
+	Parameter struct {
+		CacheExp         int
+		AutoLoadInterval int
+	}
+	Notification struct {
+		BufferSize        int
+		HeartbeatInterval int
+		MailFallback      bool
+		Channel           string // Redis pub/sub channel to relay events between instances
+		ScheduleInterval  int    // seconds, how often announcements whose window opened are pushed
+	}
+	Mail struct {
+		Enable        bool
+		SmtpHost      string
+		Port          int
+		FromName      string
+		FromMail      string
+		UserName      string
+		AuthCode      string
+		TemplateDir   string // Relative to the work dir
+		Workers       int
+		MaxAttempts   int
+		RetryInterval int // seconds, doubled after every failed attempt
+		PollInterval  int // seconds
+	}
+	Image struct {
+		Enable         bool     // Normalize stored images and generate thumbnails
+		Format         string   // Convert stored images to jpeg/png/gif, empty keeps the format
+		Quality        int      // JPEG quality
+		ThumbnailSizes []string // e.g. ["64x64", "320x0"]
+		ThumbnailMode  string   // fit/fill
+		MaxDimension   int      // Max width/height of on-the-fly resized images
+		MaxPixels      int      // Images with more pixels are stored as they are and not resized
+		VariantSizes   []int    // Requested widths/heights are rounded up to one of these sizes
+	}
+	Job struct {
+		Enable         bool // Run scheduled jobs on this instance, manual runs are always possible
+		SyncInterval   int  // seconds, interval to pick up job changes and to detect stopped instances
+		DefaultTimeout int  // seconds
+		MaxOutputSize  int  // KB, output kept per execution
+	}
+	File struct {
+		MaxSize        int64 // MB
+		PresignExpires int   // seconds
+		Upload         struct {
+			Dir             string
+			Expiration      int // seconds
+			CleanupInterval int // seconds
+		}
+	}
 }
 
 type Dictionary struct {
@@ -121,30 +238,20 @@ func (c *Config) String() string {
 	return string(b)
 }
 
+// PreLoad lets the captcha, rate limiter and token stores use the cache's Redis connection
+// unless they configure their own, so that they can share one client.
 func (c *Config) PreLoad() {
-	if addr := c.Storage.Cache.Redis.Addr; addr != "" {
-		username := c.Storage.Cache.Redis.Username
-		password := c.Storage.Cache.Redis.Password
-
-		if c.Util.Captcha.CacheType == "redis" &&
-			c.Util.Captcha.Redis.Addr == "" {
-			c.Util.Captcha.Redis.Addr = addr
-			c.Util.Captcha.Redis.Username = username
-			c.Util.Captcha.Redis.Password = password
+	if src := c.Storage.Cache.Redis; src.Addr != "" || len(src.Addrs) > 0 {
+		if c.Util.Captcha.CacheType == "redis" {
+			c.Util.Captcha.Redis.inherit(src)
 		}
 
-		if c.Middleware.RateLimiter.Store.Type == "redis" &&
-			c.Middleware.RateLimiter.Store.Redis.Addr == "" {
-			c.Middleware.RateLimiter.Store.Redis.Addr = addr
-			c.Middleware.RateLimiter.Store.Redis.Username = username
-			c.Middleware.RateLimiter.Store.Redis.Password = password
+		if c.Middleware.RateLimiter.Store.Type == "redis" {
+			c.Middleware.RateLimiter.Store.Redis.inherit(src)
 		}
 
-		if c.Middleware.Auth.Store.Type == "redis" &&
-			c.Middleware.Auth.Store.Redis.Addr == "" {
-			c.Middleware.Auth.Store.Redis.Addr = addr
-			c.Middleware.Auth.Store.Redis.Username = username
-			c.Middleware.Auth.Store.Redis.Password = password
+		if c.Middleware.Auth.Store.Type == "redis" {
+			c.Middleware.Auth.Store.Redis.inherit(src)
 		}
 	}
 }
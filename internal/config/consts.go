@@ -1,12 +1,38 @@
 package config
 
 const (
-	CacheNSForUser = "user"
-	CacheNSForRole = "role"
+	CacheNSForSync        = "sync" // Timestamps of the last changes, to let other instances reload
+	CacheNSForUser        = "user"
+	CacheNSForRole        = "role"
+	CacheNSForParameter   = "parameter"
+	CacheNSForFile        = "file"
+	CacheNSForDevice      = "device"
+	CacheNSForLoginFail   = "login-fail"
+	CacheNSForIdempotency = "idempotency"
+	CacheNSForAppKey      = "app-key"
+	CacheNSForSignNonce   = "sign-nonce"
+	CacheNSForOnline      = "online"
+	CacheNSForJob         = "job" // Liveness of instances and replace markers of running jobs
 )
 
+// CacheNamespaces lists the namespaces that can be browsed and cleared by administrators. The app key
+// secrets and the sign nonces are left out, browsing would expose the secrets and clearing would
+// allow replays.
+var CacheNamespaces = []string{
+	CacheNSForUser,
+	CacheNSForRole,
+	CacheNSForParameter,
+	CacheNSForFile,
+	CacheNSForDevice,
+	CacheNSForLoginFail,
+	CacheNSForIdempotency,
+}
+
 const (
-	CacheKeyForSyncToCasbin = "sync:casbin"
+	CacheKeyForSyncToCasbin    = "sync:casbin"
+	CacheKeyForSyncToParameter = "sync:parameter"
+	CacheKeyForSyncToRateLimit = "sync:rate-limit"
+	CacheKeyForSyncToIPRule    = "sync:ip-rule"
 )
 
 const (
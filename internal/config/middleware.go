@@ -48,32 +48,39 @@ type Middleware struct {
 			Badger struct {
 				Path string
 			}
-			Redis struct {
-				Addr     string
-				Username string
-				Password string
-				DB       int
-			}
+			Redis RedisConfig
+		}
+		Signature struct { // HMAC signed requests made with app keys, see pkg/signx
+			Enable    bool
+			ClockSkew int // seconds
 		}
 	}
+	Idempotency struct {
+		Enable              bool
+		SkippedPathPrefixes []string
+		Expiration          int // seconds, how long a response is replayed
+		LockExpiration      int // seconds, how long a request may stay in flight
+	}
+	IPFilter struct {
+		Enable              bool
+		SkippedPathPrefixes []string
+		AutoLoadInterval    int // seconds, how often to check for rule changes made on other instances
+	}
 	RateLimiter struct {
 		Enable              bool
 		SkippedPathPrefixes []string
+		Algorithm           string // fixed_window/sliding_window/token_bucket
 		Period              int
 		MaxRequestPerIP     int
 		MaxRequestPerUser   int
+		AutoLoadInterval    int // seconds, how often to check for role changes and policy changes made on other instances
 		Store               struct {
 			Type   string
 			Memory struct {
 				Expiration      int
 				CleanupInterval int
 			}
-			Redis struct {
-				Addr     string
-				Username string
-				Password string
-				DB       int
-			}
+			Redis RedisConfig
 		}
 	}
 	Casbin struct {
@@ -85,7 +92,20 @@ type Middleware struct {
 		GenPolicyFile       string
 	}
 
+	Compress struct {
+		Enable              bool
+		SkippedPathPrefixes []string
+		ContentTypes        []string
+		MinLength           int
+		Brotli              bool
+	}
+	ETag struct {
+		Enable              bool
+		SkippedPathPrefixes []string
+	}
 	Static struct {
-		Dir string
+		Dir             string
+		APIPathPrefixes []string
+		MaxAge          int // seconds
 	}
 }
@@ -0,0 +1,43 @@
+package config
+
+import "sync/atomic"
+
+// Runtime holds the config values that system parameters can override while the server runs. A snapshot
+// is never modified once published, readers call R() for every use instead of keeping it.
+type Runtime struct {
+	DefaultLoginPwd      string
+	CaptchaType          string
+	CaptchaLength        int
+	CaptchaWidth         int
+	CaptchaHeight        int
+	CaptchaFailThreshold int
+	UserCacheExp         int // hours
+}
+
+var runtimeSnapshot atomic.Pointer[Runtime]
+
+// DefaultRuntime returns the values from the loaded config files, without overrides.
+func DefaultRuntime() *Runtime {
+	return &Runtime{
+		DefaultLoginPwd:      C.General.DefaultLoginPwd,
+		CaptchaType:          C.Util.Captcha.Type,
+		CaptchaLength:        C.Util.Captcha.Length,
+		CaptchaWidth:         C.Util.Captcha.Width,
+		CaptchaHeight:        C.Util.Captcha.Height,
+		CaptchaFailThreshold: C.Util.Captcha.FailThreshold,
+		UserCacheExp:         C.Dictionary.UserCacheExp,
+	}
+}
+
+// R returns the current runtime values.
+func R() *Runtime {
+	if r := runtimeSnapshot.Load(); r != nil {
+		return r
+	}
+	return DefaultRuntime()
+}
+
+// SetRuntime publishes a new snapshot, r must not be modified afterwards.
+func SetRuntime(r *Runtime) {
+	runtimeSnapshot.Store(r)
+}
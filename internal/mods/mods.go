@@ -2,6 +2,12 @@ package mods
 
 import (
 	"context"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/middleware"
+	"gin-admin/web"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
@@ -16,13 +22,18 @@ var Set = wire.NewSet{
 }
 
 type Mods struct {
-	RBAC *rbac.RBAC
+	RBAC  *rbac.RBAC
+	SYS   *sys.SYS
+	Cache cachex.Cacher
 }
 
 func (a *Mods) Init(ctx context.Context) error {
 	if err := a.RBAC.Init(ctx); err != nil {
 		return err
 	}
+	if err := a.SYS.Init(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -33,12 +44,67 @@ func (a *Mods) RouterPrefixes() []string {
 }
 
 func (a *Mods)RegisterRouters(ctx context.Context, e *gin.Engine) error {
+	// Client IPs feed the IP filter, rate limits and login checks, so only trusted proxies may set them.
+	httpCfg := config.C.General.HTTP
+	if err := middleware.SetTrustedProxies(e, middleware.TrustedProxyConfig{
+		Proxies:         httpCfg.TrustedProxies,
+		RemoteIPHeaders: httpCfg.RemoteIPHeaders,
+		Platform:        httpCfg.TrustedPlatform,
+	}); err != nil {
+		return err
+	}
+
+	// Installed before the routes below so that it covers both the API and the UI.
+	compressCfg := config.C.Middleware.Compress
+	minLength := compressCfg.MinLength
+	if minLength <= 0 {
+		minLength = middleware.DefaultCompressConfig.MinLength
+	}
+	e.Use(middleware.CompressWithConfig(middleware.CompressConfig{
+		Enable:              compressCfg.Enable,
+		SkippedPathPrefixes: compressCfg.SkippedPathPrefixes,
+		ContentTypes:        compressCfg.ContentTypes,
+		MinLength:           minLength,
+		Brotli:              compressCfg.Brotli,
+	}))
+
 	gAPI := e.Group(apiPrefix)
+	gAPI.Use(middleware.ETagWithConfig(middleware.ETagConfig{
+		Enable:              config.C.Middleware.ETag.Enable,
+		SkippedPathPrefixes: config.C.Middleware.ETag.SkippedPathPrefixes,
+	}))
+	// Group middlewares run after the global ones, so the idempotency keys are scoped by the authenticated user.
+	idemCfg := config.C.Middleware.Idempotency
+	gAPI.Use(middleware.IdempotencyWithConfig(middleware.IdempotencyConfig{
+		Enable:              idemCfg.Enable,
+		SkippedPathPrefixes: idemCfg.SkippedPathPrefixes,
+		Expiration:          time.Duration(idemCfg.Expiration) * time.Second,
+		LockExpiration:      time.Duration(idemCfg.LockExpiration) * time.Second,
+		Cache:               a.Cache,
+		Namespace:           config.CacheNSForIdempotency,
+	}))
 	v1 := gAPI.Group("v1")
 
 	if err := a.RBAC.RegisterV1Routers(ctx, v1); err != nil {
 		return err
 	}
+	if err := a.SYS.RegisterV1Routers(ctx, v1); err != nil {
+		return err
+	}
+
+	// The UI answers the paths without a route, the directory on disk overrides the embedded one.
+	staticCfg := config.C.Middleware.Static
+	maxAge := staticCfg.MaxAge
+	if maxAge <= 0 {
+		maxAge = middleware.DefaultStaticConfig.MaxAge
+	}
+	e.NoRoute(middleware.StaticWithConfig(middleware.StaticConfig{
+		Root:             staticCfg.Dir,
+		FS:               web.FS(),
+		APIPathPrefixes:  staticCfg.APIPathPrefixes,
+		MaxAge:           maxAge,
+		ImmutablePattern: middleware.DefaultStaticConfig.ImmutablePattern,
+	}))
 	return nil
 }
 
@@ -46,5 +112,8 @@ func (a *Mods)Release(ctx context.Context) error {
 	if err := a.RBAC.Release(ctx); err != nil {
 		return err
 	}
+	if err := a.SYS.Release(ctx); err != nil {
+		return err
+	}
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"gin-admin/internal/mods/rbac/biz"
+	"gin-admin/internal/mods/rbac/schema"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AppKey struct {
+	AppKeyBIZ *biz.AppKey
+}
+
+func (a *AppKey) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.AppKeyQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.AppKeyBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *AppKey) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.AppKeyBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *AppKey) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.AppKeyForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.AppKeyBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *AppKey) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.AppKeyForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.AppKeyBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *AppKey) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.AppKeyBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *AppKey) ResetSecret(c *gin.Context) {
+	ctx := c.Request.Context()
+	result, err := a.AppKeyBIZ.ResetSecret(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
@@ -14,7 +14,7 @@ type Login struct {
 
 func (a *Login) GetCaptcha(c *gin.Context) {
 	ctx := c.Request.Context()
-	data, err := a.LoginBIZ.GetCaptcha(ctx)
+	data, err := a.LoginBIZ.GetCaptcha(ctx, c.ClientIP())
 	if err != nil {
 		util.ResError(c, err)
 		return
@@ -30,6 +30,14 @@ func (a *Login) ResponseCaptcha(c *gin.Context) {
 	}
 }
 
+func (a *Login) ResponseCaptchaAudio(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.LoginBIZ.ResponseCaptchaAudio(ctx, c.Writer, c.Query("id"), c.Query("lang"))
+	if err != nil {
+		util.ResError(c, err)
+	}
+}
+
 func (a *Login) Login(c *gin.Context) {
 	ctx := c.Request.Context()
 	item := new(schema.LoginForm)
@@ -37,6 +45,8 @@ func (a *Login) Login(c *gin.Context) {
 		util.ResError(c, err)
 		return
 	}
+	item.ClientIP = c.ClientIP()
+	item.UserAgent = c.Request.UserAgent()
 	data, err := a.LoginBIZ.Login(ctx, item.Trim())
 	if err != nil {
 		util.ResError(c, err)
@@ -0,0 +1,243 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/rbac/dal"
+	"gin-admin/internal/mods/rbac/schema"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/signx"
+	"gin-admin/pkg/util"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const appKeyCacheExp = time.Hour
+
+// defaultMaxSignedBodyLen caps the body read to verify a signature when CopyBody sets no limit.
+const defaultMaxSignedBodyLen = 32 << 20
+
+// appKeyCache is what the verification needs from an app key, cached by key.
+type appKeyCache struct {
+	Secret string
+	UserID string
+}
+
+// AppKey manages the app keys of the integrations that sign their requests, see pkg/signx.
+type AppKey struct {
+	Cache        cachex.Cacher
+	AppKeyDAL    *dal.AppKey
+	UserDAL      *dal.User
+	LoginBIZ     *Login
+	verifierOnce sync.Once
+	verifier     *signx.Verifier
+}
+
+func (a *AppKey) Query(ctx context.Context, params schema.AppKeyQueryParam) (*schema.AppKeyQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.AppKeyDAL.Query(ctx, params, schema.AppKeyQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *AppKey) Get(ctx context.Context, id string) (*schema.AppKey, error) {
+	item, err := a.AppKeyDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, errors.NotFound("", "App key not found")
+	}
+	return item, nil
+}
+
+func (a *AppKey) checkUser(ctx context.Context, userID string) error {
+	if userID == config.C.General.Root.ID {
+		return errors.BadRequest("", "The root user can't be a service user")
+	}
+
+	exists, err := a.UserDAL.Exists(ctx, userID)
+	if err != nil {
+		return err
+	} else if !exists {
+		return errors.BadRequest("", "Service user not found")
+	}
+	return nil
+}
+
+func newAppSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Create creates an app key, the secret is only returned here.
+func (a *AppKey) Create(ctx context.Context, formItem *schema.AppKeyForm) (*schema.AppKeySecret, error) {
+	if err := a.checkUser(ctx, formItem.UserID); err != nil {
+		return nil, err
+	}
+
+	secret, err := newAppSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	item := &schema.AppKey{
+		ID:        util.NewXID(),
+		Key:       util.NewXID(),
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(item); err != nil {
+		return nil, err
+	}
+
+	if err := a.AppKeyDAL.Create(ctx, item); err != nil {
+		return nil, err
+	}
+	return &schema.AppKeySecret{AppKey: item, Secret: secret}, nil
+}
+
+func (a *AppKey) Update(ctx context.Context, id string, formItem *schema.AppKeyForm) error {
+	if err := a.checkUser(ctx, formItem.UserID); err != nil {
+		return err
+	}
+
+	item, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := formItem.FillTo(item); err != nil {
+		return err
+	}
+	item.UpdatedAt = time.Now()
+	if err := a.AppKeyDAL.Update(ctx, item); err != nil {
+		return err
+	}
+	return a.Cache.Delete(ctx, config.CacheNSForAppKey, item.Key)
+}
+
+func (a *AppKey) Delete(ctx context.Context, id string) error {
+	item, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := a.AppKeyDAL.Delete(ctx, id); err != nil {
+		return err
+	}
+	return a.Cache.Delete(ctx, config.CacheNSForAppKey, item.Key)
+}
+
+// ResetSecret replaces the secret of an app key, the requests signed with the old one are refused at once.
+func (a *AppKey) ResetSecret(ctx context.Context, id string) (*schema.AppKeySecret, error) {
+	item, err := a.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newAppSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.AppKeyDAL.UpdateSecret(ctx, id, secret); err != nil {
+		return nil, err
+	}
+	if err := a.Cache.Delete(ctx, config.CacheNSForAppKey, item.Key); err != nil {
+		return nil, err
+	}
+	return &schema.AppKeySecret{AppKey: item, Secret: secret}, nil
+}
+
+func (a *AppKey) getAppKey(ctx context.Context, key string) (appKeyCache, bool, error) {
+	appKeys := cachex.NewMsgpack[appKeyCache](a.Cache, config.CacheNSForAppKey)
+	return appKeys.GetOrLoad(ctx, key, appKeyCacheExp, func(ctx context.Context) (appKeyCache, error) {
+		item, err := a.AppKeyDAL.GetByKey(ctx, key)
+		if err != nil {
+			return appKeyCache{}, err
+		} else if item == nil || item.Status != schema.AppKeyStatusEnabled {
+			return appKeyCache{}, cachex.ErrNotFound
+		}
+		return appKeyCache{Secret: item.Secret, UserID: item.UserID}, nil
+	})
+}
+
+func (a *AppKey) getVerifier() *signx.Verifier {
+	a.verifierOnce.Do(func() {
+		a.verifier = signx.NewVerifier(signx.VerifierConfig{
+			ClockSkew: time.Duration(config.C.Middleware.Auth.Signature.ClockSkew) * time.Second,
+			Cache:     a.Cache,
+			Namespace: config.CacheNSForSignNonce,
+			Secret: func(ctx context.Context, key string) (string, bool, error) {
+				app, ok, err := a.getAppKey(ctx, key)
+				return app.Secret, ok, err
+			},
+		})
+	})
+	return a.verifier
+}
+
+// ParseUserID is the AuthConfig ParseUserID hook when signed requests are enabled. Signed requests are
+// made as the service user of the app key, the others are passed to Login.ParseUserID.
+func (a *AppKey) ParseUserID(c *gin.Context) (string, error) {
+	if !config.C.Middleware.Auth.Signature.Enable || !signx.HasSignature(c.Request) {
+		return a.LoginBIZ.ParseUserID(c)
+	}
+
+	ctx := c.Request.Context()
+	body := util.GetBodyData(c)
+	if body == nil && c.Request.Body != nil {
+		maxLen := config.C.Middleware.CopyBody.MaxContentLen
+		if maxLen <= 0 {
+			maxLen = defaultMaxSignedBodyLen
+		}
+		b, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxLen))
+		if err != nil {
+			return "", errors.RequestEntityTooLarge("", "req %d ", maxLen)
+		}
+		c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	key, err := a.getVerifier().Verify(ctx, c.Request, body)
+	if err != nil {
+		return "", err
+	}
+
+	app, ok, err := a.getAppKey(ctx, key)
+	if err != nil {
+		return "", err
+	} else if !ok {
+		return "", signx.ErrInvalidSignature
+	}
+
+	userCache, ok, err := a.LoginBIZ.getUserCache(ctx, app.UserID)
+	if err != nil {
+		return "", err
+	} else if !ok {
+		return "", errors.Unauthorized("", "The service user of the app key is not activated")
+	}
+
+	c.Request = c.Request.WithContext(util.NewUserCache(ctx, userCache))
+	return app.UserID, nil
+}
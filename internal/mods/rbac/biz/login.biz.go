@@ -1,21 +1,33 @@
 package biz
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"gin-admin/internal/config"
 	"gin-admin/internal/mods/rbac/dal"
 	"gin-admin/internal/mods/rbac/schema"
 	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/captchax"
 	"gin-admin/pkg/crypto/hash"
 	"gin-admin/pkg/errors"
 	"gin-admin/pkg/jwtx"
 	"gin-admin/pkg/logging"
+	"gin-admin/pkg/notify"
+	"gin-admin/pkg/redisx"
 	"gin-admin/pkg/util"
 	"net/http"
+	"net/netip"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/LyricTian/captcha"
+	"github.com/LyricTian/captcha/store"
 	"go.uber.org/zap"
 
 	"github.com/gin-gonic/gin"
@@ -28,6 +40,8 @@ type Login struct {
 	UserRoleDAL *dal.UserRole
 	MenuDAL     *dal.Menu
 	UserBIZ     *User
+	captchaMu   sync.Mutex
+	captcha     *captchax.Manager
 }
 
 func (a *Login) ParseUserID(c *gin.Context) (string, error) {
@@ -55,55 +69,150 @@ func (a *Login) ParseUserID(c *gin.Context) (string, error) {
 		return rootID, nil
 	}
 
-	userCacheVal, ok, err := a.Cache.Get(ctx, config.CacheNSForUser, userID)
+	userCache, ok, err := a.getUserCache(ctx, userID)
 	if err != nil {
 		return "", err
-	} else if ok {
-		userCache := util.ParseUserCache(userCacheVal)
-		c.Request = c.Request.WithContext(util.NewUserCache(ctx, userCache))
-		return userID, nil
+	} else if !ok {
+		return "", invalidToken
 	}
 
-	user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
-		QueryOptions: util.QueryOptions{
-			SelectFields: []string{"status"},
-		},
-	})
-	if err != nil {
-		return "", err
-	} else if user != nil || user.Status != schema.UserStatusActivated {
-		return "", invalidToken
+	c.Request = c.Request.WithContext(util.NewUserCache(ctx, userCache))
+	return userID, nil
+}
+
+// getUserCache returns the cached roles of an activated user, ok is false if the user is missing or frozen.
+func (a *Login) getUserCache(ctx context.Context, userID string) (util.UserCache, bool, error) {
+	userCaches := cachex.NewMsgpack[util.UserCache](a.Cache, config.CacheNSForUser)
+	return userCaches.GetOrLoad(ctx, userID,
+		time.Duration(config.R().UserCacheExp)*time.Hour, func(ctx context.Context) (util.UserCache, error) {
+			user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
+				QueryOptions: util.QueryOptions{
+					SelectFields: []string{"status"},
+				},
+			})
+			if err != nil {
+				return util.UserCache{}, err
+			} else if user == nil || user.Status != schema.UserStatusActivated {
+				return util.UserCache{}, cachex.ErrNotFound
+			}
+
+			roleIDs, err := a.UserBIZ.GetRoleIDs(ctx, userID)
+			if err != nil {
+				return util.UserCache{}, err
+			}
+			return util.UserCache{RoleIDs: roleIDs}, nil
+		})
+}
+
+// getCaptcha creates the captcha manager on first use. Captchas are kept in Redis when configured,
+// which is required when more than one instance serves the login API. A failed Redis connection is
+// retried by the next call, so that the login recovers without a restart.
+func (a *Login) getCaptcha() (*captchax.Manager, error) {
+	a.captchaMu.Lock()
+	defer a.captchaMu.Unlock()
+	if a.captcha != nil {
+		return a.captcha, nil
 	}
 
-	roleIDs, err := a.UserBIZ.GetRoleIDs(ctx, userID)
-	if err != nil {
-		return "", err
+	cfg := config.C.Util.Captcha
+	expiration := time.Duration(cfg.Expiration) * time.Second
+	if expiration <= 0 {
+		expiration = captcha.Expiration
 	}
 
-	userCache := util.UserCache{
-		RoleIDs: roleIDs,
+	if cfg.CacheType != "redis" {
+		a.captcha = captchax.NewManager(store.NewMemoryStore(time.Minute, expiration))
+		return a.captcha, nil
 	}
-	err = a.Cache.Set(ctx, config.CacheNSForUser, userID, userCache.String())
+
+	cli, err := redisx.Get(redisx.Config(cfg.Redis.RedisConfig))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	c.Request = c.Request.WithContext(util.NewUserCache(ctx, userCache))
-	return userID, nil
+	ns := strings.TrimSuffix(cfg.Redis.KeyPrefix, ":")
+	if ns == "" {
+		ns = "captcha"
+	}
+	cache := cachex.NewRedisCacheWithUniversalClient(cli)
+	a.captcha = captchax.NewManager(captchax.NewCacheStore(cache, ns, expiration))
+	return a.captcha, nil
 }
 
-func (a *Login) GetCaptcha(ctx context.Context) (*schema.Captcha, error) {
+// captchaRequired reports whether a login from the IP needs a captcha,
+// which is always the case unless a threshold of failed logins is configured.
+func (a *Login) captchaRequired(ctx context.Context, clientIP string) (bool, error) {
+	threshold := config.R().CaptchaFailThreshold
+	if threshold <= 0 {
+		return true, nil
+	}
+
+	val, ok, err := a.Cache.Get(ctx, config.CacheNSForLoginFail, clientIP)
+	if err != nil || !ok {
+		return false, err
+	}
+	fails, _ := strconv.Atoi(val)
+	return fails >= threshold, nil
+}
+
+// defaultLoginFailWindow bounds the failure count when no FailWindow is configured, since a successful
+// login does not reset it.
+const defaultLoginFailWindow = 15 * time.Minute
+
+// recordLoginFail counts a failed login of the IP. Successful logins do not reset the count, or an
+// attacker with one valid account could log into it between guesses and never reach the threshold;
+// the count expires with the fail window instead.
+func (a *Login) recordLoginFail(ctx context.Context, clientIP string) {
+	if config.R().CaptchaFailThreshold <= 0 {
+		return
+	}
+
+	window := time.Duration(config.C.Util.Captcha.FailWindow) * time.Second
+	if window <= 0 {
+		window = defaultLoginFailWindow
+	}
+	if _, err := a.Cache.Incr(ctx, config.CacheNSForLoginFail, clientIP, 1, window); err != nil {
+		logging.Context(ctx).Error("record login failure error", zap.Error(err))
+	}
+}
+
+func (a *Login) GetCaptcha(ctx context.Context, clientIP string) (*schema.Captcha, error) {
+	m, err := a.getCaptcha()
+	if err != nil {
+		return nil, err
+	}
+
+	required, err := a.captchaRequired(ctx, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	r := config.R()
+	typ := r.CaptchaType
+	if typ != captchax.TypeMath {
+		typ = captchax.TypeDigit
+	}
 	return &schema.Captcha{
-		CaptchaID: captcha.NewLen(config.C.Util.Captcha.Length),
+		CaptchaID: m.New(typ, r.CaptchaLength),
+		Type:      typ,
+		Required:  required,
 	}, nil
 }
 
 func (a *Login) ResponseCaptcha(ctx context.Context, w http.ResponseWriter, id string, reload bool) error {
-	if reload && !captcha.Reload(id) {
+	m, err := a.getCaptcha()
+	if err != nil {
+		return err
+	}
+
+	if reload && !m.Reload(id) {
 		return errors.NotFound("", "captcha id not found")
 	}
-	err := captcha.WriteImage(w, id, config.C.Util.Captcha.Width, config.C.Util.Captcha.Height)
+
+	var buf bytes.Buffer
+	r := config.R()
+	err = m.WriteImage(&buf, id, r.CaptchaWidth, r.CaptchaHeight)
 	if err != nil {
-		if err == captcha.ErrNotFound {
+		if err == captchax.ErrNotFound {
 			return errors.NotFound("", "captcha id not found")
 		}
 		return err
@@ -113,7 +222,33 @@ func (a *Login) ResponseCaptcha(ctx context.Context, w http.ResponseWriter, id s
 	w.Header().Set("Pragma", "no-cache")
 	w.Header().Set("Expires", "0")
 	w.Header().Set("Content-Type", "image/png")
-	return nil
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (a *Login) ResponseCaptchaAudio(ctx context.Context, w http.ResponseWriter, id, lang string) error {
+	m, err := a.getCaptcha()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = m.WriteAudio(&buf, id, lang)
+	if err != nil {
+		if err == captchax.ErrNotFound {
+			return errors.NotFound("", "captcha id not found")
+		} else if err == captchax.ErrAudioUnsupported {
+			return errors.BadRequest("", "Audio is not available for this captcha")
+		}
+		return err
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+	w.Header().Set("Content-Type", "audio/x-wav")
+	_, err = buf.WriteTo(w)
+	return err
 }
 
 func (a *Login) GetUserToken(ctx context.Context, userID string) (*schema.LoginToken, error) {
@@ -135,38 +270,75 @@ func (a *Login) GetUserToken(ctx context.Context, userID string) (*schema.LoginT
 	}, nil
 }
 
+// loginIPAllowed checks the client IP against the allowed login IPs of a user, which allow any IP if empty.
+func loginIPAllowed(allowed, clientIP string) bool {
+	if allowed == "" {
+		return true
+	}
+
+	prefixes, err := util.ParseIPPrefixes(allowed)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return false
+	}
+	return util.ContainsIP(prefixes, addr)
+}
+
 func (a *Login) Login(ctx context.Context, formItem *schema.LoginForm) (*schema.LoginToken, error) {
-	if !captcha.VerifyString(formItem.CaptchaID, formItem.CaptchaCode) {
-		return nil, errors.BadRequest(config.ErrInvalidCaptchaID, "incorrect captcha")
+	if required, err := a.captchaRequired(ctx, formItem.ClientIP); err != nil {
+		return nil, err
+	} else if required {
+		m, err := a.getCaptcha()
+		if err != nil {
+			return nil, err
+		} else if !m.Verify(formItem.CaptchaID, formItem.CaptchaCode) {
+			a.recordLoginFail(ctx, formItem.ClientIP)
+			return nil, errors.BadRequest(config.ErrInvalidCaptchaID, "incorrect captcha")
+		}
 	}
 
 	ctx = logging.NewTag(ctx, logging.TagKeyLogin)
 
 	if formItem.Username == config.C.General.Root.Username {
 		if formItem.Password != config.C.General.Root.Password {
+			a.recordLoginFail(ctx, formItem.ClientIP)
 			return nil, errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
 		}
 		userID := config.C.General.Root.ID
 		ctx = logging.NewUserID(ctx, userID)
 		logging.Context(ctx).Info("login by root")
+		a.checkNewDevice(ctx, userID, formItem)
 		return a.GetUserToken(ctx, userID)
 	}
 
 	user, err := a.UserDAL.GetByUsername(ctx, formItem.Username, schema.UserQueryOptions{
 		QueryOptions: util.QueryOptions{
-			SelectFields: []string{"id", "password", "status"},
+			SelectFields: []string{"id", "password", "status", "allowed_login_ips"},
 		},
 	})
 
 	if err != nil {
 		return nil, err
 	} else if user == nil {
+		a.recordLoginFail(ctx, formItem.ClientIP)
 		return nil, errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
 	} else if user.Status != schema.UserStatusActivated {
 		return nil, errors.BadRequest("", "User status is not activated, please contact the administrator")
 	}
 
 	if err := hash.CompareHashAndPassword(user.Password, formItem.Password); err != nil {
+		a.recordLoginFail(ctx, formItem.ClientIP)
+		return nil, errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
+	}
+
+	// Same error as a wrong password, so the response does not confirm the password to other IPs.
+	if !loginIPAllowed(user.AllowedLoginIPs, formItem.ClientIP) {
+		logging.Context(ctx).Warn("login from a disallowed IP",
+			zap.String("user_id", user.ID), zap.String("client_ip", formItem.ClientIP))
+		a.recordLoginFail(ctx, formItem.ClientIP)
 		return nil, errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
 	}
 
@@ -174,22 +346,49 @@ func (a *Login) Login(ctx context.Context, formItem *schema.LoginForm) (*schema.
 
 	ctx = logging.NewUserID(ctx, userID)
 
-	roleIDs, err := a.UserBIZ.GetRoleIDs(ctx, userID)
-	if err != nil {
-		return nil, err
+	// Drops a cached state from before the login, the first request loads the current roles.
+	if err := a.Cache.Delete(ctx, config.CacheNSForUser, userID); err != nil {
+		logging.Context(ctx).Error("delete user cache error", zap.Error(err))
 	}
 
-	userCache := util.UserCache{RoleIDs: roleIDs}
-	err = a.Cache.Set(ctx, config.CacheNSForUser, userID, userCache.String(),
-		time.Duration(config.C.Dictionary.UserCacheExp)*time.Hour)
+	logging.Context(ctx).Info("login by user", zap.String("username", user.Username))
+	a.checkNewDevice(ctx, userID, formItem)
 
+	return a.GetUserToken(ctx, userID)
+}
+
+// checkNewDevice publishes a notify.EventLoginNewDevice event the first time a user signs
+// in with a user agent. Known devices are remembered for 180 days.
+func (a *Login) checkNewDevice(ctx context.Context, userID string, formItem *schema.LoginForm) {
+	sum := sha256.Sum256([]byte(formItem.UserAgent))
+	key := userID + ":" + hex.EncodeToString(sum[:8])
+
+	exists, err := a.Cache.Exists(ctx, config.CacheNSForDevice, key)
 	if err != nil {
-		logging.Context(ctx).Error("set user cache error", zap.Error(err))
+		logging.Context(ctx).Error("check login device error", zap.Error(err))
+		return
 	}
 
-	logging.Context(ctx).Info("login by user", zap.String("username", user.Username))
+	err = a.Cache.Set(ctx, config.CacheNSForDevice, key, formItem.ClientIP, 180*24*time.Hour)
+	if err != nil {
+		logging.Context(ctx).Error("set login device error", zap.Error(err))
+	}
+	if exists {
+		return
+	}
 
-	return a.GetUserToken(ctx, userID)
+	notify.Publish(notify.Event{
+		Type:   notify.EventLoginNewDevice,
+		UserID: userID,
+		Title:  "New device login",
+		Content: fmt.Sprintf("Your account %s signed in from a new device at %s.\nIP: %s\nUser agent: %s",
+			formItem.Username, time.Now().Format(time.DateTime), formItem.ClientIP, formItem.UserAgent),
+		Data: map[string]interface{}{
+			"username":   formItem.Username,
+			"ip":         formItem.ClientIP,
+			"user_agent": formItem.UserAgent,
+		},
+	})
 }
 
 func (a *Login) RefreshToken(ctx context.Context) (*schema.LoginToken, error) {
@@ -51,6 +51,21 @@ func (a *Menu) InitFromFile(ctx context.Context, menuFile string) error {
 		return errors.Errorf("unsupported menu file format %s", ext)
 	}
 
+	// Replicas starting together would otherwise insert the same menus twice.
+	locker, err := cachex.NewLocker(a.Cache)
+	if err != nil {
+		return err
+	}
+	lock, err := locker.Lock(ctx, "menu:init", time.Minute)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := locker.Unlock(ctx, lock); err != nil {
+			logging.Context(ctx).Warn("failed to unlock menu init", zap.Error(err))
+		}
+	}()
+
 	return a.Trans.Exec(ctx, func(ctx context.Context) error {
 		return a.createInBatchByParent(ctx, menus, nil)
 	})
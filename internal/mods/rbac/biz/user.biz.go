@@ -2,12 +2,14 @@ package biz
 
 import (
 	"context"
+	"fmt"
 	"gin-admin/internal/config"
 	"gin-admin/internal/mods/rbac/dal"
 	"gin-admin/internal/mods/rbac/schema"
 	"gin-admin/pkg/cachex"
 	"gin-admin/pkg/crypto/hash"
 	"gin-admin/pkg/errors"
+	"gin-admin/pkg/notify"
 	"gin-admin/pkg/util"
 	"time"
 )
@@ -88,7 +90,7 @@ func (a *User) Create(ctx context.Context, formItem *schema.UserForm) (*schema.U
 	}
 
 	if formItem.Password == "" {
-		formItem.Password = config.C.General.DefaultLoginPwd
+		formItem.Password = config.R().DefaultLoginPwd
 	}
 
 	if err := formItem.FillTo(user); err != nil {
@@ -133,11 +135,16 @@ func (a *User) Update(ctx context.Context, id string, formItem *schema.UserForm)
 		}
 	}
 
+	oldRoleIDs, err := a.GetRoleIDs(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	if err := formItem.FillTo(user); err != nil {
 		return err
 	}
 	user.UpdatedAt = time.Now()
-	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
 		if err := a.UserDAL.Update(ctx, user); err != nil {
 			return err
 		}
@@ -161,6 +168,42 @@ func (a *User) Update(ctx context.Context, id string, formItem *schema.UserForm)
 		}
 		return a.Cache.Delete(ctx, config.CacheNSForUser, id)
 	})
+	if err != nil {
+		return err
+	}
+
+	if newRoleIDs := formItem.Roles.ToRoleIDs(); !sameIDs(oldRoleIDs, newRoleIDs) {
+		notify.Publish(notify.Event{
+			Type:    notify.EventUserRoleChanged,
+			UserID:  id,
+			Title:   "User roles changed",
+			Content: fmt.Sprintf("The roles of user %s were changed by %s.", user.Username, util.FromUserID(ctx)),
+			Data: map[string]interface{}{
+				"username":     user.Username,
+				"old_role_ids": oldRoleIDs,
+				"new_role_ids": newRoleIDs,
+				"operator":     util.FromUserID(ctx),
+			},
+		})
+	}
+	return nil
+}
+
+func sameIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	m := make(map[string]struct{}, len(a))
+	for _, id := range a {
+		m[id] = struct{}{}
+	}
+	for _, id := range b {
+		if _, ok := m[id]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 func (a *User) Delete(ctx context.Context, id string) error {
@@ -190,7 +233,7 @@ func (a *User) ResetPassword(ctx context.Context, id string) error {
 		return errors.NotFound("", "User not found")
 	}
 
-	hashPass, err := hash.GeneratePassword(config.C.General.DefaultLoginPwd)
+	hashPass, err := hash.GeneratePassword(config.R().DefaultLoginPwd)
 	if err != nil {
 		return errors.BadRequest("", "GeneratePassword %s error", err.Error())
 	}
@@ -114,6 +114,21 @@ func (a *Casbinx) load(ctx context.Context) error {
 	wg.Wait()
 
 	if buf.Len() > 0 {
+		// Replicas sharing the work dir must not rewrite the policy file while another one reads it.
+		locker, err := cachex.NewLocker(a.Cache)
+		if err != nil {
+			return err
+		}
+		lock, err := locker.Lock(ctx, "casbin:policy", time.Minute)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := locker.Unlock(ctx, lock); err != nil {
+				logging.Context(ctx).Warn("Failed to unlock casbin policy", zap.Error(err))
+			}
+		}()
+
 		policyFile := filepath.Join(config.C.General.WorkDir, config.C.Middleware.Casbin.GenPolicyFile)
 		_ = os.Rename(policyFile, policyFile+".bak")
 		_ = os.WriteFile(policyFile, buf.Bytes(), 0755)
@@ -0,0 +1,101 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/rbac/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func GetAppKeyDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.AppKey))
+}
+
+type AppKey struct {
+	DB *gorm.DB
+}
+
+func (a *AppKey) Query(ctx context.Context, params schema.AppKeyQueryParam, opts ...schema.AppKeyQueryOptions) (*schema.AppKeyQueryResult, error) {
+	var opt schema.AppKeyQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetAppKeyDB(ctx, a.DB)
+	if v := params.LikeName; len(v) > 0 {
+		db = db.Where("name LIKE ?", "%"+v+"%")
+	}
+	if v := params.UserID; len(v) > 0 {
+		db = db.Where("user_id=?", v)
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status=?", v)
+	}
+
+	var list schema.AppKeys
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.AppKeyQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *AppKey) Get(ctx context.Context, id string, opts ...schema.AppKeyQueryOptions) (*schema.AppKey, error) {
+	var opt schema.AppKeyQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.AppKey)
+	ok, err := util.FindOne(ctx, GetAppKeyDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *AppKey) GetByKey(ctx context.Context, key string, opts ...schema.AppKeyQueryOptions) (*schema.AppKey, error) {
+	var opt schema.AppKeyQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.AppKey)
+	ok, err := util.FindOne(ctx, GetAppKeyDB(ctx, a.DB).Where("app_key=?", key), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *AppKey) Create(ctx context.Context, item *schema.AppKey) error {
+	result := GetAppKeyDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *AppKey) Update(ctx context.Context, item *schema.AppKey) error {
+	result := GetAppKeyDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *AppKey) UpdateSecret(ctx context.Context, id, secret string) error {
+	result := GetAppKeyDB(ctx, a.DB).Where("id=?", id).Select("secret", "updated_at").Updates(schema.AppKey{Secret: secret, UpdatedAt: time.Now()})
+	return errors.WithStack(result.Error)
+}
+
+func (a *AppKey) Delete(ctx context.Context, id string) error {
+	result := GetAppKeyDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.AppKey))
+	return errors.WithStack(result.Error)
+}
@@ -26,6 +26,9 @@ func (a *Role) Query(ctx context.Context, params schema.RoleQueryParam, opts ...
 	if v := params.InIDs; len(v) > 0 {
 		db = db.Where("id IN (?)", v)
 	}
+	if v := params.InCodes; len(v) > 0 {
+		db = db.Where("code IN (?)", v)
+	}
 
 	if v := params.LikeName; len(v) > 0 {
 		db = db.Where("name LIKE ?", "%"+v+"%")
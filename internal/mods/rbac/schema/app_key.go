@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"time"
+)
+
+const (
+	AppKeyStatusEnabled  = "enabled"
+	AppKeyStatusDisabled = "disabled"
+)
+
+// AppKey lets an integration sign its requests instead of logging in. The requests are made as the
+// service user, with the roles of that user.
+type AppKey struct {
+	ID        string
+	Name      string
+	Key       string `gorm:"column:app_key;uniqueIndex;size:32"`
+	Secret    string `json:"-"`
+	UserID    string
+	Status    string
+	Remark    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (a *AppKey) TableName() string {
+	return config.C.FormatTableName("app_key")
+}
+
+// AppKeySecret is returned when a key is created or its secret is reset, the only times the secret is shown.
+type AppKeySecret struct {
+	*AppKey
+	Secret string
+}
+
+type AppKeyQueryParam struct {
+	util.PaginationParam
+	LikeName string `form:"name"`
+	UserID   string `form:"userID"`
+	Status   string `form:"status"`
+}
+
+type AppKeyQueryOptions struct {
+	util.QueryOptions
+}
+
+type AppKeyQueryResult struct {
+	Data       AppKeys
+	PageResult *util.PaginationResult
+}
+
+type AppKeys []*AppKey
+
+type AppKeyForm struct {
+	Name   string
+	UserID string
+	Status string
+	Remark string
+}
+
+func (a *AppKeyForm) Validate() error {
+	if a.Name == "" {
+		return errors.BadRequest("", "Name is required")
+	} else if a.UserID == "" {
+		return errors.BadRequest("", "Service user is required")
+	}
+
+	switch a.Status {
+	case "":
+		a.Status = AppKeyStatusEnabled
+	case AppKeyStatusEnabled, AppKeyStatusDisabled:
+	default:
+		return errors.BadRequest("", "Invalid status: %s", a.Status)
+	}
+	return nil
+}
+
+func (a *AppKeyForm) FillTo(item *AppKey) error {
+	item.Name = a.Name
+	item.UserID = a.UserID
+	item.Status = a.Status
+	item.Remark = a.Remark
+	return nil
+}
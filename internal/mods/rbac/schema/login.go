@@ -4,6 +4,8 @@ import "strings"
 
 type Captcha struct {
 	CaptchaID string
+	Type      string // digit/math
+	Required  bool   // False while the client IP is below the failed login threshold
 }
 
 type LoginForm struct {
@@ -11,6 +13,8 @@ type LoginForm struct {
 	Password    string
 	CaptchaID   string
 	CaptchaCode string
+	ClientIP    string `json:"-"`
+	UserAgent   string `json:"-"`
 }
 
 func (a *LoginForm) Trim() *LoginForm {
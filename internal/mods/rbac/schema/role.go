@@ -34,6 +34,7 @@ type RoleQueryParam struct {
 	Status      string
 	ResultType  string
 	InIDs       []string
+	InCodes     []string
 	GtUpdatedAt *time.Time
 }
 
@@ -5,6 +5,7 @@ import (
 	"gin-admin/pkg/crypto/hash"
 	"gin-admin/pkg/errors"
 	"gin-admin/pkg/util"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -16,17 +17,18 @@ const (
 )
 
 type User struct {
-	ID        string
-	Username  string
-	Name      string
-	Password  string
-	Phone     string
-	Email     string
-	Remark    string
-	Status    string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Roles     UserRoles
+	ID              string
+	Username        string
+	Name            string
+	Password        string
+	Phone           string
+	Email           string
+	Remark          string
+	Status          string
+	AllowedLoginIPs string // Comma separated IPs or CIDRs the user may log in from, any IP if empty
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Roles           UserRoles
 }
 
 func (a *User) TableName() string {
@@ -60,20 +62,31 @@ func (a Users) ToIDs() []string {
 }
 
 type UserForm struct {
-	Username string
-	Name     string
-	Password string
-	Phone    string
-	Email    string
-	Remark   string
-	Status   string
-	Roles    UserRoles
+	Username        string
+	Name            string
+	Password        string
+	Phone           string
+	Email           string
+	Remark          string
+	Status          string
+	Roles           UserRoles
+	AllowedLoginIPs string
 }
 
 func (a *UserForm) Validate() error {
 	if a.Email != "" && validator.New().Var(a.Email, "email") != nil {
 		return errors.BadRequest("", "Invalid email")
 	}
+
+	prefixes, err := util.ParseIPPrefixes(a.AllowedLoginIPs)
+	if err != nil {
+		return errors.BadRequest("", "Invalid allowed login IPs: %s", err.Error())
+	}
+	ips := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		ips[i] = prefix.String()
+	}
+	a.AllowedLoginIPs = strings.Join(ips, ",")
 	return nil
 }
 
@@ -84,6 +97,7 @@ func (a *UserForm) FillTo(user *User) error {
 	user.Email = a.Email
 	user.Remark = a.Remark
 	user.Status = a.Status
+	user.AllowedLoginIPs = a.AllowedLoginIPs
 
 	if pass := a.Password; pass != "" {
 		hashPass, err := hash.GeneratePassword(pass)
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Announcement struct {
+	AnnouncementBIZ *biz.Announcement
+}
+
+func (a *Announcement) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.AnnouncementQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.AnnouncementBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *Announcement) QueryActive(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.AnnouncementBIZ.QueryActive(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+func (a *Announcement) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.AnnouncementBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *Announcement) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.AnnouncementForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.AnnouncementBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *Announcement) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.AnnouncementForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.AnnouncementBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Announcement) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.AnnouncementBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Announcement) Publish(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.AnnouncementBIZ.UpdateStatus(ctx, c.Param("id"), schema.AnnouncementStatusPublished)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Announcement) Withdraw(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.AnnouncementBIZ.UpdateStatus(ctx, c.Param("id"), schema.AnnouncementStatusDraft)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
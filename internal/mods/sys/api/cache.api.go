@@ -0,0 +1,106 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Cache struct {
+	CacheBIZ *biz.Cache
+}
+
+func (a *Cache) QueryNamespaces(c *gin.Context) {
+	ctx := c.Request.Context()
+	list, err := a.CacheBIZ.QueryNamespaces(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, list)
+}
+
+func (a *Cache) QueryKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.CacheKeyQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+	params.Namespace = c.Param("ns")
+
+	result, err := a.CacheBIZ.QueryKeys(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *Cache) GetEntry(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.CacheBIZ.GetEntry(ctx, c.Param("ns"), c.Query("key"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *Cache) DeleteEntry(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.CacheBIZ.DeleteEntry(ctx, c.Param("ns"), c.Query("key"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Cache) ClearNamespace(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.CacheBIZ.ClearNamespace(ctx, c.Param("ns"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+// Backup downloads a snapshot of the browsable namespaces of the running cache, only badger caches support it.
+func (a *Cache) Backup(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.CacheBIZ.Backup(ctx, c.Writer)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	c.Abort()
+}
+
+// Restore replaces the browsable namespaces of the running cache with the uploaded "file" part.
+func (a *Cache) Restore(c *gin.Context) {
+	ctx := c.Request.Context()
+	fh, err := c.FormFile("file")
+	if err != nil {
+		util.ResError(c, errors.BadRequest("", "Failed to read backup file: %s", err.Error()))
+		return
+	}
+
+	file, err := fh.Open()
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	defer file.Close()
+
+	err = a.CacheBIZ.Restore(ctx, file)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type File struct {
+	FileBIZ *biz.File
+}
+
+func (a *File) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.FileQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.FileBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *File) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.FileBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+// Upload accepts a multipart form with one or more "file" parts.
+func (a *File) Upload(c *gin.Context) {
+	ctx := c.Request.Context()
+	maxSize := config.C.Util.File.MaxSize << 20
+	if maxSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		util.ResError(c, errors.BadRequest("", "Failed to parse multipart form: %s", err.Error()))
+		return
+	}
+
+	item := new(schema.FileForm)
+	if err := util.ParseForm(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	headers := form.File["file"]
+	if len(headers) == 0 {
+		util.ResError(c, errors.BadRequest("", "No file uploaded"))
+		return
+	}
+
+	result := make(schema.Files, 0, len(headers))
+	for _, fh := range headers {
+		file, err := a.FileBIZ.Upload(ctx, fh, item)
+		if err != nil {
+			util.ResError(c, err)
+			return
+		}
+		result = append(result, file)
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *File) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.FileForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.FileBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *File) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.FileBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *File) Download(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.FileBIZ.Download(ctx, c.Param("id"), c.Writer)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	c.Abort()
+}
+
+func (a *File) PresignURL(c *gin.Context) {
+	ctx := c.Request.Context()
+	url, err := a.FileBIZ.PresignURL(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, url)
+}
+
+func (a *File) PresignUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.FileUploadForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.FileBIZ.PresignUpload(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *File) ConfirmUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.FileConfirmForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.FileBIZ.ConfirmUpload(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// Image returns a resized variant of an image file, e.g. ?w=200&h=200&mode=fill&format=jpeg.
+func (a *File) Image(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.FileImageParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := params.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.FileBIZ.Image(ctx, c.Param("id"), params, c.Writer)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	c.Abort()
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IPRule struct {
+	IPRuleBIZ *biz.IPRule
+}
+
+func (a *IPRule) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.IPRuleQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.IPRuleBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *IPRule) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.IPRuleBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *IPRule) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.IPRuleForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.IPRuleBIZ.Create(ctx, item, c.ClientIP())
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *IPRule) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.IPRuleForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.IPRuleBIZ.Update(ctx, c.Param("id"), item, c.ClientIP())
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *IPRule) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.IPRuleBIZ.Delete(ctx, c.Param("id"), c.ClientIP())
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Job struct {
+	JobBIZ *biz.Job
+}
+
+func (a *Job) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.JobQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.JobBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *Job) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.JobBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *Job) Handlers(c *gin.Context) {
+	util.ResSuccess(c, a.JobBIZ.Handlers(c.Request.Context()))
+}
+
+func (a *Job) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.JobForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.JobBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *Job) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.JobForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.JobBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Job) Enable(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.JobBIZ.UpdateStatus(ctx, c.Param("id"), schema.JobStatusEnabled)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Job) Disable(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.JobBIZ.UpdateStatus(ctx, c.Param("id"), schema.JobStatusDisabled)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Job) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.JobBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+// Run starts the job immediately and returns the execution log, which is updated when the run finishes.
+func (a *Job) Run(c *gin.Context) {
+	ctx := c.Request.Context()
+	result, err := a.JobBIZ.Run(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *Job) QueryLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.JobLogQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+	params.JobID = c.Param("id")
+
+	result, err := a.JobBIZ.QueryLogs(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *Job) GetLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.JobBIZ.GetLog(ctx, c.Param("id"), c.Param("logId"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
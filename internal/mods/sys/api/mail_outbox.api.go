@@ -0,0 +1,78 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MailOutbox struct {
+	MailOutboxBIZ *biz.MailOutbox
+}
+
+func (a *MailOutbox) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.MailOutboxQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.MailOutboxBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *MailOutbox) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.MailOutboxBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *MailOutbox) Send(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.MailForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.MailOutboxBIZ.Enqueue(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *MailOutbox) Retry(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.MailOutboxBIZ.Retry(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *MailOutbox) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.MailOutboxBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
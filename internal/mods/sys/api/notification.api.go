@@ -0,0 +1,139 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/util"
+	"io"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+type Notification struct {
+	NotificationBIZ *biz.Notification
+}
+
+func (a *Notification) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.NotificationQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.NotificationBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *Notification) UnreadCount(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.NotificationBIZ.UnreadCount(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+func (a *Notification) MarkRead(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.NotificationBIZ.MarkRead(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Notification) MarkAllRead(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.NotificationBIZ.MarkRead(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Notification) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.NotificationBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Notification) Send(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.NotificationForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.NotificationBIZ.Send(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// Stream pushes notifications to the browser as Server-Sent Events. EventSource
+// cannot set headers, so the token is passed with the "token" query parameter.
+// Notification events carry their ID; a reconnecting EventSource sends the last one
+// back in Last-Event-ID and the notifications missed in between are replayed first.
+func (a *Notification) Stream(c *gin.Context) {
+	ctx := c.Request.Context()
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	sub, missed, err := a.NotificationBIZ.Subscribe(ctx, lastEventID)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	defer a.NotificationBIZ.Unsubscribe(sub)
+
+	heartbeat := time.NewTicker(a.NotificationBIZ.HeartbeatInterval())
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	for _, item := range missed {
+		c.Render(-1, sse.Event{Id: item.ID, Event: schema.NotificationEventCreated, Data: item})
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+			c.Render(-1, sse.Event{Id: ev.ID, Event: ev.Event, Data: ev.Data})
+			return true
+		case <-heartbeat.C:
+			a.NotificationBIZ.KeepAlive(ctx, sub.Key)
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		}
+	})
+}
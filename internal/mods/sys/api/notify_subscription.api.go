@@ -0,0 +1,101 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotifySubscription struct {
+	NotifySubscriptionBIZ *biz.NotifySubscription
+}
+
+func (a *NotifySubscription) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.NotifySubscriptionQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.NotifySubscriptionBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *NotifySubscription) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.NotifySubscriptionBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *NotifySubscription) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.NotifySubscriptionForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.NotifySubscriptionBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *NotifySubscription) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.NotifySubscriptionForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.NotifySubscriptionBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *NotifySubscription) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.NotifySubscriptionBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *NotifySubscription) Test(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.NotifySubscriptionBIZ.Test(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *NotifySubscription) EventTypes(c *gin.Context) {
+	util.ResSuccess(c, schema.NotifyEventTypes)
+}
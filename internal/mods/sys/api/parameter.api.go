@@ -0,0 +1,114 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Parameter struct {
+	ParameterBIZ *biz.Parameter
+}
+
+func (a *Parameter) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.ParameterQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.ParameterBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *Parameter) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.ParameterBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *Parameter) GetByCode(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.ParameterBIZ.GetByCode(ctx, c.Param("code"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *Parameter) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.ParameterForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.ParameterBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *Parameter) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.ParameterForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.ParameterBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Parameter) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.ParameterBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *Parameter) QueryHistories(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.ParameterHistoryQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+	params.ParameterID = c.Param("id")
+
+	result, err := a.ParameterBIZ.QueryHistories(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
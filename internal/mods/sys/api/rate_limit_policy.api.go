@@ -0,0 +1,87 @@
+package api
+
+import (
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RateLimitPolicy struct {
+	RateLimitPolicyBIZ *biz.RateLimitPolicy
+}
+
+func (a *RateLimitPolicy) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.RateLimitPolicyQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.RateLimitPolicyBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+func (a *RateLimitPolicy) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.RateLimitPolicyBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+func (a *RateLimitPolicy) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.RateLimitPolicyForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.RateLimitPolicyBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+func (a *RateLimitPolicy) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.RateLimitPolicyForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.RateLimitPolicyBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
+
+func (a *RateLimitPolicy) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.RateLimitPolicyBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOk(c)
+}
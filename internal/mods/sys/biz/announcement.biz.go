@@ -0,0 +1,200 @@
+package biz
+
+import (
+	"context"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/sse"
+	"gin-admin/pkg/util"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Announcement struct {
+	Broker          *sse.Broker
+	AnnouncementDAL *dal.Announcement
+	ticker          *time.Ticker
+}
+
+func (a *Announcement) Query(ctx context.Context, params schema.AnnouncementQueryParam) (*schema.AnnouncementQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.AnnouncementDAL.Query(ctx, params, schema.AnnouncementQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// QueryActive returns the published announcements currently visible to the logged-in user.
+func (a *Announcement) QueryActive(ctx context.Context) (schema.Announcements, error) {
+	now := time.Now()
+	result, err := a.AnnouncementDAL.Query(ctx, schema.AnnouncementQueryParam{
+		Status:   schema.AnnouncementStatusPublished,
+		ActiveAt: &now,
+	}, schema.AnnouncementQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if util.FromIsRootUser(ctx) {
+		return result.Data, nil
+	}
+
+	roleIDs := util.FromUserCache(ctx).RoleIDs
+	list := make(schema.Announcements, 0, len(result.Data))
+	for _, item := range result.Data {
+		if item.IsVisibleTo(roleIDs) {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+func (a *Announcement) Get(ctx context.Context, id string) (*schema.Announcement, error) {
+	announcement, err := a.AnnouncementDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if announcement == nil {
+		return nil, errors.NotFound("", "Announcement not found")
+	}
+	return announcement, nil
+}
+
+func (a *Announcement) Create(ctx context.Context, formItem *schema.AnnouncementForm) (*schema.Announcement, error) {
+	announcement := &schema.Announcement{
+		ID:        util.NewXID(),
+		Status:    schema.AnnouncementStatusDraft,
+		CreatedBy: util.FromUserID(ctx),
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(announcement); err != nil {
+		return nil, err
+	}
+
+	if err := a.AnnouncementDAL.Create(ctx, announcement); err != nil {
+		return nil, err
+	}
+	return announcement, nil
+}
+
+func (a *Announcement) Update(ctx context.Context, id string, formItem *schema.AnnouncementForm) error {
+	announcement, err := a.AnnouncementDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if announcement == nil {
+		return errors.NotFound("", "Announcement not found")
+	}
+
+	if err := formItem.FillTo(announcement); err != nil {
+		return err
+	}
+	announcement.UpdatedAt = time.Now()
+	return a.AnnouncementDAL.Update(ctx, announcement)
+}
+
+func (a *Announcement) Delete(ctx context.Context, id string) error {
+	exists, err := a.AnnouncementDAL.Exists(ctx, id)
+	if err != nil {
+		return err
+	} else if !exists {
+		return errors.NotFound("", "Announcement not found")
+	}
+	return a.AnnouncementDAL.Delete(ctx, id)
+}
+
+// UpdateStatus publishes or withdraws an announcement. Publishing pushes it to the
+// online audience right away when the publish window is already open, otherwise the
+// scheduler pushes it once the window opens.
+func (a *Announcement) UpdateStatus(ctx context.Context, id string, status string) error {
+	announcement, err := a.AnnouncementDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if announcement == nil {
+		return errors.NotFound("", "Announcement not found")
+	}
+
+	announcement.Status = status
+	announcement.UpdatedAt = time.Now()
+	if err := a.AnnouncementDAL.Update(ctx, announcement); err != nil {
+		return err
+	}
+
+	if announcement.IsActive(time.Now()) {
+		a.push(announcement, a.Broker.Broadcast)
+	}
+	return nil
+}
+
+// Start pushes the announcements whose publish window opens while the process runs.
+// Every instance pushes to its own streams, so nothing is relayed between instances.
+func (a *Announcement) Start(ctx context.Context) error {
+	interval := config.C.Util.Notification.ScheduleInterval
+	if interval <= 0 {
+		interval = 30
+	}
+	a.ticker = time.NewTicker(time.Duration(interval) * time.Second)
+	go a.pushScheduled(ctx)
+	return nil
+}
+
+func (a *Announcement) pushScheduled(ctx context.Context) {
+	last := time.Now()
+	for now := range a.ticker.C {
+		result, err := a.AnnouncementDAL.Query(ctx, schema.AnnouncementQueryParam{
+			Status:     schema.AnnouncementStatusPublished,
+			ActiveAt:   &now,
+			StartAfter: &last,
+		})
+		if err != nil {
+			logging.Context(ctx).Error("query scheduled announcements error", zap.Error(err))
+			continue
+		}
+		last = now
+
+		for _, item := range result.Data {
+			// Announcements published after their start time were pushed by UpdateStatus.
+			if item.UpdatedAt.Before(*item.StartAt) {
+				a.push(item, a.Broker.BroadcastLocal)
+			}
+		}
+	}
+}
+
+func (a *Announcement) push(announcement *schema.Announcement, broadcast func(ev sse.Event, labels ...string) int) {
+	var labels []string
+	if announcement.Scope != schema.AnnouncementScopeGlobal {
+		if labels = announcement.TargetIDs(); len(labels) == 0 {
+			return
+		}
+	}
+
+	// Only notifications carry an event ID, which streams resume from.
+	broadcast(sse.Event{
+		Event: schema.NotificationEventAnnouncement,
+		Data:  announcement,
+	}, labels...)
+}
+
+func (a *Announcement) Release(ctx context.Context) error {
+	if a.ticker != nil {
+		a.ticker.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package biz
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/encoding/json"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Keys are collected by iterating the whole namespace, so browsing stops after this many.
+// Counts are then lower bounds and flagged as truncated.
+const maxCacheBrowseKeys = 10000
+
+// Cache lets administrators inspect and clear cache namespaces.
+type Cache struct {
+	Cache cachex.Cacher
+}
+
+func (a *Cache) checkNamespace(ns string) error {
+	if !slices.Contains(config.CacheNamespaces, ns) {
+		return errors.BadRequest("", "Unknown cache namespace: %s", ns)
+	}
+	return nil
+}
+
+func (a *Cache) QueryNamespaces(ctx context.Context) ([]*schema.CacheNamespace, error) {
+	list := make([]*schema.CacheNamespace, 0, len(config.CacheNamespaces))
+	for _, ns := range config.CacheNamespaces {
+		count := 0
+		err := cachex.IterateKeys(ctx, a.Cache, ns, func(ctx context.Context, key string) bool {
+			count++
+			return count < maxCacheBrowseKeys
+		})
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &schema.CacheNamespace{Name: ns, Count: count, Truncated: count >= maxCacheBrowseKeys})
+	}
+	return list, nil
+}
+
+func (a *Cache) QueryKeys(ctx context.Context, params schema.CacheKeyQueryParam) (*schema.CacheKeyQueryResult, error) {
+	if err := a.checkNamespace(params.Namespace); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	err := cachex.IterateKeys(ctx, a.Cache, params.Namespace, func(ctx context.Context, key string) bool {
+		if strings.HasPrefix(key, params.Prefix) {
+			keys = append(keys, key)
+		}
+		return len(keys) < maxCacheBrowseKeys
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	result := &schema.CacheKeyQueryResult{
+		PageResult: &util.PaginationResult{
+			Total:     int64(len(keys)),
+			Truncated: len(keys) >= maxCacheBrowseKeys,
+			Current:   params.Current,
+			PageSize:  params.PageSize,
+		},
+	}
+	if params.OnlyCount {
+		return result, nil
+	}
+	if params.Pagination && params.PageSize > 0 {
+		start := max(params.Current-1, 0) * params.PageSize
+		end := min(start+params.PageSize, len(keys))
+		if start >= len(keys) {
+			start, end = 0, 0
+		}
+		keys = keys[start:end]
+	}
+	result.Data = keys
+	return result, nil
+}
+
+func (a *Cache) GetEntry(ctx context.Context, ns, key string) (*schema.CacheEntry, error) {
+	if err := a.checkNamespace(ns); err != nil {
+		return nil, err
+	}
+
+	raw, exists, err := a.Cache.Get(ctx, ns, key)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, errors.NotFound("", "Cache key not found")
+	}
+
+	ttl, _, err := a.Cache.TTL(ctx, ns, key)
+	if err != nil {
+		return nil, err
+	}
+
+	value, found := cachex.UnwrapLoaded(raw)
+	entry := &schema.CacheEntry{
+		Namespace: ns,
+		Key:       key,
+		TTL:       int64(ttl.Seconds()),
+		Negative:  !found,
+	}
+	entry.Value, entry.Encoding = decodeCacheValue(value)
+	return entry, nil
+}
+
+// decodeCacheValue makes a cached value readable: text as it is, msgpack as JSON and anything else as base64.
+func decodeCacheValue(value string) (string, string) {
+	if utf8.ValidString(value) {
+		return value, schema.CacheEncodingText
+	}
+
+	var v interface{}
+	if err := msgpack.Unmarshal([]byte(value), &v); err == nil {
+		if b, err := json.Marshal(v); err == nil {
+			return string(b), schema.CacheEncodingMsgpack
+		}
+	}
+	return base64.StdEncoding.EncodeToString([]byte(value)), schema.CacheEncodingBase64
+}
+
+func (a *Cache) DeleteEntry(ctx context.Context, ns, key string) error {
+	if err := a.checkNamespace(ns); err != nil {
+		return err
+	}
+
+	exists, err := a.Cache.Exists(ctx, ns, key)
+	if err != nil {
+		return err
+	} else if !exists {
+		return errors.NotFound("", "Cache key not found")
+	}
+	return a.Cache.Delete(ctx, ns, key)
+}
+
+// Backup writes a snapshot of the browsable namespaces to w as a file download. The others, e.g. app key
+// secrets, sign nonces and locks, never leave the server.
+func (a *Cache) Backup(ctx context.Context, w http.ResponseWriter) error {
+	b, ok := a.Cache.(cachex.Backuper)
+	if !ok {
+		return errors.BadRequest("", "The cache does not support backups")
+	}
+
+	name := fmt.Sprintf("cache-%s.bak", time.Now().Format("20060102150405"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	return b.Backup(ctx, w, config.CacheNamespaces...)
+}
+
+// Restore replaces the browsable namespaces of the running cache with a backup written by Backup, the
+// other namespaces are kept as they are, so that e.g. sign nonces can not be replayed.
+func (a *Cache) Restore(ctx context.Context, r io.Reader) error {
+	b, ok := a.Cache.(cachex.Backuper)
+	if !ok {
+		return errors.BadRequest("", "The cache does not support backups")
+	}
+	return b.Restore(ctx, r, config.CacheNamespaces...)
+}
+
+func (a *Cache) ClearNamespace(ctx context.Context, ns string) error {
+	if err := a.checkNamespace(ns); err != nil {
+		return err
+	}
+	return a.Cache.Clear(ctx, ns)
+}
@@ -0,0 +1,711 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/imagex"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/oss"
+	"gin-admin/pkg/tus"
+	"gin-admin/pkg/util"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type File struct {
+	Cache       cachex.Cacher
+	Trans       *util.Trans
+	OSS         oss.IClient
+	FileDAL     *dal.File
+	FileRoleDAL *dal.FileRole
+}
+
+func (a *File) Query(ctx context.Context, params schema.FileQueryParam) (*schema.FileQueryResult, error) {
+	params.Pagination = true
+	if !util.FromIsRootUser(ctx) {
+		params.VisibleUserID = util.FromUserID(ctx)
+		params.VisibleRoleIDs = util.FromUserCache(ctx).RoleIDs
+	}
+
+	result, err := a.FileDAL.Query(ctx, params, schema.FileQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.fillRoleIDs(ctx, result.Data...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *File) fillRoleIDs(ctx context.Context, files ...*schema.File) error {
+	var fileIDs []string
+	for _, item := range files {
+		if item.ACL == schema.FileACLRole {
+			fileIDs = append(fileIDs, item.ID)
+		}
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	fileRoles, err := a.FileRoleDAL.QueryByFileIDs(ctx, fileIDs)
+	if err != nil {
+		return err
+	}
+	m := fileRoles.ToFileIDMap()
+	for _, item := range files {
+		item.RoleIDs = m[item.ID]
+	}
+	return nil
+}
+
+// Get returns the file metadata after checking that the current user may read it.
+func (a *File) Get(ctx context.Context, id string) (*schema.File, error) {
+	file, err := a.FileDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if file == nil {
+		return nil, errors.NotFound("", "File not found")
+	}
+
+	if err := a.fillRoleIDs(ctx, file); err != nil {
+		return nil, err
+	}
+
+	if !util.FromIsRootUser(ctx) && !file.CanAccess(util.FromUserID(ctx), util.FromUserCache(ctx).RoleIDs) {
+		return nil, errors.Forbidden("", "No permission to access the file")
+	}
+	return file, nil
+}
+
+// Upload stores an uploaded part. Content already stored under the same SHA-256 is
+// not uploaded again; the new record points at the existing object instead.
+func (a *File) Upload(ctx context.Context, fh *multipart.FileHeader, formItem *schema.FileForm) (*schema.File, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, errors.BadRequest("", "Failed to open file: %s", err.Error())
+	}
+	defer f.Close()
+
+	return a.Store(ctx, f, fh.Filename, fh.Size, formItem)
+}
+
+// Store saves the content of reader as a file. The content type is sniffed from the
+// content, the type declared by the client is ignored.
+func (a *File) Store(ctx context.Context, reader io.ReadSeeker, name string, size int64, formItem *schema.FileForm) (*schema.File, error) {
+	if err := a.checkStorage(); err != nil {
+		return nil, err
+	}
+
+	contentType, err := oss.DetectContentType(reader, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var image []byte
+	if config.C.Util.Image.Enable && imagex.IsSupported(contentType) {
+		if data, format, err := a.normalizeImage(reader); err != nil {
+			// Animated GIFs are kept as they are, re-encoding would drop their frames.
+			if !errors.Is(err, imagex.ErrAnimated) {
+				logging.Context(ctx).Warn("normalize image error", zap.Error(err), zap.String("name", name))
+			}
+			if _, err := reader.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		} else {
+			image = data
+			reader = bytes.NewReader(data)
+			size = int64(len(data))
+			contentType = imagex.ContentType(format)
+			name = strings.TrimSuffix(name, filepath.Ext(name)) + imagex.Ext(format)
+		}
+	}
+
+	hash, err := hashReader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &schema.File{
+		ID:          util.NewXID(),
+		Name:        filepath.Base(name),
+		Size:        size,
+		ContentType: contentType,
+		Hash:        hash,
+		CreatedBy:   util.FromUserID(ctx),
+		CreatedAt:   time.Now(),
+	}
+	if err := formItem.FillTo(file); err != nil {
+		return nil, err
+	}
+
+	existing, err := a.createIfExists(ctx, file)
+	if err != nil {
+		return nil, err
+	} else if existing {
+		return file, nil
+	}
+
+	objectKey := newObjectKey(name)
+	result, err := a.OSS.PutObject(ctx, "", objectKey, reader, size, oss.PutObjectOptions{
+		ContentType: contentType,
+		UserMetadata: map[string]string{
+			"name":   file.Name,
+			"sha256": hash,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	file.ObjectKey = objectKey
+	file.URL = result.URL
+
+	if image != nil {
+		go a.generateThumbnails(logging.NewTag(context.Background(), logging.TagKeySystem), objectKey, image)
+	}
+
+	if err := a.create(ctx, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// createIfExists creates the file pointing at the object already stored with the same
+// hash, and reports false without creating anything when there is none.
+func (a *File) createIfExists(ctx context.Context, file *schema.File) (bool, error) {
+	var ok bool
+	err := a.withHashLock(ctx, file.Hash, func() error {
+		existing, err := a.FileDAL.GetByHash(ctx, file.Hash)
+		if err != nil || existing == nil {
+			return err
+		}
+
+		file.Bucket = existing.Bucket
+		file.ObjectKey = existing.ObjectKey
+		file.URL = existing.URL
+		ok = true
+		return a.create(ctx, file)
+	})
+	return ok, err
+}
+
+// withHashLock serializes the reference counting of the object stored under hash, so
+// Delete can not remove an object while a new file starts to reference it.
+func (a *File) withHashLock(ctx context.Context, hash string, fn func() error) error {
+	locker, err := cachex.NewLocker(a.Cache)
+	if err != nil {
+		return err
+	}
+	lock, err := locker.Lock(ctx, "file:"+hash, time.Minute)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := locker.Unlock(ctx, lock); err != nil {
+			logging.Context(ctx).Error("unlock file hash error", zap.Error(err), zap.String("hash", hash))
+		}
+	}()
+	return fn()
+}
+
+func (a *File) create(ctx context.Context, file *schema.File) error {
+	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.FileDAL.Create(ctx, file); err != nil {
+			return err
+		}
+		return a.createFileRoles(ctx, file)
+	})
+}
+
+// normalizeImage applies the EXIF orientation and re-encodes the image, which strips
+// EXIF metadata including GPS coordinates. It converts to the configured format if set.
+func (a *File) normalizeImage(reader io.ReadSeeker) ([]byte, string, error) {
+	cfg := config.C.Util.Image
+	format := ""
+	if cfg.Format != "" {
+		f, err := imagex.ParseFormat(cfg.Format)
+		if err != nil {
+			return nil, "", err
+		}
+		format = f
+	}
+
+	return imagex.Process(reader, imagex.Options{
+		Format:    format,
+		Quality:   cfg.Quality,
+		MaxPixels: cfg.MaxPixels,
+	})
+}
+
+func (a *File) generateThumbnails(ctx context.Context, objectKey string, image []byte) {
+	cfg := config.C.Util.Image
+	for _, size := range cfg.ThumbnailSizes {
+		var opts imagex.Options
+		if _, err := fmt.Sscanf(size, "%dx%d", &opts.Width, &opts.Height); err != nil {
+			logging.Context(ctx).Warn("invalid thumbnail size", zap.String("size", size))
+			continue
+		}
+		opts.Mode = cfg.ThumbnailMode
+		opts.Quality = cfg.Quality
+		opts.MaxPixels = cfg.MaxPixels
+
+		if _, err := a.putVariant(ctx, objectKey, bytes.NewReader(image), opts); err != nil {
+			logging.Context(ctx).Error("generate thumbnail error", zap.Error(err), zap.String("key", objectKey), zap.String("size", size))
+		}
+	}
+}
+
+func variantKey(objectKey string, opts imagex.Options) string {
+	return fmt.Sprintf("variants/%s/%dx%d_%s%s", objectKey, opts.Width, opts.Height, opts.Mode, imagex.Ext(opts.Format))
+}
+
+func (a *File) putVariant(ctx context.Context, objectKey string, reader io.ReadSeeker, opts imagex.Options) ([]byte, error) {
+	data, format, err := imagex.Process(reader, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Format = format
+
+	_, err = a.OSS.PutObject(ctx, "", variantKey(objectKey, opts), bytes.NewReader(data), int64(len(data)), oss.PutObjectOptions{
+		ContentType: imagex.ContentType(format),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Image writes a resized variant of an image file. Variants are cached in the bucket
+// next to the original object and reused by later requests. The requested size is rounded
+// up to one of the configured variant sizes, which bounds the number of cached variants.
+// Animated GIFs are written as they are.
+func (a *File) Image(ctx context.Context, id string, params schema.FileImageParam, w http.ResponseWriter) error {
+	if err := a.checkStorage(); err != nil {
+		return err
+	}
+
+	file, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if !imagex.IsSupported(file.ContentType) {
+		return errors.BadRequest("", "File is not an image")
+	}
+
+	cfg := config.C.Util.Image
+	opts := imagex.Options{
+		Width:     snapVariantSize(params.Width),
+		Height:    snapVariantSize(params.Height),
+		Mode:      params.Mode,
+		Quality:   cfg.Quality,
+		MaxPixels: cfg.MaxPixels,
+	}
+	if opts.Mode != imagex.ModeFill {
+		opts.Mode = imagex.ModeFit
+	}
+	if params.Format != "" {
+		if opts.Format, err = imagex.ParseFormat(params.Format); err != nil {
+			return errors.BadRequest("", "Unsupported image format: %s", params.Format)
+		}
+	} else if opts.Format, err = imagex.ParseFormat(strings.TrimPrefix(file.ContentType, "image/")); err != nil {
+		opts.Format = imagex.FormatPNG
+	}
+
+	key := variantKey(file.ObjectKey, opts)
+	if _, err := a.OSS.StatObject(ctx, file.Bucket, key); err == nil {
+		if reader, err := a.OSS.GetObject(ctx, file.Bucket, key); err == nil {
+			defer reader.Close()
+			writeImageHeader(w, imagex.ContentType(opts.Format))
+			_, err = io.Copy(w, reader)
+			return err
+		}
+	}
+
+	reader, err := a.OSS.GetObject(ctx, file.Bucket, file.ObjectKey)
+	if err != nil {
+		return err
+	}
+	original, err := io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil {
+		return err
+	}
+
+	data, err := a.putVariant(ctx, file.ObjectKey, bytes.NewReader(original), opts)
+	if errors.Is(err, imagex.ErrAnimated) {
+		writeImageHeader(w, file.ContentType)
+		_, err = w.Write(original)
+		return err
+	} else if errors.Is(err, imagex.ErrTooLarge) {
+		return errors.BadRequest("", "Image is too large to be resized")
+	} else if err != nil {
+		return err
+	}
+
+	writeImageHeader(w, imagex.ContentType(opts.Format))
+	_, err = w.Write(data)
+	return err
+}
+
+func writeImageHeader(w http.ResponseWriter, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+}
+
+// snapVariantSize rounds size up to the next configured variant size, or down to the
+// largest one. Zero stays zero, it derives the dimension from the aspect ratio.
+func snapVariantSize(size int) int {
+	if size <= 0 {
+		return 0
+	}
+
+	sizes := config.C.Util.Image.VariantSizes
+	if len(sizes) == 0 {
+		sizes = []int{64, 128, 256, 512, 1024, 2048}
+	}
+	sizes = slices.Sorted(slices.Values(sizes))
+	for _, v := range sizes {
+		if v >= size {
+			return v
+		}
+	}
+	return sizes[len(sizes)-1]
+}
+
+func (a *File) removeVariants(ctx context.Context, bucket, objectKey string) {
+	variants, err := a.OSS.ListObjects(ctx, bucket, "variants/"+objectKey+"/")
+	if err != nil {
+		logging.Context(ctx).Error("list image variants error", zap.Error(err), zap.String("key", objectKey))
+		return
+	}
+
+	for _, item := range variants {
+		if err := a.OSS.RemoveObject(ctx, bucket, item.Key); err != nil {
+			logging.Context(ctx).Error("remove image variant error", zap.Error(err), zap.String("key", item.Key))
+		}
+	}
+}
+
+// FinalizeUpload stores a completed resumable upload. The file name and ACL come
+// from the tus Upload-Metadata keys "filename", "acl" and "roles".
+func (a *File) FinalizeUpload(ctx context.Context, upload *tus.Upload, reader io.ReadSeeker) (string, error) {
+	formItem := &schema.FileForm{
+		ACL: upload.Metadata["acl"],
+	}
+	if roles := upload.Metadata["roles"]; roles != "" {
+		formItem.RoleIDs = strings.Split(roles, ",")
+	}
+	if err := formItem.Validate(); err != nil {
+		return "", err
+	}
+
+	name := upload.Metadata["filename"]
+	if name == "" {
+		name = upload.ID
+	}
+
+	file, err := a.Store(ctx, reader, name, upload.Size, formItem)
+	if err != nil {
+		return "", err
+	}
+	return file.ID, nil
+}
+
+func (a *File) createFileRoles(ctx context.Context, file *schema.File) error {
+	for _, roleID := range file.RoleIDs {
+		if err := a.FileRoleDAL.Create(ctx, &schema.FileRole{
+			ID:        util.NewXID(),
+			FileID:    file.ID,
+			RoleID:    roleID,
+			CreatedAt: time.Now(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *File) Update(ctx context.Context, id string, formItem *schema.FileForm) error {
+	file, err := a.getOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := formItem.FillTo(file); err != nil {
+		return err
+	}
+	file.UpdatedAt = time.Now()
+
+	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.FileDAL.Update(ctx, file, "name", "acl", "updated_at"); err != nil {
+			return err
+		}
+		if err := a.FileRoleDAL.DeleteByFileID(ctx, id); err != nil {
+			return err
+		}
+		return a.createFileRoles(ctx, file)
+	})
+}
+
+// Delete removes the record, and the stored object once no other record references it.
+func (a *File) Delete(ctx context.Context, id string) error {
+	file, err := a.getOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return a.withHashLock(ctx, file.Hash, func() error {
+		err := a.Trans.Exec(ctx, func(ctx context.Context) error {
+			if err := a.FileDAL.Delete(ctx, id); err != nil {
+				return err
+			}
+			return a.FileRoleDAL.DeleteByFileID(ctx, id)
+		})
+		if err != nil {
+			return err
+		}
+
+		if exists, err := a.FileDAL.ExistsObject(ctx, file.Bucket, file.ObjectKey); err != nil {
+			return err
+		} else if !exists && a.OSS != nil {
+			if err := a.OSS.RemoveObject(ctx, file.Bucket, file.ObjectKey); err != nil {
+				logging.Context(ctx).Error("remove object error", zap.Error(err), zap.String("key", file.ObjectKey))
+			}
+			if imagex.IsSupported(file.ContentType) {
+				a.removeVariants(ctx, file.Bucket, file.ObjectKey)
+			}
+		}
+		return nil
+	})
+}
+
+// Download writes the object to w, proxied through the API server.
+func (a *File) Download(ctx context.Context, id string, w http.ResponseWriter) error {
+	if err := a.checkStorage(); err != nil {
+		return err
+	}
+
+	file, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	reader, err := a.OSS.GetObject(ctx, file.Bucket, file.ObjectKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", file.Size))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	w.Header().Set("ETag", `"`+file.Hash+`"`)
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// PresignURL returns a temporary URL for downloading the object directly from the
+// storage backend, bypassing the API server.
+func (a *File) PresignURL(ctx context.Context, id string) (string, error) {
+	if err := a.checkStorage(); err != nil {
+		return "", err
+	}
+
+	file, err := a.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	return a.OSS.PresignedGetObject(ctx, file.Bucket, file.ObjectKey, presignExpires())
+}
+
+// PresignUpload issues a URL the client uploads the file body to with PUT. The object
+// is registered as a file by ConfirmUpload afterwards.
+func (a *File) PresignUpload(ctx context.Context, formItem *schema.FileUploadForm) (*schema.FileUploadTicket, error) {
+	if err := a.checkStorage(); err != nil {
+		return nil, err
+	}
+
+	expires := presignExpires()
+	objectKey := newObjectKey(formItem.Name)
+	url, err := a.OSS.PresignedPutObject(ctx, "", objectKey, expires)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember who may confirm the object, so keys can not be claimed by other users.
+	err = a.Cache.Set(ctx, config.CacheNSForFile, "upload:"+objectKey, util.FromUserID(ctx), expires+time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.FileUploadTicket{
+		ObjectKey: objectKey,
+		URL:       url,
+		Method:    http.MethodPut,
+		ExpiresAt: time.Now().Add(expires),
+	}, nil
+}
+
+func (a *File) ConfirmUpload(ctx context.Context, formItem *schema.FileConfirmForm) (*schema.File, error) {
+	if err := a.checkStorage(); err != nil {
+		return nil, err
+	}
+
+	cacheKey := "upload:" + formItem.ObjectKey
+	userID, ok, err := a.Cache.Get(ctx, config.CacheNSForFile, cacheKey)
+	if err != nil {
+		return nil, err
+	} else if !ok || userID != util.FromUserID(ctx) {
+		return nil, errors.BadRequest("", "Upload not found or expired")
+	}
+
+	stat, err := a.OSS.StatObject(ctx, "", formItem.ObjectKey)
+	if err != nil {
+		return nil, errors.BadRequest("", "Object has not been uploaded")
+	}
+
+	// The client uploads straight to the storage, so the size is only known from here.
+	if max := config.C.Util.File.MaxSize << 20; max > 0 && stat.Size > max {
+		if err := a.OSS.RemoveObject(ctx, "", formItem.ObjectKey); err != nil {
+			logging.Context(ctx).Error("remove oversized object error", zap.Error(err), zap.String("key", formItem.ObjectKey))
+		}
+		_ = a.Cache.Delete(ctx, config.CacheNSForFile, cacheKey)
+		return nil, errors.RequestEntityTooLarge("", "File size exceeds %d MB", config.C.Util.File.MaxSize)
+	}
+
+	reader, err := a.OSS.GetObject(ctx, "", formItem.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+	// The type stored with the object was declared by the client, sniff it instead.
+	h := sha256.New()
+	head := &headWriter{limit: 512}
+	_, err = io.Copy(io.MultiWriter(h, head), reader)
+	_ = reader.Close()
+	if err != nil {
+		return nil, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	name := formItem.Name
+	if name == "" {
+		name = formItem.ObjectKey
+	}
+	contentType, err := oss.DetectContentType(bytes.NewReader(head.buf), name)
+	if err != nil {
+		return nil, err
+	}
+	file := &schema.File{
+		ID:          util.NewXID(),
+		Name:        filepath.Base(name),
+		Size:        stat.Size,
+		ContentType: contentType,
+		Hash:        hash,
+		CreatedBy:   util.FromUserID(ctx),
+		CreatedAt:   time.Now(),
+	}
+	if err := formItem.FillTo(file); err != nil {
+		return nil, err
+	}
+
+	existing, err := a.createIfExists(ctx, file)
+	if err != nil {
+		return nil, err
+	} else if !existing {
+		file.ObjectKey = formItem.ObjectKey
+		if err := a.create(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+
+	if existing {
+		if err := a.OSS.RemoveObject(ctx, "", formItem.ObjectKey); err != nil {
+			logging.Context(ctx).Error("remove duplicated object error", zap.Error(err), zap.String("key", formItem.ObjectKey))
+		}
+	}
+	_ = a.Cache.Delete(ctx, config.CacheNSForFile, cacheKey)
+	return file, nil
+}
+
+func (a *File) getOwned(ctx context.Context, id string) (*schema.File, error) {
+	file, err := a.FileDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if file == nil {
+		return nil, errors.NotFound("", "File not found")
+	} else if !util.FromIsRootUser(ctx) && file.CreatedBy != util.FromUserID(ctx) {
+		return nil, errors.Forbidden("", "Only the uploader can modify the file")
+	}
+	return file, nil
+}
+
+func (a *File) checkStorage() error {
+	if a.OSS == nil {
+		return errors.BadRequest("", "File storage is not configured")
+	}
+	return nil
+}
+
+func newObjectKey(name string) string {
+	return fmt.Sprintf("files/%s/%s%s", time.Now().Format("20060102"), util.NewXID(), strings.ToLower(filepath.Ext(name)))
+}
+
+func presignExpires() time.Duration {
+	expires := time.Duration(config.C.Util.File.PresignExpires) * time.Second
+	if expires <= 0 {
+		expires = 15 * time.Minute
+	}
+	return expires
+}
+
+func hashReader(reader io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// headWriter keeps the first limit bytes written to it.
+type headWriter struct {
+	limit int
+	buf   []byte
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if n := w.limit - len(w.buf); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,272 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/middleware"
+	"gin-admin/pkg/util"
+	"net/netip"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ipRulePath is where the IP rules are managed, a change must not lock its author out of it.
+const ipRulePath = "/api/v1/ip-rules"
+
+// IPRule manages the IP allow and deny rules. The enabled rules are kept in memory for the IP filter
+// middleware and reloaded after every change, other instances pick changes up through the cache.
+type IPRule struct {
+	Cache         cachex.Cacher
+	IPRuleDAL     *dal.IPRule
+	active        atomic.Pointer[[]middleware.IPRule]
+	ticker        *time.Ticker
+	countryLookup func(addr netip.Addr) string
+}
+
+func (a *IPRule) Query(ctx context.Context, params schema.IPRuleQueryParam) (*schema.IPRuleQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.IPRuleDAL.Query(ctx, params, schema.IPRuleQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "path_prefix", Direction: util.ASC},
+				{Field: "created_at", Direction: util.ASC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *IPRule) Get(ctx context.Context, id string) (*schema.IPRule, error) {
+	item, err := a.IPRuleDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, errors.NotFound("", "IP rule not found")
+	}
+	return item, nil
+}
+
+// Create adds a rule, clientIP is the address of the administrator making the change.
+func (a *IPRule) Create(ctx context.Context, formItem *schema.IPRuleForm, clientIP string) (*schema.IPRule, error) {
+	if err := a.checkCountries(formItem); err != nil {
+		return nil, err
+	}
+
+	item := &schema.IPRule{
+		ID:        util.NewXID(),
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(item); err != nil {
+		return nil, err
+	}
+
+	if err := a.checkLockout(ctx, clientIP, item.ID, item); err != nil {
+		return nil, err
+	}
+	if err := a.IPRuleDAL.Create(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, a.changed(ctx)
+}
+
+func (a *IPRule) Update(ctx context.Context, id string, formItem *schema.IPRuleForm, clientIP string) error {
+	if err := a.checkCountries(formItem); err != nil {
+		return err
+	}
+
+	item, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := formItem.FillTo(item); err != nil {
+		return err
+	}
+	item.UpdatedAt = time.Now()
+	if err := a.checkLockout(ctx, clientIP, id, item); err != nil {
+		return err
+	}
+	if err := a.IPRuleDAL.Update(ctx, item); err != nil {
+		return err
+	}
+	return a.changed(ctx)
+}
+
+func (a *IPRule) Delete(ctx context.Context, id string, clientIP string) error {
+	if _, err := a.Get(ctx, id); err != nil {
+		return err
+	}
+
+	// Removing an allow rule can lock out as well, when other allow rules remain.
+	if err := a.checkLockout(ctx, clientIP, id, nil); err != nil {
+		return err
+	}
+	if err := a.IPRuleDAL.Delete(ctx, id); err != nil {
+		return err
+	}
+	return a.changed(ctx)
+}
+
+// checkCountries rejects country rules without a lookup, they would never match.
+func (a *IPRule) checkCountries(formItem *schema.IPRuleForm) error {
+	if formItem.Countries != "" && a.countryLookup == nil {
+		return errors.BadRequest("", "Countries require a geo lookup, none is configured")
+	}
+	return nil
+}
+
+// checkLockout rejects a change that would deny the client access to the IP rules, since it could not be
+// undone through the API. The change replaces the enabled rule with the ID by item, or removes it if nil.
+func (a *IPRule) checkLockout(ctx context.Context, clientIP, id string, item *schema.IPRule) error {
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return errors.BadRequest("", "Invalid client IP: %s", clientIP)
+	}
+	addr = addr.Unmap()
+
+	result, err := a.IPRuleDAL.Query(ctx, schema.IPRuleQueryParam{
+		Status: schema.IPRuleStatusEnabled,
+	})
+	if err != nil {
+		return err
+	}
+
+	items := make(schema.IPRules, 0, len(result.Data)+1)
+	for _, v := range result.Data {
+		if v.ID != id {
+			items = append(items, v)
+		}
+	}
+	if item != nil && item.Status == schema.IPRuleStatusEnabled {
+		items = append(items, item)
+	}
+
+	if !middleware.CheckIPRules(a.toMiddleware(ctx, items), ipRulePath, addr, a.Country(addr)) {
+		return errors.BadRequest("", "The change would block your IP address %s from managing IP rules", clientIP)
+	}
+	return nil
+}
+
+// changed reloads the rules and bumps the sync timestamp so that other instances reload theirs.
+func (a *IPRule) changed(ctx context.Context) error {
+	if err := a.load(ctx); err != nil {
+		return err
+	}
+	return a.Cache.Set(ctx, config.CacheNSForSync, config.CacheKeyForSyncToIPRule, fmt.Sprintf("%d", time.Now().UnixNano()))
+}
+
+// Load loads the enabled rules and starts watching changes made by other instances.
+func (a *IPRule) Load(ctx context.Context) error {
+	if err := a.load(ctx); err != nil {
+		return err
+	}
+
+	if interval := config.C.Middleware.IPFilter.AutoLoadInterval; interval > 0 {
+		a.ticker = time.NewTicker(time.Duration(interval) * time.Second)
+		go a.autoLoad(ctx)
+	}
+	return nil
+}
+
+func (a *IPRule) load(ctx context.Context) error {
+	result, err := a.IPRuleDAL.Query(ctx, schema.IPRuleQueryParam{
+		Status: schema.IPRuleStatusEnabled,
+	})
+	if err != nil {
+		return err
+	}
+
+	rules := a.toMiddleware(ctx, result.Data)
+	a.active.Store(&rules)
+	return nil
+}
+
+// denyAllPrefixes match every IPv4 and IPv6 address.
+var denyAllPrefixes = []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")}
+
+// toMiddleware converts the rules. An invalid rule denies everyone below its prefix, skipping it would
+// let in the clients an allow rule was meant to keep out.
+func (a *IPRule) toMiddleware(ctx context.Context, items schema.IPRules) []middleware.IPRule {
+	rules := make([]middleware.IPRule, 0, len(items))
+	for _, item := range items {
+		rule, err := item.ToMiddleware()
+		if err != nil {
+			logging.Context(ctx).Error("Invalid IP rule denies all clients", zap.Error(err), zap.String("rule", item.ID))
+			rule = middleware.IPRule{
+				PathPrefix: item.PathPrefix,
+				Action:     middleware.IPRuleDeny,
+				Prefixes:   denyAllPrefixes,
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (a *IPRule) autoLoad(ctx context.Context) {
+	var lastUpdated int64
+	for range a.ticker.C {
+		val, ok, err := a.Cache.Get(ctx, config.CacheNSForSync, config.CacheKeyForSyncToIPRule)
+		if err != nil {
+			logging.Context(ctx).Error("get cache error", zap.Error(err), zap.String("key", config.CacheKeyForSyncToIPRule))
+			continue
+		} else if !ok {
+			continue
+		}
+
+		updated, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			logging.Context(ctx).Error("parse cache value error", zap.Error(err), zap.String("key", config.CacheKeyForSyncToIPRule))
+			continue
+		}
+
+		if lastUpdated < updated {
+			if err := a.load(ctx); err != nil {
+				logging.Context(ctx).Error("load IP rules error", zap.Error(err))
+			} else {
+				lastUpdated = updated
+			}
+		}
+	}
+}
+
+// Rules is the middleware.IPFilterConfig Rules hook.
+func (a *IPRule) Rules() []middleware.IPRule {
+	if rules := a.active.Load(); rules != nil {
+		return *rules
+	}
+	return nil
+}
+
+// SetCountryLookup enables rules with countries, fn returns the ISO 3166-1 alpha-2 code of an address.
+func (a *IPRule) SetCountryLookup(fn func(addr netip.Addr) string) {
+	a.countryLookup = fn
+}
+
+// Country is the middleware.IPFilterConfig Country hook, it returns "" without a lookup.
+func (a *IPRule) Country(addr netip.Addr) string {
+	if a.countryLookup == nil {
+		return ""
+	}
+	return a.countryLookup(addr)
+}
+
+func (a *IPRule) Release(ctx context.Context) error {
+	if a.ticker != nil {
+		a.ticker.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,653 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/jobx"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/util"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultJobTimeout      = time.Hour
+	defaultJobOutputSize   = 64 * 1024
+	defaultJobSyncInterval = 30 * time.Second
+	jobLockTTL             = time.Minute
+	jobFireLockTTL         = 10 * time.Minute // Covers clock skew between instances
+	jobReplacePollInterval = 5 * time.Second
+)
+
+// Job manages scheduled jobs. Every instance keeps its cron entries in sync with the database,
+// and executions are guarded by locks in the cache so that each fire time runs on one instance only.
+type Job struct {
+	Cache     cachex.Cacher
+	JobDAL    *dal.Job
+	JobLogDAL *dal.JobLog
+	scheduler *jobx.Scheduler
+	locker    cachex.Locker
+	instance  string
+	interval  time.Duration
+	mu        sync.Mutex
+	running   map[string]map[string]context.CancelFunc // job ID -> log ID -> cancel
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func (a *Job) Query(ctx context.Context, params schema.JobQueryParam) (*schema.JobQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.JobDAL.Query(ctx, params, schema.JobQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range result.Data {
+		a.fillNextRunAt(item)
+	}
+	return result, nil
+}
+
+func (a *Job) Get(ctx context.Context, id string) (*schema.Job, error) {
+	item, err := a.JobDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, errors.NotFound("", "Job not found")
+	}
+	a.fillNextRunAt(item)
+	return item, nil
+}
+
+func (a *Job) fillNextRunAt(item *schema.Job) {
+	if a.scheduler == nil {
+		return
+	}
+	if next := a.scheduler.Next(item.ID); !next.IsZero() {
+		item.NextRunAt = &next
+	}
+}
+
+// Handlers returns the names of the registered job handlers.
+func (a *Job) Handlers(ctx context.Context) []string {
+	return jobx.Names()
+}
+
+func (a *Job) Create(ctx context.Context, formItem *schema.JobForm) (*schema.Job, error) {
+	item := &schema.Job{
+		ID:        util.NewXID(),
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(item); err != nil {
+		return nil, err
+	}
+
+	if err := a.JobDAL.Create(ctx, item); err != nil {
+		return nil, err
+	}
+	a.sync(ctx)
+	return item, nil
+}
+
+func (a *Job) Update(ctx context.Context, id string, formItem *schema.JobForm) error {
+	item, err := a.JobDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if item == nil {
+		return errors.NotFound("", "Job not found")
+	}
+
+	if err := formItem.FillTo(item); err != nil {
+		return err
+	}
+	item.UpdatedAt = time.Now()
+
+	if err := a.JobDAL.Update(ctx, item); err != nil {
+		return err
+	}
+	a.sync(ctx)
+	return nil
+}
+
+func (a *Job) UpdateStatus(ctx context.Context, id, status string) error {
+	item, err := a.JobDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if item == nil {
+		return errors.NotFound("", "Job not found")
+	}
+
+	item.Status = status
+	item.UpdatedAt = time.Now()
+	if err := a.JobDAL.Update(ctx, item); err != nil {
+		return err
+	}
+	a.sync(ctx)
+	return nil
+}
+
+func (a *Job) Delete(ctx context.Context, id string) error {
+	exists, err := a.JobDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if exists == nil {
+		return errors.NotFound("", "Job not found")
+	}
+
+	if err := a.JobDAL.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := a.JobLogDAL.DeleteByJobID(ctx, id); err != nil {
+		return err
+	}
+	a.sync(ctx)
+	return nil
+}
+
+// Run starts the job immediately, regardless of its status and schedule.
+func (a *Job) Run(ctx context.Context, id string) (*schema.JobLog, error) {
+	item, err := a.JobDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, errors.NotFound("", "Job not found")
+	}
+	return a.trigger(ctx, item, schema.JobTriggerManual)
+}
+
+func (a *Job) QueryLogs(ctx context.Context, params schema.JobLogQueryParam) (*schema.JobLogQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.JobLogDAL.Query(ctx, params, schema.JobLogQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "started_at", Direction: util.DESC},
+			},
+			OmitFields: []string{"output"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *Job) GetLog(ctx context.Context, jobID, id string) (*schema.JobLog, error) {
+	item, err := a.JobLogDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil || item.JobID != jobID {
+		return nil, errors.NotFound("", "Job log not found")
+	}
+	return item, nil
+}
+
+// CleanLogs is a job handler that removes execution logs older than the number of days given in args.
+func (a *Job) CleanLogs(ctx context.Context, args string, out io.Writer) error {
+	days := 30
+	if v := strings.TrimSpace(args); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid retention days: %s", v)
+		}
+		days = n
+	}
+
+	n, err := a.JobLogDAL.DeleteBefore(ctx, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "removed %d job logs older than %d days\n", n, days)
+	return nil
+}
+
+// Start prepares job execution and, if enabled, schedules the enabled jobs on this instance.
+func (a *Job) Start(ctx context.Context) error {
+	locker, err := cachex.NewLocker(a.Cache)
+	if err != nil {
+		return err
+	}
+	a.locker = locker
+	a.running = make(map[string]map[string]context.CancelFunc)
+	hostname, _ := os.Hostname()
+	a.instance = hostname + "-" + util.NewXID()
+
+	cfg := config.C.Util.Job
+	a.interval = time.Duration(cfg.SyncInterval) * time.Second
+	if a.interval <= 0 {
+		a.interval = defaultJobSyncInterval
+	}
+	if err := a.keepAlive(ctx); err != nil {
+		return err
+	}
+	a.resetStale(ctx)
+
+	if cfg.Enable {
+		a.scheduler = jobx.NewScheduler(time.Local)
+		a.sync(ctx)
+		a.scheduler.Start()
+	}
+
+	ctx = logging.NewTag(context.Background(), logging.TagKeySystem)
+	a.done = make(chan struct{})
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		// Picks up jobs changed through other instances and cleans up after stopped ones.
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.done:
+				return
+			case <-ticker.C:
+				if err := a.keepAlive(ctx); err != nil {
+					logging.Context(ctx).Error("failed to refresh job instance", zap.Error(err))
+				}
+				a.resetStale(ctx)
+				a.sync(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// keepAlive marks this instance as alive. The key expires a few sync intervals after the process stops.
+func (a *Job) keepAlive(ctx context.Context) error {
+	return a.Cache.Set(ctx, config.CacheNSForJob, "instance:"+a.instance, strconv.FormatInt(time.Now().Unix(), 10), 3*a.interval)
+}
+
+// resetStale fails the running executions of instances that are not alive anymore.
+func (a *Job) resetStale(ctx context.Context) {
+	instances, err := a.JobLogDAL.QueryRunningInstances(ctx)
+	if err != nil {
+		logging.Context(ctx).Error("failed to query running job instances", zap.Error(err))
+		return
+	}
+
+	for _, instance := range instances {
+		if instance == a.instance {
+			continue
+		}
+		if alive, err := a.Cache.Exists(ctx, config.CacheNSForJob, "instance:"+instance); err != nil {
+			logging.Context(ctx).Error("failed to check job instance", zap.Error(err), zap.String("instance", instance))
+			continue
+		} else if alive {
+			continue
+		}
+		if err := a.JobLogDAL.ResetRunning(ctx, instance); err != nil {
+			logging.Context(ctx).Error("failed to reset job logs", zap.Error(err), zap.String("instance", instance))
+		}
+	}
+}
+
+func (a *Job) sync(ctx context.Context) {
+	if a.scheduler == nil {
+		return
+	}
+
+	result, err := a.JobDAL.Query(ctx, schema.JobQueryParam{
+		Status: schema.JobStatusEnabled,
+	}, schema.JobQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "spec", "updated_at"},
+		},
+	})
+	if err != nil {
+		logging.Context(ctx).Error("failed to load jobs", zap.Error(err))
+		return
+	}
+
+	active := make(map[string]bool, len(result.Data))
+	for _, item := range result.Data {
+		id := item.ID
+		active[id] = true
+		version := strconv.FormatInt(item.UpdatedAt.UnixNano(), 10)
+		if err := a.scheduler.Set(id, item.Spec, version, func(at time.Time) { a.fire(id, at) }); err != nil {
+			logging.Context(ctx).Error("failed to schedule job", zap.Error(err), zap.String("job_id", id))
+		}
+	}
+
+	for _, id := range a.scheduler.IDs() {
+		if !active[id] {
+			a.scheduler.Remove(id)
+		}
+	}
+}
+
+// fire is called by the scheduler with the scheduled fire time. All instances compute the
+// same time from the spec, the first one to lock it runs the job.
+func (a *Job) fire(id string, at time.Time) {
+	ctx := logging.NewTag(context.Background(), logging.TagKeySystem)
+	key := fmt.Sprintf("job:fire:%s:%d", id, at.Unix())
+	if _, ok, err := a.locker.TryLock(ctx, key, jobFireLockTTL); err != nil {
+		logging.Context(ctx).Error("failed to lock job", zap.Error(err), zap.String("job_id", id))
+		return
+	} else if !ok {
+		return
+	}
+
+	item, err := a.JobDAL.Get(ctx, id)
+	if err != nil {
+		logging.Context(ctx).Error("failed to get job", zap.Error(err), zap.String("job_id", id))
+		return
+	} else if item == nil || item.Status != schema.JobStatusEnabled {
+		return
+	}
+
+	if _, err := a.trigger(ctx, item, schema.JobTriggerSchedule); err != nil {
+		logging.Context(ctx).Error("failed to trigger job", zap.Error(err), zap.String("job_id", id))
+	}
+}
+
+func (a *Job) timeout(item *schema.Job) time.Duration {
+	if item.Timeout > 0 {
+		return time.Duration(item.Timeout) * time.Second
+	} else if v := config.C.Util.Job.DefaultTimeout; v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return defaultJobTimeout
+}
+
+// trigger starts an execution in the background and returns its log. A scheduled run that
+// is not allowed by the concurrency policy is recorded as skipped, a manual one is rejected.
+func (a *Job) trigger(ctx context.Context, item *schema.Job, trigger string) (*schema.JobLog, error) {
+	handler, ok := jobx.Lookup(item.Handler)
+	if !ok {
+		return nil, errors.BadRequest("", "Job handler %s is not registered", item.Handler)
+	}
+
+	jobLog := &schema.JobLog{
+		ID:        util.NewXID(),
+		JobID:     item.ID,
+		Handler:   item.Handler,
+		Trigger:   trigger,
+		Instance:  a.instance,
+		Status:    schema.JobLogStatusRunning,
+		StartedAt: time.Now(),
+	}
+
+	var lock *cachex.Lock
+	switch item.ConcurrencyPolicy {
+	case schema.JobConcurrencyForbid:
+		var (
+			ok  bool
+			err error
+		)
+		lock, ok, err = a.locker.TryLock(ctx, "job:running:"+item.ID, jobLockTTL)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			if trigger == schema.JobTriggerManual {
+				return nil, errors.Conflict("", "Job %s is already running", item.Name)
+			}
+
+			finishedAt := jobLog.StartedAt
+			jobLog.Status = schema.JobLogStatusSkipped
+			jobLog.Error = "previous run is still in progress"
+			jobLog.FinishedAt = &finishedAt
+			return jobLog, a.JobLogDAL.Create(ctx, jobLog)
+		}
+	case schema.JobConcurrencyReplace:
+		a.cancelRunning(item.ID)
+
+		// Runs on other instances see the newer start and cancel themselves.
+		marker := strconv.FormatInt(jobLog.StartedAt.UnixNano(), 10)
+		if err := a.Cache.Set(ctx, config.CacheNSForJob, "replace:"+item.ID, marker, a.timeout(item)+jobLockTTL); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := a.JobLogDAL.Create(ctx, jobLog); err != nil {
+		if lock != nil {
+			_ = a.locker.Unlock(ctx, lock)
+		}
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(logging.NewTag(context.Background(), logging.TagKeySystem), a.timeout(item))
+	a.track(item.ID, jobLog.ID, cancel)
+
+	var unlock func()
+	if lock != nil {
+		unlock = a.holdLock(lock, cancel)
+	}
+
+	if item.ConcurrencyPolicy == schema.JobConcurrencyReplace {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.watchReplaced(runCtx, item.ID, jobLog.StartedAt, cancel)
+		}()
+	}
+
+	result := *jobLog
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer func() {
+			a.untrack(item.ID, jobLog.ID)
+			cancel()
+			if unlock != nil {
+				unlock()
+			}
+		}()
+		a.execute(runCtx, item, jobLog, handler)
+	}()
+	return &result, nil
+}
+
+// holdLock refreshes the lock until the returned function is called, which releases it.
+// The short TTL frees the job soon after an instance dies in the middle of a run. Once the lock
+// is lost, e.g. after a pause longer than the TTL, another instance may start the job, so the
+// run is cancelled.
+func (a *Job) holdLock(lock *cachex.Lock, cancel context.CancelFunc) func() {
+	ctx := logging.NewTag(context.Background(), logging.TagKeySystem)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := a.locker.Refresh(ctx, lock, jobLockTTL)
+				if errors.Is(err, cachex.ErrLockNotHeld) {
+					logging.Context(ctx).Error("job lock lost, cancel the run", zap.String("key", lock.Key))
+					cancel()
+					return
+				} else if err != nil {
+					logging.Context(ctx).Error("failed to refresh job lock", zap.Error(err), zap.String("key", lock.Key))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		if err := a.locker.Unlock(ctx, lock); err != nil {
+			logging.Context(ctx).Error("failed to unlock job", zap.Error(err), zap.String("key", lock.Key))
+		}
+	}
+}
+
+// watchReplaced cancels the run once a newer run of the job started on any instance.
+func (a *Job) watchReplaced(ctx context.Context, jobID string, startedAt time.Time, cancel context.CancelFunc) {
+	ticker := time.NewTicker(jobReplacePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			v, ok, err := a.Cache.Get(ctx, config.CacheNSForJob, "replace:"+jobID)
+			if err != nil {
+				logging.Context(ctx).Error("failed to check job replacement", zap.Error(err), zap.String("job_id", jobID))
+				continue
+			}
+			if n, _ := strconv.ParseInt(v, 10, 64); ok && n > startedAt.UnixNano() {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (a *Job) execute(ctx context.Context, item *schema.Job, jobLog *schema.JobLog, handler jobx.Handler) {
+	maxOutput := defaultJobOutputSize
+	if v := config.C.Util.Job.MaxOutputSize; v > 0 {
+		maxOutput = v * 1024
+	}
+	out := &jobOutput{limit: maxOutput}
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- handler(ctx, item.Args, out)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		// A handler that ignores the context is abandoned, the output written so far is kept.
+		err = ctx.Err()
+	}
+
+	switch {
+	case err == nil:
+		jobLog.Status = schema.JobLogStatusSucceeded
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		jobLog.Status = schema.JobLogStatusTimeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		jobLog.Status = schema.JobLogStatusCanceled
+	default:
+		jobLog.Status = schema.JobLogStatusFailed
+	}
+	if err != nil {
+		jobLog.Error = err.Error()
+		if len(jobLog.Error) > 1024 {
+			jobLog.Error = jobLog.Error[:1024]
+		}
+	}
+
+	finishedAt := time.Now()
+	jobLog.FinishedAt = &finishedAt
+	jobLog.Duration = finishedAt.Sub(jobLog.StartedAt).Milliseconds()
+	jobLog.Output = out.String()
+
+	ctx = logging.NewTag(context.Background(), logging.TagKeySystem)
+	if err := a.JobLogDAL.Update(ctx, jobLog); err != nil {
+		logging.Context(ctx).Error("failed to update job log", zap.Error(err), zap.String("job_id", item.ID))
+	}
+	if err := a.JobDAL.UpdateLastRun(ctx, item.ID, jobLog.StartedAt, jobLog.Status); err != nil {
+		logging.Context(ctx).Error("failed to update job", zap.Error(err), zap.String("job_id", item.ID))
+	}
+}
+
+func (a *Job) track(jobID, logID string, cancel context.CancelFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running[jobID] == nil {
+		a.running[jobID] = make(map[string]context.CancelFunc)
+	}
+	a.running[jobID][logID] = cancel
+}
+
+func (a *Job) untrack(jobID, logID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.running[jobID], logID)
+	if len(a.running[jobID]) == 0 {
+		delete(a.running, jobID)
+	}
+}
+
+func (a *Job) cancelRunning(jobID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, cancel := range a.running[jobID] {
+		cancel()
+	}
+}
+
+func (a *Job) Release(ctx context.Context) error {
+	if a.scheduler != nil {
+		a.scheduler.Stop()
+	}
+	if a.done != nil {
+		close(a.done)
+	}
+
+	a.mu.Lock()
+	for _, runs := range a.running {
+		for _, cancel := range runs {
+			cancel()
+		}
+	}
+	a.mu.Unlock()
+
+	a.wg.Wait()
+
+	if a.instance != "" {
+		return a.Cache.Delete(ctx, config.CacheNSForJob, "instance:"+a.instance)
+	}
+	return nil
+}
+
+// jobOutput collects handler output up to a limit. Handlers may write from several goroutines.
+type jobOutput struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *jobOutput) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if n := w.limit - w.buf.Len(); n < len(p) {
+		w.buf.Write(p[:max(n, 0)])
+		w.truncated = true
+		return len(p), nil
+	}
+	return w.buf.Write(p)
+}
+
+func (w *jobOutput) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.truncated {
+		return w.buf.String() + "\n... (truncated)"
+	}
+	return w.buf.String()
+}
@@ -0,0 +1,302 @@
+package biz
+
+import (
+	"context"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/mail"
+	"gin-admin/pkg/util"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxMailRetryInterval = time.Hour
+	// Mails in sending for longer are considered abandoned by a killed worker.
+	mailStaleTimeout = 10 * time.Minute
+)
+
+// MailOutbox queues mails in the database and delivers them with a pool of workers.
+// Failed deliveries are retried with exponential backoff until MaxAttempts is reached.
+type MailOutbox struct {
+	Trans         *util.Trans
+	MailOutboxDAL *dal.MailOutbox
+	templates     *mail.Templates
+	wakeup        chan struct{}
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+func (a *MailOutbox) Query(ctx context.Context, params schema.MailOutboxQueryParam) (*schema.MailOutboxQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.MailOutboxDAL.Query(ctx, params, schema.MailOutboxQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+			OmitFields: []string{"body"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *MailOutbox) Get(ctx context.Context, id string) (*schema.MailOutbox, error) {
+	item, err := a.MailOutboxDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, errors.NotFound("", "Mail not found")
+	}
+	return item, nil
+}
+
+// Enqueue renders the mail and stores it in the outbox for delivery.
+func (a *MailOutbox) Enqueue(ctx context.Context, formItem *schema.MailForm) (*schema.MailOutbox, error) {
+	now := time.Now()
+	item := &schema.MailOutbox{
+		ID:            util.NewXID(),
+		Status:        schema.MailStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := formItem.FillTo(item); err != nil {
+		return nil, err
+	}
+
+	if formItem.Template != "" {
+		if a.templates == nil || !a.templates.Has(formItem.Template) {
+			return nil, errors.BadRequest("", "Mail template %s not found", formItem.Template)
+		}
+
+		subject, body, err := a.templates.Render(formItem.Template, formItem.Data)
+		if err != nil {
+			return nil, errors.BadRequest("", "Failed to render mail template: %s", err.Error())
+		}
+		item.Subject = subject
+		item.Body = body
+	}
+
+	if err := a.MailOutboxDAL.Create(ctx, item); err != nil {
+		return nil, err
+	}
+	a.notify()
+	return item, nil
+}
+
+// Retry requeues a failed mail for immediate delivery.
+func (a *MailOutbox) Retry(ctx context.Context, id string) error {
+	item, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if item.Status != schema.MailStatusFailed {
+		return errors.BadRequest("", "Only failed mails can be retried")
+	}
+
+	item.Status = schema.MailStatusPending
+	item.Attempts = 0
+	item.NextAttemptAt = time.Now()
+	item.UpdatedAt = time.Now()
+	if err := a.MailOutboxDAL.Update(ctx, item); err != nil {
+		return err
+	}
+	a.notify()
+	return nil
+}
+
+func (a *MailOutbox) Delete(ctx context.Context, id string) error {
+	if _, err := a.Get(ctx, id); err != nil {
+		return err
+	}
+	return a.MailOutboxDAL.Delete(ctx, id)
+}
+
+func (a *MailOutbox) notify() {
+	if a.wakeup == nil {
+		return
+	}
+
+	select {
+	case a.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Start loads the mail templates and, if a sender is configured, starts the delivery workers.
+func (a *MailOutbox) Start(ctx context.Context) error {
+	cfg := config.C.Util.Mail
+	if cfg.TemplateDir != "" {
+		dir := filepath.Join(config.C.General.WorkDir, cfg.TemplateDir)
+		if _, err := os.Stat(dir); err == nil {
+			templates, err := mail.LoadTemplates(os.DirFS(dir))
+			if err != nil {
+				return err
+			}
+			a.templates = templates
+		}
+	}
+
+	if !mail.Enabled() {
+		return nil
+	}
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 2
+	}
+	pollInterval := time.Duration(cfg.PollInterval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = 10 * time.Second
+	}
+
+	ctx = logging.NewTag(context.Background(), logging.TagKeySystem)
+	a.wakeup = make(chan struct{}, 1)
+	a.done = make(chan struct{})
+	jobs := make(chan *schema.MailOutbox)
+
+	for i := 0; i < workers; i++ {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			for item := range jobs {
+				a.deliver(ctx, item)
+			}
+		}()
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer close(jobs)
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		a.resetStale(ctx)
+		for {
+			a.dispatch(ctx, jobs, workers)
+
+			select {
+			case <-a.done:
+				return
+			case <-ticker.C:
+				a.resetStale(ctx)
+			case <-a.wakeup:
+			}
+		}
+	}()
+	return nil
+}
+
+// resetStale requeues mails left in sending by a worker of this or another instance
+// that was killed mid-delivery.
+func (a *MailOutbox) resetStale(ctx context.Context) {
+	if err := a.MailOutboxDAL.ResetStale(ctx, time.Now().Add(-mailStaleTimeout)); err != nil {
+		logging.Context(ctx).Error("reset stale mails error", zap.Error(err))
+	}
+}
+
+func (a *MailOutbox) dispatch(ctx context.Context, jobs chan<- *schema.MailOutbox, limit int) {
+	for {
+		list, err := a.MailOutboxDAL.QueryDue(ctx, time.Now(), limit)
+		if err != nil {
+			logging.Context(ctx).Error("query due mails error", zap.Error(err))
+			return
+		}
+
+		claimed := 0
+		for _, item := range list {
+			if ok, err := a.MailOutboxDAL.Claim(ctx, item.ID); err != nil {
+				logging.Context(ctx).Error("claim mail error", zap.Error(err))
+				return
+			} else if !ok {
+				continue
+			}
+
+			claimed++
+			select {
+			case jobs <- item:
+			case <-a.done:
+				return
+			}
+		}
+
+		if len(list) < limit || claimed == 0 {
+			return
+		}
+	}
+}
+
+func (a *MailOutbox) deliver(ctx context.Context, item *schema.MailOutbox) {
+	err := mail.SendMessage(ctx, &mail.Message{
+		To:      item.ReceiverList(),
+		Cc:      item.CcList(),
+		Bcc:     item.BccList(),
+		Subject: item.Subject,
+		Body:    item.Body,
+	})
+
+	now := time.Now()
+	item.Attempts++
+	item.UpdatedAt = now
+	if err == nil {
+		item.Status = schema.MailStatusSent
+		item.SentAt = &now
+		item.LastError = ""
+	} else {
+		item.LastError = err.Error()
+		if len(item.LastError) > 1024 {
+			item.LastError = item.LastError[:1024]
+		}
+
+		maxAttempts := config.C.Util.Mail.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = 5
+		}
+		if item.Attempts >= maxAttempts {
+			item.Status = schema.MailStatusFailed
+		} else {
+			item.Status = schema.MailStatusPending
+			item.NextAttemptAt = now.Add(retryInterval(item.Attempts))
+		}
+		logging.Context(ctx).Warn("deliver mail error", zap.Error(err), zap.String("id", item.ID), zap.Int("attempts", item.Attempts))
+	}
+
+	if err := a.MailOutboxDAL.Update(ctx, item); err != nil {
+		logging.Context(ctx).Error("update mail status error", zap.Error(err), zap.String("id", item.ID))
+	}
+}
+
+// retryInterval doubles the configured base interval for every failed attempt, with up
+// to 20% jitter so that mails failing together do not retry together.
+func retryInterval(attempts int) time.Duration {
+	base := time.Duration(config.C.Util.Mail.RetryInterval) * time.Second
+	if base <= 0 {
+		base = 30 * time.Second
+	}
+
+	d := base << (attempts - 1)
+	if d <= 0 || d > maxMailRetryInterval {
+		d = maxMailRetryInterval
+	}
+	return d + time.Duration(rand.Int64N(int64(d)/5+1))
+}
+
+func (a *MailOutbox) Release(ctx context.Context) error {
+	if a.done != nil {
+		close(a.done)
+		a.wg.Wait()
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package biz
+
+import (
+	"context"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/mail"
+	"gin-admin/pkg/mail/mailtest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var smtpServer *mailtest.Server
+
+func TestMain(m *testing.M) {
+	srv, err := mailtest.NewServer()
+	if err != nil {
+		panic(err)
+	}
+	smtpServer = srv
+	mail.SetSender(&mail.SmtpSender{
+		SmtpHost: srv.Host(),
+		Port:     srv.Port(),
+		FromMail: "noreply@example.com",
+	})
+
+	code := m.Run()
+	_ = srv.Close()
+	os.Exit(code)
+}
+
+// startMailOutbox runs the outbox workers on a fresh database, polling every second.
+func startMailOutbox(t *testing.T, maxAttempts int) *MailOutbox {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(new(schema.MailOutbox)); err != nil {
+		t.Fatal(err)
+	}
+
+	config.C.Util.Mail.Workers = 1
+	config.C.Util.Mail.MaxAttempts = maxAttempts
+	config.C.Util.Mail.RetryInterval = 1
+	config.C.Util.Mail.PollInterval = 1
+
+	outbox := &MailOutbox{MailOutboxDAL: &dal.MailOutbox{DB: db}}
+	if err := outbox.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = outbox.Release(context.Background())
+		_ = sqlDB.Close()
+	})
+	return outbox
+}
+
+// waitMail polls the mail until it leaves the pending and sending states.
+func waitMail(t *testing.T, outbox *MailOutbox, id string) *schema.MailOutbox {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		item, err := outbox.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Status == schema.MailStatusSent || item.Status == schema.MailStatusFailed {
+			return item
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("mail %s was not delivered in time", id)
+	return nil
+}
+
+func TestMailOutboxDelivery(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		maxAttempts  int
+		wantStatus   string
+		wantAttempts int
+		wantSent     int
+	}{
+		{"sent at once", 0, 3, schema.MailStatusSent, 1, 1},
+		{"retried after a failure", 1, 3, schema.MailStatusSent, 2, 1},
+		{"failed after max attempts", 2, 2, schema.MailStatusFailed, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := startMailOutbox(t, tt.maxAttempts)
+			sent := len(smtpServer.Messages())
+			smtpServer.FailNext(tt.failures)
+			defer smtpServer.FailNext(0)
+
+			item, err := outbox.Enqueue(context.Background(), &schema.MailForm{
+				To:      []string{"user@example.com"},
+				Subject: "Hello",
+				Body:    "<p>Hello</p>",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			got := waitMail(t, outbox, item.ID)
+			if got.Status != tt.wantStatus || got.Attempts != tt.wantAttempts {
+				t.Fatalf("status %s after %d attempts, want %s after %d", got.Status, got.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if got.Status == schema.MailStatusFailed && got.LastError == "" {
+				t.Fatal("failed mail has no last error")
+			}
+			if tt.wantAttempts > 1 && time.Since(start) < time.Second {
+				t.Fatalf("retried after %s, before the backoff elapsed", time.Since(start))
+			}
+
+			messages := smtpServer.Messages()[sent:]
+			if len(messages) != tt.wantSent {
+				t.Fatalf("server received %d messages, want %d", len(messages), tt.wantSent)
+			}
+			if tt.wantSent > 0 && (len(messages[0].To) != 1 || messages[0].To[0] != "user@example.com") {
+				t.Fatalf("message sent to %v", messages[0].To)
+			}
+		})
+	}
+}
+
+func TestMailOutboxResetStale(t *testing.T) {
+	outbox := startMailOutbox(t, 3)
+	sent := len(smtpServer.Messages())
+
+	// A mail claimed by a worker that was killed before it finished.
+	now := time.Now()
+	item := &schema.MailOutbox{
+		ID:            "stale",
+		Receivers:     "user@example.com",
+		Subject:       "Stale",
+		Body:          "<p>Stale</p>",
+		Status:        schema.MailStatusSending,
+		NextAttemptAt: now.Add(-time.Hour),
+		CreatedAt:     now.Add(-time.Hour),
+		UpdatedAt:     now.Add(-time.Hour),
+	}
+	if err := outbox.MailOutboxDAL.Create(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+
+	got := waitMail(t, outbox, item.ID)
+	if got.Status != schema.MailStatusSent {
+		t.Fatalf("status %s, want %s", got.Status, schema.MailStatusSent)
+	}
+	if n := len(smtpServer.Messages()) - sent; n != 1 {
+		t.Fatalf("server received %d messages, want 1", n)
+	}
+}
+
+func TestRetryInterval(t *testing.T) {
+	config.C.Util.Mail.RetryInterval = 30
+
+	tests := []struct {
+		attempts int
+		min      time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{20, maxMailRetryInterval},
+		{100, maxMailRetryInterval},
+	}
+
+	for _, tt := range tests {
+		got := retryInterval(tt.attempts)
+		if got < tt.min || got > tt.min+tt.min/5 {
+			t.Errorf("retryInterval(%d) = %s, want %s plus up to 20%%", tt.attempts, got, tt.min)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package biz
+
+import (
+	"context"
+	"gin-admin/internal/config"
+	rbacdal "gin-admin/internal/mods/rbac/dal"
+	rbacschema "gin-admin/internal/mods/rbac/schema"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/mail"
+	"gin-admin/pkg/sse"
+	"gin-admin/pkg/util"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxReplayNotifications caps the notifications replayed to a resumed stream, older ones
+// are left to the notification list.
+const maxReplayNotifications = 100
+
+type Notification struct {
+	Cache           cachex.Cacher
+	Trans           *util.Trans
+	Broker          *sse.Broker
+	NotificationDAL *dal.Notification
+	UserDAL         *rbacdal.User
+	MailOutboxBIZ   *MailOutbox
+}
+
+func (a *Notification) Query(ctx context.Context, params schema.NotificationQueryParam) (*schema.NotificationQueryResult, error) {
+	params.Pagination = true
+	params.UserID = util.FromUserID(ctx)
+
+	result, err := a.NotificationDAL.Query(ctx, params, schema.NotificationQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *Notification) UnreadCount(ctx context.Context) (*schema.UnreadCount, error) {
+	count, err := a.NotificationDAL.CountUnread(ctx, util.FromUserID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &schema.UnreadCount{Count: count}, nil
+}
+
+func (a *Notification) MarkRead(ctx context.Context, ids ...string) error {
+	userID := util.FromUserID(ctx)
+	if err := a.NotificationDAL.MarkRead(ctx, userID, ids...); err != nil {
+		return err
+	}
+	a.pushUnreadCount(ctx, userID)
+	return nil
+}
+
+func (a *Notification) Delete(ctx context.Context, id string) error {
+	userID := util.FromUserID(ctx)
+	if err := a.NotificationDAL.Delete(ctx, userID, id); err != nil {
+		return err
+	}
+	a.pushUnreadCount(ctx, userID)
+	return nil
+}
+
+// Send stores a notification for every receiver and pushes it to the receivers that are online
+// on any instance. The others get it by mail when the mail fallback is enabled.
+func (a *Notification) Send(ctx context.Context, formItem *schema.NotificationForm) (schema.Notifications, error) {
+	var list schema.Notifications
+	err := a.Trans.Exec(ctx, func(ctx context.Context) error {
+		for _, userID := range formItem.UserIDs {
+			item := &schema.Notification{
+				ID:        util.NewXID(),
+				UserID:    userID,
+				Type:      formItem.Type,
+				Title:     formItem.Title,
+				Content:   formItem.Content,
+				Link:      formItem.Link,
+				CreatedAt: time.Now(),
+			}
+			if err := a.NotificationDAL.Create(ctx, item); err != nil {
+				return err
+			}
+			list = append(list, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range list {
+		online := a.online(ctx, item.UserID)
+		a.Broker.Publish(item.UserID, sse.Event{ID: item.ID, Event: schema.NotificationEventCreated, Data: item})
+		if online {
+			a.pushUnreadCount(ctx, item.UserID)
+			continue
+		}
+		if config.C.Util.Notification.MailFallback {
+			a.sendMail(ctx, item)
+		}
+	}
+	return list, nil
+}
+
+// Subscribe registers the current user on the event stream, labelled with the user's role IDs.
+// A client resuming after the event lastEventID gets the notifications created since then,
+// to be written before the live events. Those may repeat a replayed one, IDs tell them apart.
+func (a *Notification) Subscribe(ctx context.Context, lastEventID string) (*sse.Subscriber, schema.Notifications, error) {
+	userID := util.FromUserID(ctx)
+	if userID == "" {
+		return nil, nil, errors.Unauthorized("", "Unauthorized")
+	}
+
+	sub := a.Broker.Subscribe(userID, util.FromUserCache(ctx).RoleIDs...)
+	missed, err := a.queryMissed(ctx, userID, lastEventID)
+	if err != nil {
+		a.Broker.Unsubscribe(sub)
+		return nil, nil, err
+	}
+	a.KeepAlive(ctx, userID)
+	a.pushUnreadCount(ctx, userID)
+	return sub, missed, nil
+}
+
+func (a *Notification) Unsubscribe(sub *sse.Subscriber) {
+	a.Broker.Unsubscribe(sub)
+}
+
+// HeartbeatInterval is how often a stream is pinged and its presence refreshed.
+func (a *Notification) HeartbeatInterval() time.Duration {
+	interval := config.C.Util.Notification.HeartbeatInterval
+	if interval <= 0 {
+		interval = 30
+	}
+	return time.Duration(interval) * time.Second
+}
+
+// KeepAlive marks the user as connected for other instances. The mark is not removed on
+// disconnect because the user may still be connected elsewhere, it expires after two
+// missed heartbeats instead.
+func (a *Notification) KeepAlive(ctx context.Context, userID string) {
+	err := a.Cache.Set(ctx, config.CacheNSForOnline, userID, "1", 2*a.HeartbeatInterval())
+	if err != nil {
+		logging.Context(ctx).Error("set notification presence error", zap.Error(err))
+	}
+}
+
+func (a *Notification) online(ctx context.Context, userID string) bool {
+	if a.Broker.Online(userID) {
+		return true
+	}
+
+	exists, err := a.Cache.Exists(ctx, config.CacheNSForOnline, userID)
+	if err != nil {
+		logging.Context(ctx).Error("get notification presence error", zap.Error(err))
+		return false
+	}
+	return exists
+}
+
+func (a *Notification) queryMissed(ctx context.Context, userID, lastEventID string) (schema.Notifications, error) {
+	if lastEventID == "" {
+		return nil, nil
+	}
+
+	last, err := a.NotificationDAL.Get(ctx, userID, lastEventID, schema.NotificationQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "created_at"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	} else if last == nil {
+		return nil, nil
+	}
+
+	result, err := a.NotificationDAL.Query(ctx, schema.NotificationQueryParam{
+		PaginationParam: util.PaginationParam{PageSize: maxReplayNotifications},
+		UserID:          userID,
+		CreatedAfter:    &last.CreatedAt,
+	}, schema.NotificationQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.ASC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+func (a *Notification) pushUnreadCount(ctx context.Context, userID string) {
+	if !a.online(ctx, userID) {
+		return
+	}
+
+	count, err := a.NotificationDAL.CountUnread(ctx, userID)
+	if err != nil {
+		logging.Context(ctx).Error("count unread notifications error", zap.Error(err))
+		return
+	}
+	a.Broker.Publish(userID, sse.Event{Event: schema.NotificationEventUnreadCount, Data: schema.UnreadCount{Count: count}})
+}
+
+func (a *Notification) sendMail(ctx context.Context, item *schema.Notification) {
+	if !mail.Enabled() {
+		return
+	}
+
+	user, err := a.UserDAL.Get(ctx, item.UserID, rbacschema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "email"},
+		},
+	})
+	if err != nil {
+		logging.Context(ctx).Error("get notification receiver error", zap.Error(err))
+		return
+	} else if user == nil || user.Email == "" {
+		return
+	}
+
+	_, err = a.MailOutboxBIZ.Enqueue(ctx, &schema.MailForm{
+		To:       []string{user.Email},
+		Template: "notification",
+		Data: map[string]interface{}{
+			"Title":   item.Title,
+			"Content": item.Content,
+			"Link":    item.Link,
+		},
+	})
+	if err != nil {
+		logging.Context(ctx).Error("send notification mail error", zap.Error(err), zap.String("user_id", item.UserID))
+	}
+}
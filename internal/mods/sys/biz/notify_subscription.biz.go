@@ -0,0 +1,219 @@
+package biz
+
+import (
+	"context"
+	"gin-admin/internal/config"
+	rbacdal "gin-admin/internal/mods/rbac/dal"
+	rbacschema "gin-admin/internal/mods/rbac/schema"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/notify"
+	"gin-admin/pkg/util"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// NotifySubscription routes events from the notify bus to the channels users subscribed to.
+type NotifySubscription struct {
+	NotifySubscriptionDAL *dal.NotifySubscription
+	NotificationBIZ       *Notification
+	MailOutboxBIZ         *MailOutbox
+	UserDAL               *rbacdal.User
+}
+
+func (a *NotifySubscription) Query(ctx context.Context, params schema.NotifySubscriptionQueryParam) (*schema.NotifySubscriptionQueryResult, error) {
+	params.Pagination = true
+	if !util.FromIsRootUser(ctx) {
+		params.UserID = util.FromUserID(ctx)
+	}
+
+	result, err := a.NotifySubscriptionDAL.Query(ctx, params, schema.NotifySubscriptionQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *NotifySubscription) Get(ctx context.Context, id string) (*schema.NotifySubscription, error) {
+	item, err := a.NotifySubscriptionDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil || (!util.FromIsRootUser(ctx) && item.UserID != util.FromUserID(ctx)) {
+		return nil, errors.NotFound("", "Subscription not found")
+	}
+	return item, nil
+}
+
+func (a *NotifySubscription) Create(ctx context.Context, formItem *schema.NotifySubscriptionForm) (*schema.NotifySubscription, error) {
+	if err := a.checkForm(ctx, formItem); err != nil {
+		return nil, err
+	}
+
+	item := &schema.NotifySubscription{
+		ID:        util.NewXID(),
+		UserID:    util.FromUserID(ctx),
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(item); err != nil {
+		return nil, err
+	}
+
+	if err := a.NotifySubscriptionDAL.Create(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (a *NotifySubscription) Update(ctx context.Context, id string, formItem *schema.NotifySubscriptionForm) error {
+	if err := a.checkForm(ctx, formItem); err != nil {
+		return err
+	}
+
+	item, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := formItem.FillTo(item); err != nil {
+		return err
+	}
+	item.UpdatedAt = time.Now()
+	return a.NotifySubscriptionDAL.Update(ctx, item)
+}
+
+// checkForm reserves subscriptions that reach beyond the user to administrators: events of
+// all users, mails to other addresses and requests to user supplied URLs. Other users are
+// notified in-app or by mail to their own address.
+func (a *NotifySubscription) checkForm(ctx context.Context, formItem *schema.NotifySubscriptionForm) error {
+	if util.FromIsRootUser(ctx) {
+		return nil
+	}
+
+	if formItem.Scope == schema.NotifyScopeAll {
+		return errors.Forbidden("", "Only administrators can subscribe to events of all users")
+	}
+	switch {
+	case formItem.Channel == schema.NotifyChannelInApp:
+	case formItem.Channel == schema.NotifyChannelMail && formItem.Target == "":
+	default:
+		return errors.Forbidden("", "Only administrators can notify other mail addresses or URLs")
+	}
+	return nil
+}
+
+func (a *NotifySubscription) Delete(ctx context.Context, id string) error {
+	if _, err := a.Get(ctx, id); err != nil {
+		return err
+	}
+	return a.NotifySubscriptionDAL.Delete(ctx, id)
+}
+
+// Test sends a test message through the subscription channel and returns the delivery error.
+func (a *NotifySubscription) Test(ctx context.Context, id string) error {
+	item, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = a.deliver(ctx, item, notify.Event{
+		Type:       notify.EventSubscriptionTest,
+		UserID:     item.UserID,
+		Title:      "Test notification",
+		Content:    "This is a test message for subscription " + item.Name + ".",
+		OccurredAt: time.Now(),
+	})
+	if err != nil {
+		return errors.BadRequest("", "Failed to send test notification: %s", err.Error())
+	}
+	return nil
+}
+
+// Dispatch is subscribed to the notify bus and fans an event out to all matching subscriptions.
+func (a *NotifySubscription) Dispatch(ctx context.Context, ev notify.Event) {
+	ctx = logging.NewTag(ctx, logging.TagKeySystem)
+	result, err := a.NotifySubscriptionDAL.Query(ctx, schema.NotifySubscriptionQueryParam{
+		EventType: ev.Type,
+		Status:    schema.NotifySubscriptionStatusEnabled,
+	})
+	if err != nil {
+		// Logged below error level so that failures here do not publish more events.
+		logging.Context(ctx).Warn("query notify subscriptions error", zap.Error(err))
+		return
+	}
+
+	for _, item := range result.Data {
+		if item.Scope != schema.NotifyScopeAll && (ev.UserID == "" || ev.UserID != item.UserID) {
+			continue
+		}
+
+		if err := a.deliver(ctx, item, ev); err != nil {
+			logging.Context(ctx).Warn("deliver notification error", zap.Error(err),
+				zap.String("subscription_id", item.ID), zap.String("event", ev.Type))
+		}
+	}
+}
+
+func (a *NotifySubscription) deliver(ctx context.Context, item *schema.NotifySubscription, ev notify.Event) error {
+	switch item.Channel {
+	case schema.NotifyChannelInApp:
+		_, err := a.NotificationBIZ.Send(ctx, &schema.NotificationForm{
+			UserIDs: []string{item.UserID},
+			Type:    schema.NotificationTypeSystem,
+			Title:   ev.Title,
+			Content: ev.Content,
+		})
+		return err
+	case schema.NotifyChannelMail:
+		to := item.Target
+		if to == "" {
+			email, err := a.getUserEmail(ctx, item.UserID)
+			if err != nil {
+				return err
+			} else if email == "" {
+				return errors.BadRequest("", "Subscriber has no email address")
+			}
+			to = email
+		}
+
+		_, err := a.MailOutboxBIZ.Enqueue(ctx, &schema.MailForm{
+			To:       []string{to},
+			Template: "notification",
+			Data: map[string]interface{}{
+				"Title":   ev.Title,
+				"Content": ev.Content,
+			},
+		})
+		return err
+	}
+
+	notifier, err := notify.New(item.Channel, item.Target, item.Secret)
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(ctx, notify.NewMessage(ev))
+}
+
+func (a *NotifySubscription) getUserEmail(ctx context.Context, userID string) (string, error) {
+	if userID == config.C.General.Root.ID {
+		return "", nil
+	}
+
+	user, err := a.UserDAL.Get(ctx, userID, rbacschema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "email"},
+		},
+	})
+	if err != nil || user == nil {
+		return "", err
+	}
+	return user.Email, nil
+}
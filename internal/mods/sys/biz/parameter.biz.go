@@ -0,0 +1,378 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/captchax"
+	"gin-admin/pkg/encoding/json"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/util"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// parameterBinding links a parameter code to a config value that can be overridden at runtime.
+type parameterBinding struct {
+	Type     string
+	Validate func(value string) error
+	Set      func(r *config.Runtime, value string)
+}
+
+// intBinding accepts the integers from min to max.
+func intBinding(field func(r *config.Runtime) *int, min, max int) parameterBinding {
+	return parameterBinding{
+		Type: schema.ParameterTypeInt,
+		Validate: func(value string) error {
+			if i, err := strconv.Atoi(value); err != nil || i < min || i > max {
+				return fmt.Errorf("must be an integer from %d to %d", min, max)
+			}
+			return nil
+		},
+		Set: func(r *config.Runtime, value string) {
+			if i, err := strconv.Atoi(value); err == nil {
+				*field(r) = i
+			}
+		},
+	}
+}
+
+// stringBinding accepts the non-empty strings, limited to options if any.
+func stringBinding(field func(r *config.Runtime) *string, options ...string) parameterBinding {
+	return parameterBinding{
+		Type: schema.ParameterTypeString,
+		Validate: func(value string) error {
+			if value == "" {
+				return fmt.Errorf("must not be empty")
+			} else if len(options) > 0 && !slices.Contains(options, value) {
+				return fmt.Errorf("must be one of %s", strings.Join(options, ", "))
+			}
+			return nil
+		},
+		Set: func(r *config.Runtime, value string) { *field(r) = value },
+	}
+}
+
+var parameterBindings = map[string]parameterBinding{
+	"general.default_login_pwd": stringBinding(func(r *config.Runtime) *string { return &r.DefaultLoginPwd }),
+	"util.captcha.type": stringBinding(func(r *config.Runtime) *string { return &r.CaptchaType },
+		captchax.TypeDigit, captchax.TypeMath),
+	"util.captcha.length":         intBinding(func(r *config.Runtime) *int { return &r.CaptchaLength }, 4, 10),
+	"util.captcha.width":          intBinding(func(r *config.Runtime) *int { return &r.CaptchaWidth }, 50, 2000),
+	"util.captcha.height":         intBinding(func(r *config.Runtime) *int { return &r.CaptchaHeight }, 20, 1000),
+	"util.captcha.fail_threshold": intBinding(func(r *config.Runtime) *int { return &r.CaptchaFailThreshold }, 0, 1000),
+	"dictionary.user_cache_exp":   intBinding(func(r *config.Runtime) *int { return &r.UserCacheExp }, 1, 24*365),
+}
+
+type Parameter struct {
+	Cache               cachex.Cacher
+	Trans               *util.Trans
+	ParameterDAL        *dal.Parameter
+	ParameterHistoryDAL *dal.ParameterHistory
+	ticker              *time.Ticker
+	lock                sync.Mutex
+}
+
+func (a *Parameter) Query(ctx context.Context, params schema.ParameterQueryParam) (*schema.ParameterQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.ParameterDAL.Query(ctx, params, schema.ParameterQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "code", Direction: util.ASC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *Parameter) Get(ctx context.Context, id string) (*schema.Parameter, error) {
+	parameter, err := a.ParameterDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if parameter == nil {
+		return nil, errors.NotFound("", "Parameter not found")
+	}
+	return parameter, nil
+}
+
+// GetByCode returns the parameter with the given code, reading through the cache.
+func (a *Parameter) GetByCode(ctx context.Context, code string) (*schema.Parameter, error) {
+	val, ok, err := a.Cache.GetOrLoad(ctx, config.CacheNSForParameter, code,
+		time.Duration(config.C.Util.Parameter.CacheExp)*time.Second, func(ctx context.Context) (string, error) {
+			parameter, err := a.ParameterDAL.GetByCode(ctx, code)
+			if err != nil {
+				return "", err
+			} else if parameter == nil {
+				return "", cachex.ErrNotFound
+			}
+			return json.MarshalToString(parameter), nil
+		})
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errors.NotFound("", "Parameter not found")
+	}
+
+	parameter := new(schema.Parameter)
+	if err := json.Unmarshal([]byte(val), parameter); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parameter, nil
+}
+
+func (a *Parameter) Create(ctx context.Context, formItem *schema.ParameterForm) (*schema.Parameter, error) {
+	if err := a.checkBinding(formItem); err != nil {
+		return nil, err
+	}
+
+	if exists, err := a.ParameterDAL.ExistsCode(ctx, formItem.Code); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errors.BadRequest("", "Parameter code already exists")
+	}
+
+	parameter := &schema.Parameter{
+		ID:        util.NewXID(),
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(parameter); err != nil {
+		return nil, err
+	}
+
+	err := a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.ParameterDAL.Create(ctx, parameter); err != nil {
+			return err
+		}
+		return a.createHistory(ctx, parameter, schema.ParameterActionCreate, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := a.syncToInstances(ctx, parameter.Code); err != nil {
+		return nil, err
+	}
+	return parameter, a.applyIfBound(ctx, parameter.Code)
+}
+
+func (a *Parameter) Update(ctx context.Context, id string, formItem *schema.ParameterForm) error {
+	if err := a.checkBinding(formItem); err != nil {
+		return err
+	}
+
+	parameter, err := a.ParameterDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if parameter == nil {
+		return errors.NotFound("", "Parameter not found")
+	} else if parameter.Code != formItem.Code {
+		if exists, err := a.ParameterDAL.ExistsCode(ctx, formItem.Code); err != nil {
+			return err
+		} else if exists {
+			return errors.BadRequest("", "Parameter code already exists")
+		}
+	}
+
+	oldCode, oldValue := parameter.Code, parameter.Value
+	if err := formItem.FillTo(parameter); err != nil {
+		return err
+	}
+	parameter.UpdatedAt = time.Now()
+
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.ParameterDAL.Update(ctx, parameter); err != nil {
+			return err
+		}
+		return a.createHistory(ctx, parameter, schema.ParameterActionUpdate, oldValue)
+	})
+	if err != nil {
+		return err
+	}
+	if err := a.syncToInstances(ctx, oldCode, parameter.Code); err != nil {
+		return err
+	}
+	return a.applyIfBound(ctx, oldCode, parameter.Code)
+}
+
+func (a *Parameter) Delete(ctx context.Context, id string) error {
+	parameter, err := a.ParameterDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if parameter == nil {
+		return errors.NotFound("", "Parameter not found")
+	}
+
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.ParameterDAL.Delete(ctx, id); err != nil {
+			return err
+		}
+		oldValue := parameter.Value
+		parameter.Value = ""
+		return a.createHistory(ctx, parameter, schema.ParameterActionDelete, oldValue)
+	})
+	if err != nil {
+		return err
+	}
+	if err := a.syncToInstances(ctx, parameter.Code); err != nil {
+		return err
+	}
+	return a.applyIfBound(ctx, parameter.Code)
+}
+
+func (a *Parameter) QueryHistories(ctx context.Context, params schema.ParameterHistoryQueryParam) (*schema.ParameterHistoryQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.ParameterHistoryDAL.Query(ctx, params, schema.ParameterHistoryQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *Parameter) checkBinding(formItem *schema.ParameterForm) error {
+	binding, ok := parameterBindings[formItem.Code]
+	if !ok {
+		return nil
+	} else if binding.Type != formItem.Type {
+		return errors.BadRequest("", "Parameter %s must be of type %s", formItem.Code, binding.Type)
+	} else if err := binding.Validate(formItem.Value); err != nil {
+		return errors.BadRequest("", "Parameter %s %s", formItem.Code, err.Error())
+	}
+	return nil
+}
+
+func (a *Parameter) createHistory(ctx context.Context, parameter *schema.Parameter, action, oldValue string) error {
+	return a.ParameterHistoryDAL.Create(ctx, &schema.ParameterHistory{
+		ID:          util.NewXID(),
+		ParameterID: parameter.ID,
+		Code:        parameter.Code,
+		Action:      action,
+		OldValue:    oldValue,
+		NewValue:    parameter.Value,
+		Operator:    util.FromUserID(ctx),
+		CreatedAt:   time.Now(),
+	})
+}
+
+// syncToInstances drops the cached values and bumps the sync timestamp so that
+// other instances reload their config overrides. It runs after the commit, or a concurrent
+// read could cache the old value again before the change is visible.
+func (a *Parameter) syncToInstances(ctx context.Context, codes ...string) error {
+	for _, code := range codes {
+		if err := a.Cache.Delete(ctx, config.CacheNSForParameter, code); err != nil {
+			return err
+		}
+	}
+	return a.Cache.Set(ctx, config.CacheNSForSync, config.CacheKeyForSyncToParameter, fmt.Sprintf("%d", time.Now().UnixNano()))
+}
+
+// applyIfBound reloads the overrides when one of the codes is bound to a config value.
+func (a *Parameter) applyIfBound(ctx context.Context, codes ...string) error {
+	for _, code := range codes {
+		if _, ok := parameterBindings[code]; ok {
+			return a.load(ctx)
+		}
+	}
+	return nil
+}
+
+// Load applies the persisted overrides and starts watching changes made by other instances.
+func (a *Parameter) Load(ctx context.Context) error {
+	if err := a.load(ctx); err != nil {
+		return err
+	}
+
+	if interval := config.C.Util.Parameter.AutoLoadInterval; interval > 0 {
+		a.ticker = time.NewTicker(time.Duration(interval) * time.Second)
+		go a.autoLoad(ctx)
+	}
+	return nil
+}
+
+// load publishes a new runtime snapshot: the config file values with the persisted overrides applied.
+func (a *Parameter) load(ctx context.Context) error {
+	// Serializes the loads, so that an older snapshot can't be published after a newer one.
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	codes := make([]string, 0, len(parameterBindings))
+	for code := range parameterBindings {
+		codes = append(codes, code)
+	}
+
+	result, err := a.ParameterDAL.Query(ctx, schema.ParameterQueryParam{
+		InCodes: codes,
+	})
+	if err != nil {
+		return err
+	}
+
+	r := config.DefaultRuntime()
+	for _, parameter := range result.Data {
+		binding := parameterBindings[parameter.Code]
+		if parameter.Type != binding.Type {
+			logging.Context(ctx).Warn("parameter type mismatch, skip override",
+				zap.String("code", parameter.Code), zap.String("type", parameter.Type))
+			continue
+		} else if err := binding.Validate(parameter.Value); err != nil {
+			logging.Context(ctx).Warn("invalid parameter value, skip override",
+				zap.String("code", parameter.Code), zap.Error(err))
+			continue
+		}
+		binding.Set(r, parameter.Value)
+	}
+	config.SetRuntime(r)
+	return nil
+}
+
+func (a *Parameter) autoLoad(ctx context.Context) {
+	var lastUpdated int64
+	for range a.ticker.C {
+		val, ok, err := a.Cache.Get(ctx, config.CacheNSForSync, config.CacheKeyForSyncToParameter)
+		if err != nil {
+			logging.Context(ctx).Error("get cache error", zap.Error(err), zap.String("key", config.CacheKeyForSyncToParameter))
+			continue
+		} else if !ok {
+			continue
+		}
+
+		updated, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			logging.Context(ctx).Error("parse cache value error", zap.Error(err), zap.String("key", config.CacheKeyForSyncToParameter))
+			continue
+		}
+
+		if lastUpdated < updated {
+			if err := a.load(ctx); err != nil {
+				logging.Context(ctx).Error("load parameters error", zap.Error(err))
+			} else {
+				lastUpdated = updated
+			}
+		}
+	}
+}
+
+func (a *Parameter) Release(ctx context.Context) error {
+	if a.ticker != nil {
+		a.ticker.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package biz
+
+import (
+	"gin-admin/internal/mods/sys/schema"
+	"testing"
+)
+
+func TestParameterCheckBinding(t *testing.T) {
+	tests := []struct {
+		code, typ, value string
+		wantErr          bool
+	}{
+		{"general.default_login_pwd", schema.ParameterTypeString, "6351623c8cef86fefabfa7da046fc619", false},
+		{"general.default_login_pwd", schema.ParameterTypeString, "", true},
+		{"general.default_login_pwd", schema.ParameterTypeInt, "1", true},
+		{"util.captcha.type", schema.ParameterTypeString, "math", false},
+		{"util.captcha.type", schema.ParameterTypeString, "emoji", true},
+		{"util.captcha.length", schema.ParameterTypeInt, "6", false},
+		{"util.captcha.length", schema.ParameterTypeInt, "1", true},
+		{"util.captcha.length", schema.ParameterTypeInt, "six", true},
+		{"util.captcha.fail_threshold", schema.ParameterTypeInt, "0", false},
+		{"util.captcha.fail_threshold", schema.ParameterTypeInt, "-1", true},
+		{"dictionary.user_cache_exp", schema.ParameterTypeInt, "4", false},
+		{"dictionary.user_cache_exp", schema.ParameterTypeInt, "0", true},
+		{"custom.anything", schema.ParameterTypeString, "", false},
+	}
+
+	a := new(Parameter)
+	for _, tt := range tests {
+		err := a.checkBinding(&schema.ParameterForm{Code: tt.code, Type: tt.typ, Value: tt.value})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s = %q (%s): %v, want error %v", tt.code, tt.value, tt.typ, err, tt.wantErr)
+		}
+	}
+}
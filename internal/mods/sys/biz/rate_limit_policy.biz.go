@@ -0,0 +1,288 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"gin-admin/internal/config"
+	rbacdal "gin-admin/internal/mods/rbac/dal"
+	rbacschema "gin-admin/internal/mods/rbac/schema"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/middleware"
+	"gin-admin/pkg/util"
+	"slices"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type activeRateLimitPolicy struct {
+	*schema.RateLimitPolicy
+	roleID string
+}
+
+// RateLimitPolicy manages the rate limit policies and picks the one for each request. The enabled policies
+// are kept in memory and reloaded after every change, other instances pick changes up through the cache.
+// Role codes are resolved to IDs on load, so policies are also reloaded when roles change.
+type RateLimitPolicy struct {
+	Cache              cachex.Cacher
+	RateLimitPolicyDAL *dal.RateLimitPolicy
+	RoleDAL            *rbacdal.Role
+	active             atomic.Pointer[[]*activeRateLimitPolicy]
+	ticker             *time.Ticker
+}
+
+func (a *RateLimitPolicy) Query(ctx context.Context, params schema.RateLimitPolicyQueryParam) (*schema.RateLimitPolicyQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.RateLimitPolicyDAL.Query(ctx, params, schema.RateLimitPolicyQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "priority", Direction: util.DESC},
+				{Field: "path", Direction: util.ASC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *RateLimitPolicy) Get(ctx context.Context, id string) (*schema.RateLimitPolicy, error) {
+	item, err := a.RateLimitPolicyDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, errors.NotFound("", "Rate limit policy not found")
+	}
+	return item, nil
+}
+
+func (a *RateLimitPolicy) checkRoleCode(ctx context.Context, code string) error {
+	if code == "" {
+		return nil
+	}
+
+	exists, err := a.RoleDAL.ExistsCode(ctx, code)
+	if err != nil {
+		return err
+	} else if !exists {
+		return errors.BadRequest("", "Role code not found: %s", code)
+	}
+	return nil
+}
+
+func (a *RateLimitPolicy) Create(ctx context.Context, formItem *schema.RateLimitPolicyForm) (*schema.RateLimitPolicy, error) {
+	if err := a.checkRoleCode(ctx, formItem.RoleCode); err != nil {
+		return nil, err
+	}
+
+	item := &schema.RateLimitPolicy{
+		ID:        util.NewXID(),
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(item); err != nil {
+		return nil, err
+	}
+
+	if err := a.RateLimitPolicyDAL.Create(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, a.changed(ctx)
+}
+
+func (a *RateLimitPolicy) Update(ctx context.Context, id string, formItem *schema.RateLimitPolicyForm) error {
+	if err := a.checkRoleCode(ctx, formItem.RoleCode); err != nil {
+		return err
+	}
+
+	item, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := formItem.FillTo(item); err != nil {
+		return err
+	}
+	item.UpdatedAt = time.Now()
+	if err := a.RateLimitPolicyDAL.Update(ctx, item); err != nil {
+		return err
+	}
+	return a.changed(ctx)
+}
+
+func (a *RateLimitPolicy) Delete(ctx context.Context, id string) error {
+	if _, err := a.Get(ctx, id); err != nil {
+		return err
+	}
+
+	if err := a.RateLimitPolicyDAL.Delete(ctx, id); err != nil {
+		return err
+	}
+	return a.changed(ctx)
+}
+
+// changed reloads the policies and bumps the sync timestamp so that other instances reload theirs.
+func (a *RateLimitPolicy) changed(ctx context.Context) error {
+	if err := a.load(ctx); err != nil {
+		return err
+	}
+	return a.Cache.Set(ctx, config.CacheNSForSync, config.CacheKeyForSyncToRateLimit, fmt.Sprintf("%d", time.Now().UnixNano()))
+}
+
+// Load loads the enabled policies and starts watching changes made by other instances.
+func (a *RateLimitPolicy) Load(ctx context.Context) error {
+	if err := a.load(ctx); err != nil {
+		return err
+	}
+
+	if interval := config.C.Middleware.RateLimiter.AutoLoadInterval; interval > 0 {
+		a.ticker = time.NewTicker(time.Duration(interval) * time.Second)
+		go a.autoLoad(ctx)
+	}
+	return nil
+}
+
+func (a *RateLimitPolicy) load(ctx context.Context) error {
+	result, err := a.RateLimitPolicyDAL.Query(ctx, schema.RateLimitPolicyQueryParam{
+		Status: schema.RateLimitPolicyStatusEnabled,
+	})
+	if err != nil {
+		return err
+	}
+
+	var codes []string
+	for _, item := range result.Data {
+		if item.RoleCode != "" && !slices.Contains(codes, item.RoleCode) {
+			codes = append(codes, item.RoleCode)
+		}
+	}
+
+	roleIDs := make(map[string]string)
+	if len(codes) > 0 {
+		roles, err := a.RoleDAL.Query(ctx, rbacschema.RoleQueryParam{InCodes: codes}, rbacschema.RoleQueryOptions{
+			QueryOptions: util.QueryOptions{SelectFields: []string{"id", "code"}},
+		})
+		if err != nil {
+			return err
+		}
+		for _, role := range roles.Data {
+			roleIDs[role.Code] = role.ID
+		}
+	}
+
+	active := make([]*activeRateLimitPolicy, 0, len(result.Data))
+	for _, item := range result.Data {
+		policy := &activeRateLimitPolicy{RateLimitPolicy: item}
+		if item.RoleCode != "" {
+			if policy.roleID = roleIDs[item.RoleCode]; policy.roleID == "" {
+				logging.Context(ctx).Warn("Rate limit policy refers to a missing role",
+					zap.String("policy", item.ID), zap.String("role_code", item.RoleCode))
+				continue
+			}
+		}
+		active = append(active, policy)
+	}
+
+	// Higher priority first, then role specific policies before the general ones.
+	sort.SliceStable(active, func(i, j int) bool {
+		if active[i].Priority != active[j].Priority {
+			return active[i].Priority > active[j].Priority
+		}
+		return active[i].roleID != "" && active[j].roleID == ""
+	})
+	a.active.Store(&active)
+	return nil
+}
+
+// autoLoad reloads the policies when they or the roles changed, roles bump the casbin sync key.
+func (a *RateLimitPolicy) autoLoad(ctx context.Context) {
+	keys := []struct{ ns, key string }{
+		{config.CacheNSForSync, config.CacheKeyForSyncToRateLimit},
+		{config.CacheNSForRole, config.CacheKeyForSyncToCasbin},
+	}
+	lastUpdated := make(map[string]int64, len(keys))
+	for range a.ticker.C {
+		updated := make(map[string]int64, len(keys))
+		for _, k := range keys {
+			key := k.key
+			val, ok, err := a.Cache.Get(ctx, k.ns, key)
+			if err != nil {
+				logging.Context(ctx).Error("get cache error", zap.Error(err), zap.String("key", key))
+				continue
+			} else if !ok {
+				continue
+			}
+
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				logging.Context(ctx).Error("parse cache value error", zap.Error(err), zap.String("key", key))
+				continue
+			}
+			if lastUpdated[key] < n {
+				updated[key] = n
+			}
+		}
+
+		if len(updated) > 0 {
+			if err := a.load(ctx); err != nil {
+				logging.Context(ctx).Error("load rate limit policies error", zap.Error(err))
+				continue
+			}
+			for key, n := range updated {
+				lastUpdated[key] = n
+			}
+		}
+	}
+}
+
+// Match is the middleware.RateLimiterConfig Policy hook. It returns the limit of the first matching policy
+// and an identifier that keeps the counters of each policy apart.
+func (a *RateLimitPolicy) Match(c *gin.Context) (string, middleware.RateLimit, bool) {
+	active := a.active.Load()
+	if active == nil {
+		return "", middleware.RateLimit{}, false
+	}
+
+	ctx := c.Request.Context()
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	userID := util.FromUserID(ctx)
+	roleIDs := util.FromUserCache(ctx).RoleIDs
+
+	for _, p := range *active {
+		if !p.Match(c.Request.Method, route) {
+			continue
+		} else if p.roleID != "" && !slices.Contains(roleIDs, p.roleID) {
+			continue
+		}
+
+		identifier := "policy:" + p.ID + ":" + c.ClientIP()
+		if p.Scope == schema.RateLimitScopeUser && userID != "" {
+			identifier = "policy:" + p.ID + ":user:" + userID
+		}
+		return identifier, middleware.RateLimit{
+			Algorithm: p.Algorithm,
+			Limit:     p.Limit,
+			Period:    time.Duration(p.Period) * time.Second,
+		}, true
+	}
+	return "", middleware.RateLimit{}, false
+}
+
+func (a *RateLimitPolicy) Release(ctx context.Context) error {
+	if a.ticker != nil {
+		a.ticker.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+func GetAnnouncementDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.Announcement))
+}
+
+type Announcement struct {
+	DB *gorm.DB
+}
+
+func (a *Announcement) Query(ctx context.Context, params schema.AnnouncementQueryParam, opts ...schema.AnnouncementQueryOptions) (*schema.AnnouncementQueryResult, error) {
+	var opt schema.AnnouncementQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetAnnouncementDB(ctx, a.DB)
+	if v := params.LikeTitle; len(v) > 0 {
+		db = db.Where("title LIKE ?", "%"+v+"%")
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status=?", v)
+	}
+	if v := params.Scope; len(v) > 0 {
+		db = db.Where("scope=?", v)
+	}
+	if v := params.ActiveAt; v != nil {
+		db = db.Where("start_at IS NULL OR start_at<=?", v)
+		db = db.Where("end_at IS NULL OR end_at>=?", v)
+	}
+	if v := params.StartAfter; v != nil {
+		db = db.Where("start_at>?", v)
+	}
+
+	var list schema.Announcements
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.AnnouncementQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *Announcement) Get(ctx context.Context, id string, opts ...schema.AnnouncementQueryOptions) (*schema.Announcement, error) {
+	var opt schema.AnnouncementQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.Announcement)
+	ok, err := util.FindOne(ctx, GetAnnouncementDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *Announcement) Exists(ctx context.Context, id string) (bool, error) {
+	ok, err := util.Exists(ctx, GetAnnouncementDB(ctx, a.DB).Where("id=?", id))
+	return ok, errors.WithStack(err)
+}
+
+func (a *Announcement) Create(ctx context.Context, item *schema.Announcement) error {
+	result := GetAnnouncementDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *Announcement) Update(ctx context.Context, item *schema.Announcement) error {
+	result := GetAnnouncementDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *Announcement) Delete(ctx context.Context, id string) error {
+	result := GetAnnouncementDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.Announcement))
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,120 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+func GetFileDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.File))
+}
+
+type File struct {
+	DB *gorm.DB
+}
+
+func (a *File) Query(ctx context.Context, params schema.FileQueryParam, opts ...schema.FileQueryOptions) (*schema.FileQueryResult, error) {
+	var opt schema.FileQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetFileDB(ctx, a.DB)
+	if v := params.LikeName; len(v) > 0 {
+		db = db.Where("name LIKE ?", "%"+v+"%")
+	}
+	if v := params.ContentType; len(v) > 0 {
+		db = db.Where("content_type LIKE ?", v+"%")
+	}
+	if v := params.Hash; len(v) > 0 {
+		db = db.Where("hash=?", v)
+	}
+	if v := params.CreatedBy; len(v) > 0 {
+		db = db.Where("created_by=?", v)
+	}
+	if v := params.ObjectKey; len(v) > 0 {
+		db = db.Where("object_key=?", v)
+	}
+	if v := params.VisibleUserID; len(v) > 0 {
+		visible := util.GetDB(ctx, a.DB).Where("created_by=?", v).Or("acl=?", schema.FileACLPublic)
+		if roleIDs := params.VisibleRoleIDs; len(roleIDs) > 0 {
+			subQuery := GetFileRoleDB(ctx, a.DB).Select("file_id").Where("role_id IN (?)", roleIDs)
+			visible = visible.Or("acl=? AND id IN (?)", schema.FileACLRole, subQuery)
+		}
+		db = db.Where(visible)
+	}
+
+	var list schema.Files
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.FileQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *File) Get(ctx context.Context, id string, opts ...schema.FileQueryOptions) (*schema.File, error) {
+	var opt schema.FileQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.File)
+	ok, err := util.FindOne(ctx, GetFileDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *File) GetByHash(ctx context.Context, hash string, opts ...schema.FileQueryOptions) (*schema.File, error) {
+	var opt schema.FileQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.File)
+	ok, err := util.FindOne(ctx, GetFileDB(ctx, a.DB).Where("hash=?", hash), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *File) ExistsObject(ctx context.Context, bucket, objectKey string) (bool, error) {
+	ok, err := util.Exists(ctx, GetFileDB(ctx, a.DB).Where("bucket=? AND object_key=?", bucket, objectKey))
+	return ok, errors.WithStack(err)
+}
+
+func (a *File) Create(ctx context.Context, item *schema.File) error {
+	result := GetFileDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *File) Update(ctx context.Context, item *schema.File, selectFields ...string) error {
+	db := GetFileDB(ctx, a.DB).Where("id=?", item.ID)
+	if len(selectFields) > 0 {
+		db = db.Select(selectFields)
+	} else {
+		db = db.Select("*").Omit("created_at")
+	}
+	result := db.Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *File) Delete(ctx context.Context, id string) error {
+	result := GetFileDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.File))
+	return errors.WithStack(result.Error)
+}
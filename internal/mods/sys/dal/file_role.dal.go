@@ -0,0 +1,37 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+func GetFileRoleDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.FileRole))
+}
+
+type FileRole struct {
+	DB *gorm.DB
+}
+
+func (a *FileRole) QueryByFileIDs(ctx context.Context, fileIDs []string) (schema.FileRoles, error) {
+	var list schema.FileRoles
+	result := GetFileRoleDB(ctx, a.DB).Where("file_id IN (?)", fileIDs).Find(&list)
+	if err := result.Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+func (a *FileRole) Create(ctx context.Context, item *schema.FileRole) error {
+	result := GetFileRoleDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *FileRole) DeleteByFileID(ctx context.Context, fileID string) error {
+	result := GetFileRoleDB(ctx, a.DB).Where("file_id=?", fileID).Delete(new(schema.FileRole))
+	return errors.WithStack(result.Error)
+}
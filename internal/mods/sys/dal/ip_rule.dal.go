@@ -0,0 +1,82 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+func GetIPRuleDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.IPRule))
+}
+
+type IPRule struct {
+	DB *gorm.DB
+}
+
+func (a *IPRule) Query(ctx context.Context, params schema.IPRuleQueryParam, opts ...schema.IPRuleQueryOptions) (*schema.IPRuleQueryResult, error) {
+	var opt schema.IPRuleQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetIPRuleDB(ctx, a.DB)
+	if v := params.LikeName; len(v) > 0 {
+		db = db.Where("name LIKE ?", "%"+v+"%")
+	}
+	if v := params.LikePathPrefix; len(v) > 0 {
+		db = db.Where("path_prefix LIKE ?", "%"+v+"%")
+	}
+	if v := params.Action; len(v) > 0 {
+		db = db.Where("action=?", v)
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status=?", v)
+	}
+
+	var list schema.IPRules
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.IPRuleQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *IPRule) Get(ctx context.Context, id string, opts ...schema.IPRuleQueryOptions) (*schema.IPRule, error) {
+	var opt schema.IPRuleQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.IPRule)
+	ok, err := util.FindOne(ctx, GetIPRuleDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *IPRule) Create(ctx context.Context, item *schema.IPRule) error {
+	result := GetIPRuleDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *IPRule) Update(ctx context.Context, item *schema.IPRule) error {
+	result := GetIPRuleDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *IPRule) Delete(ctx context.Context, id string) error {
+	result := GetIPRuleDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.IPRule))
+	return errors.WithStack(result.Error)
+}
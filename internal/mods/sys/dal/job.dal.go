@@ -0,0 +1,90 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func GetJobDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.Job))
+}
+
+type Job struct {
+	DB *gorm.DB
+}
+
+func (a *Job) Query(ctx context.Context, params schema.JobQueryParam, opts ...schema.JobQueryOptions) (*schema.JobQueryResult, error) {
+	var opt schema.JobQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetJobDB(ctx, a.DB)
+	if v := params.LikeName; len(v) > 0 {
+		db = db.Where("name LIKE ?", "%"+v+"%")
+	}
+	if v := params.Handler; len(v) > 0 {
+		db = db.Where("handler=?", v)
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status=?", v)
+	}
+
+	var list schema.Jobs
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.JobQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *Job) Get(ctx context.Context, id string, opts ...schema.JobQueryOptions) (*schema.Job, error) {
+	var opt schema.JobQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.Job)
+	ok, err := util.FindOne(ctx, GetJobDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *Job) Create(ctx context.Context, item *schema.Job) error {
+	result := GetJobDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *Job) Update(ctx context.Context, item *schema.Job) error {
+	result := GetJobDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at", "last_run_at", "last_status").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+// UpdateLastRun records the outcome of the latest execution without touching updated_at,
+// which is used to detect schedule changes.
+func (a *Job) UpdateLastRun(ctx context.Context, id string, runAt time.Time, status string) error {
+	result := GetJobDB(ctx, a.DB).Where("id=?", id).UpdateColumns(map[string]interface{}{
+		"last_run_at": runAt,
+		"last_status": status,
+	})
+	return errors.WithStack(result.Error)
+}
+
+func (a *Job) Delete(ctx context.Context, id string) error {
+	result := GetJobDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.Job))
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,103 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func GetJobLogDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.JobLog))
+}
+
+type JobLog struct {
+	DB *gorm.DB
+}
+
+func (a *JobLog) Query(ctx context.Context, params schema.JobLogQueryParam, opts ...schema.JobLogQueryOptions) (*schema.JobLogQueryResult, error) {
+	var opt schema.JobLogQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetJobLogDB(ctx, a.DB)
+	if v := params.JobID; len(v) > 0 {
+		db = db.Where("job_id=?", v)
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status=?", v)
+	}
+
+	var list schema.JobLogs
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.JobLogQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *JobLog) Get(ctx context.Context, id string, opts ...schema.JobLogQueryOptions) (*schema.JobLog, error) {
+	var opt schema.JobLogQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.JobLog)
+	ok, err := util.FindOne(ctx, GetJobLogDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *JobLog) Create(ctx context.Context, item *schema.JobLog) error {
+	result := GetJobLogDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *JobLog) Update(ctx context.Context, item *schema.JobLog) error {
+	result := GetJobLogDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *JobLog) DeleteByJobID(ctx context.Context, jobID string) error {
+	result := GetJobLogDB(ctx, a.DB).Where("job_id=?", jobID).Delete(new(schema.JobLog))
+	return errors.WithStack(result.Error)
+}
+
+// DeleteBefore removes finished logs started before the given time and returns the number of removed rows.
+func (a *JobLog) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := GetJobLogDB(ctx, a.DB).Where("started_at<? AND status<>?", before, schema.JobLogStatusRunning).Delete(new(schema.JobLog))
+	if err := result.Error; err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return result.RowsAffected, nil
+}
+
+// QueryRunningInstances returns the instances that have logs in running.
+func (a *JobLog) QueryRunningInstances(ctx context.Context) ([]string, error) {
+	var list []string
+	result := GetJobLogDB(ctx, a.DB).Where("status=?", schema.JobLogStatusRunning).Distinct().Pluck("instance", &list)
+	if err := result.Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+// ResetRunning marks logs left in running by a stopped instance as failed.
+func (a *JobLog) ResetRunning(ctx context.Context, instance string) error {
+	result := GetJobLogDB(ctx, a.DB).Where("instance=? AND status=?", instance, schema.JobLogStatusRunning).
+		Updates(map[string]interface{}{"status": schema.JobLogStatusFailed, "error": "instance stopped during the run"})
+	return errors.WithStack(result.Error)
+}
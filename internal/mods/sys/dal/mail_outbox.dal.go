@@ -0,0 +1,108 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func GetMailOutboxDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.MailOutbox))
+}
+
+type MailOutbox struct {
+	DB *gorm.DB
+}
+
+func (a *MailOutbox) Query(ctx context.Context, params schema.MailOutboxQueryParam, opts ...schema.MailOutboxQueryOptions) (*schema.MailOutboxQueryResult, error) {
+	var opt schema.MailOutboxQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetMailOutboxDB(ctx, a.DB)
+	if v := params.Receiver; len(v) > 0 {
+		db = db.Where("receivers LIKE ?", "%"+v+"%")
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status=?", v)
+	}
+	if v := params.Template; len(v) > 0 {
+		db = db.Where("template=?", v)
+	}
+
+	var list schema.MailOutboxes
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.MailOutboxQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *MailOutbox) Get(ctx context.Context, id string, opts ...schema.MailOutboxQueryOptions) (*schema.MailOutbox, error) {
+	var opt schema.MailOutboxQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.MailOutbox)
+	ok, err := util.FindOne(ctx, GetMailOutboxDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *MailOutbox) Create(ctx context.Context, item *schema.MailOutbox) error {
+	result := GetMailOutboxDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *MailOutbox) Update(ctx context.Context, item *schema.MailOutbox) error {
+	result := GetMailOutboxDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *MailOutbox) Delete(ctx context.Context, id string) error {
+	result := GetMailOutboxDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.MailOutbox))
+	return errors.WithStack(result.Error)
+}
+
+// QueryDue returns pending mails whose next attempt is due.
+func (a *MailOutbox) QueryDue(ctx context.Context, now time.Time, limit int) (schema.MailOutboxes, error) {
+	var list schema.MailOutboxes
+	result := GetMailOutboxDB(ctx, a.DB).Where("status=? AND next_attempt_at<=?", schema.MailStatusPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&list)
+	if err := result.Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return list, nil
+}
+
+// Claim marks a pending mail as sending. It reports false if another worker or instance claimed it first.
+func (a *MailOutbox) Claim(ctx context.Context, id string) (bool, error) {
+	result := GetMailOutboxDB(ctx, a.DB).Where("id=? AND status=?", id, schema.MailStatusPending).
+		Updates(map[string]interface{}{"status": schema.MailStatusSending, "updated_at": time.Now()})
+	if err := result.Error; err != nil {
+		return false, errors.WithStack(err)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ResetStale requeues mails stuck in sending, e.g. after the process was killed mid-delivery.
+func (a *MailOutbox) ResetStale(ctx context.Context, before time.Time) error {
+	result := GetMailOutboxDB(ctx, a.DB).Where("status=? AND updated_at<?", schema.MailStatusSending, before).
+		Updates(map[string]interface{}{"status": schema.MailStatusPending, "updated_at": time.Now()})
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,93 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func GetNotificationDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.Notification))
+}
+
+type Notification struct {
+	DB *gorm.DB
+}
+
+func (a *Notification) Query(ctx context.Context, params schema.NotificationQueryParam, opts ...schema.NotificationQueryOptions) (*schema.NotificationQueryResult, error) {
+	var opt schema.NotificationQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetNotificationDB(ctx, a.DB)
+	if v := params.UserID; len(v) > 0 {
+		db = db.Where("user_id=?", v)
+	}
+	if v := params.Type; len(v) > 0 {
+		db = db.Where("type=?", v)
+	}
+	if params.Unread {
+		db = db.Where("read_at IS NULL")
+	}
+	if v := params.CreatedAfter; v != nil {
+		db = db.Where("created_at>?", v)
+	}
+
+	var list schema.Notifications
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.NotificationQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *Notification) Get(ctx context.Context, userID, id string, opts ...schema.NotificationQueryOptions) (*schema.Notification, error) {
+	var opt schema.NotificationQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.Notification)
+	ok, err := util.FindOne(ctx, GetNotificationDB(ctx, a.DB).Where("id=? AND user_id=?", id, userID), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *Notification) CountUnread(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	result := GetNotificationDB(ctx, a.DB).Where("user_id=? AND read_at IS NULL", userID).Count(&count)
+	return count, errors.WithStack(result.Error)
+}
+
+func (a *Notification) Create(ctx context.Context, item *schema.Notification) error {
+	result := GetNotificationDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *Notification) MarkRead(ctx context.Context, userID string, ids ...string) error {
+	db := GetNotificationDB(ctx, a.DB).Where("user_id=? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		db = db.Where("id IN (?)", ids)
+	}
+	result := db.Update("read_at", time.Now())
+	return errors.WithStack(result.Error)
+}
+
+func (a *Notification) Delete(ctx context.Context, userID, id string) error {
+	result := GetNotificationDB(ctx, a.DB).Where("id=? AND user_id=?", id, userID).Delete(new(schema.Notification))
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,82 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+func GetNotifySubscriptionDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.NotifySubscription))
+}
+
+type NotifySubscription struct {
+	DB *gorm.DB
+}
+
+func (a *NotifySubscription) Query(ctx context.Context, params schema.NotifySubscriptionQueryParam, opts ...schema.NotifySubscriptionQueryOptions) (*schema.NotifySubscriptionQueryResult, error) {
+	var opt schema.NotifySubscriptionQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetNotifySubscriptionDB(ctx, a.DB)
+	if v := params.UserID; len(v) > 0 {
+		db = db.Where("user_id=?", v)
+	}
+	if v := params.EventType; len(v) > 0 {
+		db = db.Where("event_type=?", v)
+	}
+	if v := params.Channel; len(v) > 0 {
+		db = db.Where("channel=?", v)
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status=?", v)
+	}
+
+	var list schema.NotifySubscriptions
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.NotifySubscriptionQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *NotifySubscription) Get(ctx context.Context, id string, opts ...schema.NotifySubscriptionQueryOptions) (*schema.NotifySubscription, error) {
+	var opt schema.NotifySubscriptionQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.NotifySubscription)
+	ok, err := util.FindOne(ctx, GetNotifySubscriptionDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *NotifySubscription) Create(ctx context.Context, item *schema.NotifySubscription) error {
+	result := GetNotifySubscriptionDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *NotifySubscription) Update(ctx context.Context, item *schema.NotifySubscription) error {
+	result := GetNotifySubscriptionDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *NotifySubscription) Delete(ctx context.Context, id string) error {
+	result := GetNotifySubscriptionDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.NotifySubscription))
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,108 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+func GetParameterDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.Parameter))
+}
+
+type Parameter struct {
+	DB *gorm.DB
+}
+
+func (a *Parameter) Query(ctx context.Context, params schema.ParameterQueryParam, opts ...schema.ParameterQueryOptions) (*schema.ParameterQueryResult, error) {
+	var opt schema.ParameterQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetParameterDB(ctx, a.DB)
+	if v := params.LikeCode; len(v) > 0 {
+		db = db.Where("code LIKE ?", "%"+v+"%")
+	}
+	if v := params.LikeName; len(v) > 0 {
+		db = db.Where("name LIKE ?", "%"+v+"%")
+	}
+	if v := params.Type; len(v) > 0 {
+		db = db.Where("type=?", v)
+	}
+	if v := params.InCodes; len(v) > 0 {
+		db = db.Where("code IN (?)", v)
+	}
+
+	var list schema.Parameters
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.ParameterQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *Parameter) Get(ctx context.Context, id string, opts ...schema.ParameterQueryOptions) (*schema.Parameter, error) {
+	var opt schema.ParameterQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.Parameter)
+	ok, err := util.FindOne(ctx, GetParameterDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *Parameter) GetByCode(ctx context.Context, code string, opts ...schema.ParameterQueryOptions) (*schema.Parameter, error) {
+	var opt schema.ParameterQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.Parameter)
+	ok, err := util.FindOne(ctx, GetParameterDB(ctx, a.DB).Where("code=?", code), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *Parameter) Exists(ctx context.Context, id string) (bool, error) {
+	ok, err := util.Exists(ctx, GetParameterDB(ctx, a.DB).Where("id=?", id))
+	return ok, errors.WithStack(err)
+}
+
+func (a *Parameter) ExistsCode(ctx context.Context, code string) (bool, error) {
+	ok, err := util.Exists(ctx, GetParameterDB(ctx, a.DB).Where("code=?", code))
+	return ok, errors.WithStack(err)
+}
+
+func (a *Parameter) Create(ctx context.Context, item *schema.Parameter) error {
+	result := GetParameterDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *Parameter) Update(ctx context.Context, item *schema.Parameter) error {
+	result := GetParameterDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *Parameter) Delete(ctx context.Context, id string) error {
+	result := GetParameterDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.Parameter))
+	return errors.WithStack(result.Error)
+}
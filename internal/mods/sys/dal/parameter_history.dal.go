@@ -0,0 +1,50 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+func GetParameterHistoryDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.ParameterHistory))
+}
+
+type ParameterHistory struct {
+	DB *gorm.DB
+}
+
+func (a *ParameterHistory) Query(ctx context.Context, params schema.ParameterHistoryQueryParam, opts ...schema.ParameterHistoryQueryOptions) (*schema.ParameterHistoryQueryResult, error) {
+	var opt schema.ParameterHistoryQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetParameterHistoryDB(ctx, a.DB)
+	if v := params.ParameterID; len(v) > 0 {
+		db = db.Where("parameter_id=?", v)
+	}
+	if v := params.Code; len(v) > 0 {
+		db = db.Where("code=?", v)
+	}
+
+	var list schema.ParameterHistories
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.ParameterHistoryQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *ParameterHistory) Create(ctx context.Context, item *schema.ParameterHistory) error {
+	result := GetParameterHistoryDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,82 @@
+package dal
+
+import (
+	"context"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+func GetRateLimitPolicyDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.RateLimitPolicy))
+}
+
+type RateLimitPolicy struct {
+	DB *gorm.DB
+}
+
+func (a *RateLimitPolicy) Query(ctx context.Context, params schema.RateLimitPolicyQueryParam, opts ...schema.RateLimitPolicyQueryOptions) (*schema.RateLimitPolicyQueryResult, error) {
+	var opt schema.RateLimitPolicyQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetRateLimitPolicyDB(ctx, a.DB)
+	if v := params.LikeName; len(v) > 0 {
+		db = db.Where("name LIKE ?", "%"+v+"%")
+	}
+	if v := params.LikePath; len(v) > 0 {
+		db = db.Where("path LIKE ?", "%"+v+"%")
+	}
+	if v := params.RoleCode; len(v) > 0 {
+		db = db.Where("role_code=?", v)
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status=?", v)
+	}
+
+	var list schema.RateLimitPolicies
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.RateLimitPolicyQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *RateLimitPolicy) Get(ctx context.Context, id string, opts ...schema.RateLimitPolicyQueryOptions) (*schema.RateLimitPolicy, error) {
+	var opt schema.RateLimitPolicyQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.RateLimitPolicy)
+	ok, err := util.FindOne(ctx, GetRateLimitPolicyDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+func (a *RateLimitPolicy) Create(ctx context.Context, item *schema.RateLimitPolicy) error {
+	result := GetRateLimitPolicyDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *RateLimitPolicy) Update(ctx context.Context, item *schema.RateLimitPolicy) error {
+	result := GetRateLimitPolicyDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *RateLimitPolicy) Delete(ctx context.Context, id string) error {
+	result := GetRateLimitPolicyDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.RateLimitPolicy))
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,245 @@
+package sys
+
+import (
+	"context"
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/api"
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/schema"
+	"gin-admin/pkg/jobx"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/mail"
+	"gin-admin/pkg/notify"
+	"gin-admin/pkg/oss"
+	"gin-admin/pkg/sse"
+	"gin-admin/pkg/tus"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
+)
+
+type SYS struct {
+	DB              *gorm.DB
+	ParameterAPI    *api.Parameter
+	ParameterBIZ    *biz.Parameter
+	AnnouncementAPI *api.Announcement
+	AnnouncementBIZ *biz.Announcement
+	Broker          *sse.Broker
+	NotificationAPI *api.Notification
+	MailOutboxAPI   *api.MailOutbox
+	MailOutboxBIZ   *biz.MailOutbox
+	SubscriptionAPI *api.NotifySubscription
+	SubscriptionBIZ *biz.NotifySubscription
+	JobAPI          *api.Job
+	JobBIZ          *biz.Job
+	CacheAPI        *api.Cache
+	RateLimitAPI    *api.RateLimitPolicy
+	RateLimitBIZ    *biz.RateLimitPolicy
+	IPRuleAPI       *api.IPRule
+	IPRuleBIZ       *biz.IPRule
+	FileAPI         *api.File
+	OSS             oss.IClient
+	TusHandler      *tus.Handler
+}
+
+func (a *SYS) AutoMigrate(ctx context.Context) error {
+	return a.DB.AutoMigrate(
+		new(schema.Parameter),
+		new(schema.ParameterHistory),
+		new(schema.Announcement),
+		new(schema.Notification),
+		new(schema.MailOutbox),
+		new(schema.NotifySubscription),
+		new(schema.Job),
+		new(schema.JobLog),
+		new(schema.File),
+		new(schema.FileRole),
+		new(schema.RateLimitPolicy),
+		new(schema.IPRule),
+	)
+}
+
+func (a *SYS) Init(ctx context.Context) error {
+	if config.C.Storage.DB.AutoMigrate {
+		if err := a.AutoMigrate(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := a.ParameterBIZ.Load(ctx); err != nil {
+		return err
+	}
+	if err := a.RateLimitBIZ.Load(ctx); err != nil {
+		return err
+	}
+	if err := a.IPRuleBIZ.Load(ctx); err != nil {
+		return err
+	}
+
+	if cfg := config.C.Util.Mail; cfg.Enable {
+		mail.SetSender(&mail.SmtpSender{
+			SmtpHost: cfg.SmtpHost,
+			Port:     cfg.Port,
+			FromName: cfg.FromName,
+			FromMail: cfg.FromMail,
+			UserName: cfg.UserName,
+			AuthCode: cfg.AuthCode,
+		})
+	}
+	if err := a.MailOutboxBIZ.Start(ctx); err != nil {
+		return err
+	}
+	if err := a.AnnouncementBIZ.Start(ctx); err != nil {
+		return err
+	}
+
+	jobx.Register("sys.clean_job_logs", a.JobBIZ.CleanLogs)
+	if err := a.JobBIZ.Start(ctx); err != nil {
+		return err
+	}
+
+	notify.Init(0)
+	notify.Subscribe(a.SubscriptionBIZ.Dispatch)
+	logging.AddEntryHook(notify.LogHook(zapcore.ErrorLevel, time.Minute))
+	return nil
+}
+
+func (a *SYS) RegisterV1Routers(ctx context.Context, v1 *gin.RouterGroup) error {
+	parameter := v1.Group("parameters")
+	{
+		parameter.GET("", a.ParameterAPI.Query)
+		parameter.GET(":id", a.ParameterAPI.Get)
+		parameter.GET("code/:code", a.ParameterAPI.GetByCode)
+		parameter.GET(":id/histories", a.ParameterAPI.QueryHistories)
+		parameter.POST("", a.ParameterAPI.Create)
+		parameter.PUT(":id", a.ParameterAPI.Update)
+		parameter.DELETE(":id", a.ParameterAPI.Delete)
+	}
+	announcement := v1.Group("announcements")
+	{
+		announcement.GET("", a.AnnouncementAPI.Query)
+		announcement.GET(":id", a.AnnouncementAPI.Get)
+		announcement.POST("", a.AnnouncementAPI.Create)
+		announcement.PUT(":id", a.AnnouncementAPI.Update)
+		announcement.DELETE(":id", a.AnnouncementAPI.Delete)
+		announcement.POST(":id/publish", a.AnnouncementAPI.Publish)
+		announcement.POST(":id/withdraw", a.AnnouncementAPI.Withdraw)
+	}
+	notification := v1.Group("notifications")
+	{
+		notification.POST("", a.NotificationAPI.Send)
+	}
+	file := v1.Group("files")
+	{
+		file.GET("", a.FileAPI.Query)
+		file.GET(":id", a.FileAPI.Get)
+		file.GET(":id/download", a.FileAPI.Download)
+		file.GET(":id/presign", a.FileAPI.PresignURL)
+		file.GET(":id/image", a.FileAPI.Image)
+		file.POST("", a.FileAPI.Upload)
+		file.POST("presign", a.FileAPI.PresignUpload)
+		file.POST("confirm", a.FileAPI.ConfirmUpload)
+		file.PUT(":id", a.FileAPI.Update)
+		file.DELETE(":id", a.FileAPI.Delete)
+	}
+	a.TusHandler.Register(v1.Group("uploads"))
+	if local, ok := a.OSS.(*oss.LocalClient); ok {
+		v1.Match([]string{http.MethodGet, http.MethodHead, http.MethodPut}, "oss/*key", local.Handler("key"))
+	}
+	mailOutbox := v1.Group("mails")
+	{
+		mailOutbox.GET("", a.MailOutboxAPI.Query)
+		mailOutbox.GET(":id", a.MailOutboxAPI.Get)
+		mailOutbox.POST("", a.MailOutboxAPI.Send)
+		mailOutbox.POST(":id/retry", a.MailOutboxAPI.Retry)
+		mailOutbox.DELETE(":id", a.MailOutboxAPI.Delete)
+	}
+	subscription := v1.Group("notify-subscriptions")
+	{
+		subscription.GET("", a.SubscriptionAPI.Query)
+		subscription.GET("event-types", a.SubscriptionAPI.EventTypes)
+		subscription.GET(":id", a.SubscriptionAPI.Get)
+		subscription.POST("", a.SubscriptionAPI.Create)
+		subscription.PUT(":id", a.SubscriptionAPI.Update)
+		subscription.DELETE(":id", a.SubscriptionAPI.Delete)
+		subscription.POST(":id/test", a.SubscriptionAPI.Test)
+	}
+	job := v1.Group("jobs")
+	{
+		job.GET("", a.JobAPI.Query)
+		job.GET("handlers", a.JobAPI.Handlers)
+		job.GET(":id", a.JobAPI.Get)
+		job.GET(":id/logs", a.JobAPI.QueryLogs)
+		job.GET(":id/logs/:logId", a.JobAPI.GetLog)
+		job.POST("", a.JobAPI.Create)
+		job.POST(":id/run", a.JobAPI.Run)
+		job.POST(":id/enable", a.JobAPI.Enable)
+		job.POST(":id/disable", a.JobAPI.Disable)
+		job.PUT(":id", a.JobAPI.Update)
+		job.DELETE(":id", a.JobAPI.Delete)
+	}
+	cache := v1.Group("caches")
+	{
+		cache.GET("", a.CacheAPI.QueryNamespaces)
+		cache.GET("backup", a.CacheAPI.Backup)
+		cache.POST("restore", a.CacheAPI.Restore)
+		cache.GET(":ns/keys", a.CacheAPI.QueryKeys)
+		cache.GET(":ns/entry", a.CacheAPI.GetEntry)
+		cache.DELETE(":ns/entry", a.CacheAPI.DeleteEntry)
+		cache.DELETE(":ns", a.CacheAPI.ClearNamespace)
+	}
+	rateLimit := v1.Group("rate-limit-policies")
+	{
+		rateLimit.GET("", a.RateLimitAPI.Query)
+		rateLimit.GET(":id", a.RateLimitAPI.Get)
+		rateLimit.POST("", a.RateLimitAPI.Create)
+		rateLimit.PUT(":id", a.RateLimitAPI.Update)
+		rateLimit.DELETE(":id", a.RateLimitAPI.Delete)
+	}
+	ipRule := v1.Group("ip-rules")
+	{
+		ipRule.GET("", a.IPRuleAPI.Query)
+		ipRule.GET(":id", a.IPRuleAPI.Get)
+		ipRule.POST("", a.IPRuleAPI.Create)
+		ipRule.PUT(":id", a.IPRuleAPI.Update)
+		ipRule.DELETE(":id", a.IPRuleAPI.Delete)
+	}
+	current := v1.Group("current")
+	{
+		current.GET("announcements", a.AnnouncementAPI.QueryActive)
+		current.GET("notifications", a.NotificationAPI.Query)
+		current.GET("notifications/unread-count", a.NotificationAPI.UnreadCount)
+		current.GET("notifications/stream", a.NotificationAPI.Stream)
+		current.PUT("notifications/read", a.NotificationAPI.MarkAllRead)
+		current.PUT("notifications/:id/read", a.NotificationAPI.MarkRead)
+		current.DELETE("notifications/:id", a.NotificationAPI.Delete)
+	}
+	return nil
+}
+
+func (a *SYS) Release(ctx context.Context) error {
+	notify.Close()
+	a.TusHandler.Release()
+	if err := a.JobBIZ.Release(ctx); err != nil {
+		return err
+	}
+	if err := a.MailOutboxBIZ.Release(ctx); err != nil {
+		return err
+	}
+	if err := a.ParameterBIZ.Release(ctx); err != nil {
+		return err
+	}
+	if err := a.RateLimitBIZ.Release(ctx); err != nil {
+		return err
+	}
+	if err := a.IPRuleBIZ.Release(ctx); err != nil {
+		return err
+	}
+	if err := a.AnnouncementBIZ.Release(ctx); err != nil {
+		return err
+	}
+	return a.Broker.Close()
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"strings"
+	"time"
+)
+
+const (
+	AnnouncementScopeGlobal = "global"
+	AnnouncementScopeRole   = "role"
+
+	AnnouncementStatusDraft     = "draft"
+	AnnouncementStatusPublished = "published"
+)
+
+type Announcement struct {
+	ID        string
+	Title     string
+	Content   string
+	Scope     string
+	Targets   string
+	Status    string
+	StartAt   *time.Time
+	EndAt     *time.Time
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (a *Announcement) TableName() string {
+	return config.C.FormatTableName("announcement")
+}
+
+func (a *Announcement) TargetIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(a.Targets, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// IsActive reports whether the announcement is published and inside its publish window.
+func (a *Announcement) IsActive(now time.Time) bool {
+	if a.Status != AnnouncementStatusPublished {
+		return false
+	}
+	if a.StartAt != nil && now.Before(*a.StartAt) {
+		return false
+	}
+	if a.EndAt != nil && now.After(*a.EndAt) {
+		return false
+	}
+	return true
+}
+
+// IsVisibleTo reports whether a user holding roleIDs is in the announcement's audience.
+func (a *Announcement) IsVisibleTo(roleIDs []string) bool {
+	switch a.Scope {
+	case AnnouncementScopeGlobal:
+		return true
+	case AnnouncementScopeRole:
+		for _, target := range a.TargetIDs() {
+			for _, roleID := range roleIDs {
+				if target == roleID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+type AnnouncementQueryParam struct {
+	util.PaginationParam
+	LikeTitle  string     `form:"title"`
+	Status     string     `form:"status"`
+	Scope      string     `form:"scope"`
+	ActiveAt   *time.Time `form:"-"`
+	StartAfter *time.Time `form:"-"`
+}
+
+type AnnouncementQueryOptions struct {
+	util.QueryOptions
+}
+
+type AnnouncementQueryResult struct {
+	Data       Announcements
+	PageResult *util.PaginationResult
+}
+
+type Announcements []*Announcement
+
+type AnnouncementForm struct {
+	Title   string
+	Content string
+	Scope   string
+	Targets []string
+	StartAt *time.Time
+	EndAt   *time.Time
+}
+
+func (a *AnnouncementForm) Validate() error {
+	if strings.TrimSpace(a.Title) == "" {
+		return errors.BadRequest("", "Title is required")
+	}
+
+	switch a.Scope {
+	case AnnouncementScopeGlobal:
+	case AnnouncementScopeRole:
+		if len(a.Targets) == 0 {
+			return errors.BadRequest("", "Targets are required for %s scope", a.Scope)
+		}
+	default:
+		return errors.BadRequest("", "Invalid scope: %s", a.Scope)
+	}
+
+	if a.StartAt != nil && a.EndAt != nil && a.EndAt.Before(*a.StartAt) {
+		return errors.BadRequest("", "End time must be after start time")
+	}
+	return nil
+}
+
+func (a *AnnouncementForm) FillTo(announcement *Announcement) error {
+	announcement.Title = a.Title
+	announcement.Content = a.Content
+	announcement.Scope = a.Scope
+	announcement.Targets = strings.Join(a.Targets, ",")
+	announcement.StartAt = a.StartAt
+	announcement.EndAt = a.EndAt
+	return nil
+}
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"gin-admin/pkg/util"
+)
+
+// Encodings of a cache entry value as shown in the cache browser.
+const (
+	CacheEncodingText    = "text"
+	CacheEncodingMsgpack = "msgpack" // Decoded and shown as JSON
+	CacheEncodingBase64  = "base64"
+)
+
+type CacheNamespace struct {
+	Name      string
+	Count     int  // Number of keys, capped at the browse limit
+	Truncated bool // Count reached the browse limit, the namespace may hold more keys
+}
+
+type CacheKeyQueryParam struct {
+	util.PaginationParam
+	Namespace string `form:"-"`
+	Prefix    string `form:"prefix"`
+}
+
+type CacheKeyQueryResult struct {
+	Data       []string
+	PageResult *util.PaginationResult
+}
+
+type CacheEntry struct {
+	Namespace string
+	Key       string
+	Value     string
+	Encoding  string
+	TTL       int64 // Seconds until the entry expires, 0 for no expiry
+	Negative  bool  // A cached "not found" stored by GetOrLoad
+}
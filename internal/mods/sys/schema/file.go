@@ -0,0 +1,189 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"time"
+)
+
+const (
+	FileACLPrivate = "private"
+	FileACLPublic  = "public"
+	FileACLRole    = "role"
+)
+
+type File struct {
+	ID          string
+	Name        string
+	Size        int64
+	ContentType string
+	Hash        string
+	Bucket      string
+	ObjectKey   string
+	URL         string
+	ACL         string
+	CreatedBy   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	RoleIDs     []string `gorm:"-"`
+}
+
+func (a *File) TableName() string {
+	return config.C.FormatTableName("file")
+}
+
+// CanAccess reports whether the user may read the file: the uploader always can,
+// everyone else follows the file's ACL.
+func (a *File) CanAccess(userID string, roleIDs []string) bool {
+	if a.CreatedBy == userID {
+		return true
+	}
+
+	switch a.ACL {
+	case FileACLPublic:
+		return true
+	case FileACLRole:
+		for _, id := range a.RoleIDs {
+			for _, roleID := range roleIDs {
+				if id == roleID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+type FileQueryParam struct {
+	util.PaginationParam
+	LikeName       string   `form:"name"`
+	ContentType    string   `form:"contentType"`
+	Hash           string   `form:"hash"`
+	CreatedBy      string   `form:"-"`
+	VisibleUserID  string   `form:"-"`
+	VisibleRoleIDs []string `form:"-"`
+	ObjectKey      string   `form:"-"`
+}
+
+type FileQueryOptions struct {
+	util.QueryOptions
+}
+
+type FileQueryResult struct {
+	Data       Files
+	PageResult *util.PaginationResult
+}
+
+type Files []*File
+
+type FileForm struct {
+	Name    string
+	ACL     string
+	RoleIDs []string
+}
+
+func (a *FileForm) Validate() error {
+	switch a.ACL {
+	case "":
+		a.ACL = FileACLPrivate
+	case FileACLPrivate, FileACLPublic:
+	case FileACLRole:
+		if len(a.RoleIDs) == 0 {
+			return errors.BadRequest("", "Roles are required for role ACL")
+		}
+	default:
+		return errors.BadRequest("", "Invalid ACL: %s", a.ACL)
+	}
+	return nil
+}
+
+func (a *FileForm) FillTo(file *File) error {
+	if a.Name != "" {
+		file.Name = a.Name
+	}
+	file.ACL = a.ACL
+	file.RoleIDs = nil
+	if a.ACL == FileACLRole {
+		file.RoleIDs = a.RoleIDs
+	}
+	return nil
+}
+
+type FileImageParam struct {
+	Width  int    `form:"w"`
+	Height int    `form:"h"`
+	Mode   string `form:"mode"`   // fit/fill
+	Format string `form:"format"` // jpeg/png/gif
+}
+
+func (a *FileImageParam) Validate() error {
+	maxDimension := config.C.Util.Image.MaxDimension
+	if maxDimension <= 0 {
+		maxDimension = 2048
+	}
+
+	if a.Width < 0 || a.Height < 0 || (a.Width == 0 && a.Height == 0) {
+		return errors.BadRequest("", "Width or height is required")
+	} else if a.Width > maxDimension || a.Height > maxDimension {
+		return errors.BadRequest("", "Width and height must not exceed %d", maxDimension)
+	}
+	return nil
+}
+
+// FileUploadForm requests a presigned URL to upload a file directly to the storage backend.
+type FileUploadForm struct {
+	Name string
+	Size int64
+}
+
+func (a *FileUploadForm) Validate() error {
+	if a.Name == "" {
+		return errors.BadRequest("", "File name is required")
+	}
+	if max := config.C.Util.File.MaxSize << 20; max > 0 && a.Size > max {
+		return errors.RequestEntityTooLarge("", "File size exceeds %d MB", config.C.Util.File.MaxSize)
+	}
+	return nil
+}
+
+type FileUploadTicket struct {
+	ObjectKey string
+	URL       string
+	Method    string
+	ExpiresAt time.Time
+}
+
+// FileConfirmForm registers an object uploaded through a FileUploadTicket.
+type FileConfirmForm struct {
+	FileForm
+	ObjectKey string
+}
+
+func (a *FileConfirmForm) Validate() error {
+	if a.ObjectKey == "" {
+		return errors.BadRequest("", "Object key is required")
+	}
+	return a.FileForm.Validate()
+}
+
+type FileRole struct {
+	ID        string
+	FileID    string
+	RoleID    string
+	CreatedAt time.Time
+}
+
+func (a *FileRole) TableName() string {
+	return config.C.FormatTableName("file_role")
+}
+
+type FileRoles []*FileRole
+
+func (a FileRoles) ToFileIDMap() map[string][]string {
+	m := make(map[string][]string)
+	for _, item := range a {
+		m[item.FileID] = append(m[item.FileID], item.RoleID)
+	}
+	return m
+}
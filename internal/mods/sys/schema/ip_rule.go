@@ -0,0 +1,146 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/middleware"
+	"gin-admin/pkg/util"
+	"strings"
+	"time"
+)
+
+const (
+	IPRuleStatusEnabled  = "enabled"
+	IPRuleStatusDisabled = "disabled"
+)
+
+// IPRule allows or denies the clients in CIDRs, or in Countries when a geo lookup is configured, to request
+// the paths below PathPrefix. Only the rules with the longest matching prefix are checked for a request.
+type IPRule struct {
+	ID          string
+	Name        string
+	PathPrefix  string
+	Action      string
+	CIDRs       string `gorm:"type:text"` // Comma separated IPs or CIDRs, IPv4 and IPv6
+	Countries   string // Comma separated ISO 3166-1 alpha-2 codes
+	Status      string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (a *IPRule) TableName() string {
+	return config.C.FormatTableName("ip_rule")
+}
+
+// ToMiddleware converts the rule for the middleware, the CIDRs have been validated by the form.
+func (a *IPRule) ToMiddleware() (middleware.IPRule, error) {
+	prefixes, err := util.ParseIPPrefixes(a.CIDRs)
+	if err != nil {
+		return middleware.IPRule{}, err
+	}
+	return middleware.IPRule{
+		PathPrefix: a.PathPrefix,
+		Action:     a.Action,
+		Prefixes:   prefixes,
+		Countries:  splitCountries(a.Countries),
+	}, nil
+}
+
+func splitCountries(s string) []string {
+	var countries []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+			countries = append(countries, v)
+		}
+	}
+	return countries
+}
+
+type IPRuleQueryParam struct {
+	util.PaginationParam
+	LikeName       string `form:"name"`
+	LikePathPrefix string `form:"pathPrefix"`
+	Action         string `form:"action"`
+	Status         string `form:"status"`
+}
+
+type IPRuleQueryOptions struct {
+	util.QueryOptions
+}
+
+type IPRuleQueryResult struct {
+	Data       IPRules
+	PageResult *util.PaginationResult
+}
+
+type IPRules []*IPRule
+
+type IPRuleForm struct {
+	Name        string
+	PathPrefix  string
+	Action      string
+	CIDRs       string
+	Countries   string
+	Status      string
+	Description string
+}
+
+func (a *IPRuleForm) Validate() error {
+	if a.Name == "" {
+		return errors.BadRequest("", "Name is required")
+	}
+
+	a.PathPrefix = strings.TrimSpace(a.PathPrefix)
+	if !strings.HasPrefix(a.PathPrefix, "/") {
+		return errors.BadRequest("", "Path prefix must start with /")
+	}
+
+	switch a.Action {
+	case middleware.IPRuleAllow, middleware.IPRuleDeny:
+	default:
+		return errors.BadRequest("", "Invalid action: %s", a.Action)
+	}
+
+	prefixes, err := util.ParseIPPrefixes(a.CIDRs)
+	if err != nil {
+		return errors.BadRequest("", "%s", err.Error())
+	}
+	cidrs := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		cidrs[i] = prefix.String()
+	}
+	a.CIDRs = strings.Join(cidrs, ",")
+
+	countries := splitCountries(a.Countries)
+	for _, country := range countries {
+		if len(country) != 2 {
+			return errors.BadRequest("", "Invalid country code: %s", country)
+		}
+	}
+	a.Countries = strings.Join(countries, ",")
+
+	if a.CIDRs == "" && a.Countries == "" {
+		return errors.BadRequest("", "CIDRs or countries are required")
+	}
+
+	switch a.Status {
+	case "":
+		a.Status = IPRuleStatusEnabled
+	case IPRuleStatusEnabled, IPRuleStatusDisabled:
+	default:
+		return errors.BadRequest("", "Invalid status: %s", a.Status)
+	}
+	return nil
+}
+
+func (a *IPRuleForm) FillTo(item *IPRule) error {
+	item.Name = a.Name
+	item.PathPrefix = a.PathPrefix
+	item.Action = a.Action
+	item.CIDRs = a.CIDRs
+	item.Countries = a.Countries
+	item.Status = a.Status
+	item.Description = a.Description
+	return nil
+}
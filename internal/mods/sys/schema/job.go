@@ -0,0 +1,165 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/jobx"
+	"gin-admin/pkg/util"
+	"time"
+)
+
+const (
+	JobStatusEnabled  = "enabled"
+	JobStatusDisabled = "disabled"
+)
+
+// Concurrency policies decide what happens when a job is triggered while a previous run is still going.
+const (
+	JobConcurrencyAllow   = "allow"   // Start another run
+	JobConcurrencyForbid  = "forbid"  // Skip the new run
+	JobConcurrencyReplace = "replace" // Cancel the running one on this instance and start the new run
+)
+
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+const (
+	JobLogStatusRunning   = "running"
+	JobLogStatusSucceeded = "succeeded"
+	JobLogStatusFailed    = "failed"
+	JobLogStatusTimeout   = "timeout"
+	JobLogStatusCanceled  = "canceled"
+	JobLogStatusSkipped   = "skipped"
+)
+
+// Job is a persisted schedule for a handler registered with jobx.Register.
+type Job struct {
+	ID                string
+	Name              string
+	Handler           string
+	Args              string `gorm:"type:text"`
+	Spec              string // Cron expression, the seconds field is optional
+	Timeout           int    // seconds, 0 uses the configured default
+	ConcurrencyPolicy string
+	Description       string
+	Status            string
+	LastRunAt         *time.Time
+	LastStatus        string
+	NextRunAt         *time.Time `gorm:"-"` // Filled from the local scheduler
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (a *Job) TableName() string {
+	return config.C.FormatTableName("job")
+}
+
+type JobQueryParam struct {
+	util.PaginationParam
+	LikeName string `form:"name"`
+	Handler  string `form:"handler"`
+	Status   string `form:"status"`
+}
+
+type JobQueryOptions struct {
+	util.QueryOptions
+}
+
+type JobQueryResult struct {
+	Data       Jobs
+	PageResult *util.PaginationResult
+}
+
+type Jobs []*Job
+
+type JobForm struct {
+	Name              string
+	Handler           string
+	Args              string
+	Spec              string
+	Timeout           int // seconds
+	ConcurrencyPolicy string
+	Description       string
+	Status            string
+}
+
+func (a *JobForm) Validate() error {
+	if a.Name == "" {
+		return errors.BadRequest("", "Name is required")
+	} else if a.Timeout < 0 {
+		return errors.BadRequest("", "Timeout must not be negative")
+	}
+	if _, ok := jobx.Lookup(a.Handler); !ok {
+		return errors.BadRequest("", "Job handler %s is not registered", a.Handler)
+	}
+	if _, err := jobx.ParseSpec(a.Spec); err != nil {
+		return errors.BadRequest("", "Invalid cron expression: %s", err.Error())
+	}
+
+	switch a.ConcurrencyPolicy {
+	case "":
+		a.ConcurrencyPolicy = JobConcurrencyForbid
+	case JobConcurrencyAllow, JobConcurrencyForbid, JobConcurrencyReplace:
+	default:
+		return errors.BadRequest("", "Invalid concurrency policy: %s", a.ConcurrencyPolicy)
+	}
+
+	switch a.Status {
+	case "":
+		a.Status = JobStatusEnabled
+	case JobStatusEnabled, JobStatusDisabled:
+	default:
+		return errors.BadRequest("", "Invalid status: %s", a.Status)
+	}
+	return nil
+}
+
+func (a *JobForm) FillTo(item *Job) error {
+	item.Name = a.Name
+	item.Handler = a.Handler
+	item.Args = a.Args
+	item.Spec = a.Spec
+	item.Timeout = a.Timeout
+	item.ConcurrencyPolicy = a.ConcurrencyPolicy
+	item.Description = a.Description
+	item.Status = a.Status
+	return nil
+}
+
+// JobLog is the history of one job execution.
+type JobLog struct {
+	ID         string
+	JobID      string `gorm:"index"`
+	Handler    string
+	Trigger    string
+	Instance   string // Host name and process ID of the instance that ran the job
+	Status     string
+	Output     string `gorm:"type:text"`
+	Error      string `gorm:"size:1024"`
+	Duration   int64  // milliseconds
+	StartedAt  time.Time
+	FinishedAt *time.Time
+}
+
+func (a *JobLog) TableName() string {
+	return config.C.FormatTableName("job_log")
+}
+
+type JobLogQueryParam struct {
+	util.PaginationParam
+	JobID  string `form:"-"`
+	Status string `form:"status"`
+}
+
+type JobLogQueryOptions struct {
+	util.QueryOptions
+}
+
+type JobLogQueryResult struct {
+	Data       JobLogs
+	PageResult *util.PaginationResult
+}
+
+type JobLogs []*JobLog
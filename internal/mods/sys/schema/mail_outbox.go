@@ -0,0 +1,108 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"strings"
+	"time"
+)
+
+const (
+	MailStatusPending = "pending"
+	MailStatusSending = "sending"
+	MailStatusSent    = "sent"
+	MailStatusFailed  = "failed"
+)
+
+// MailOutbox is a queued mail. Bodies are rendered when the mail is queued, so later
+// template changes do not affect mails that are waiting for a retry.
+type MailOutbox struct {
+	ID            string
+	Receivers     string // Comma separated
+	Cc            string
+	Bcc           string
+	Subject       string
+	Body          string `gorm:"type:text"`
+	Template      string
+	Status        string
+	Attempts      int
+	LastError     string `gorm:"size:1024"`
+	NextAttemptAt time.Time
+	SentAt        *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (a *MailOutbox) TableName() string {
+	return config.C.FormatTableName("mail_outbox")
+}
+
+func (a *MailOutbox) ReceiverList() []string {
+	return splitAddresses(a.Receivers)
+}
+
+func (a *MailOutbox) CcList() []string {
+	return splitAddresses(a.Cc)
+}
+
+func (a *MailOutbox) BccList() []string {
+	return splitAddresses(a.Bcc)
+}
+
+func splitAddresses(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+type MailOutboxQueryParam struct {
+	util.PaginationParam
+	Receiver string `form:"receiver"`
+	Status   string `form:"status"`
+	Template string `form:"template"`
+}
+
+type MailOutboxQueryOptions struct {
+	util.QueryOptions
+}
+
+type MailOutboxQueryResult struct {
+	Data       MailOutboxes
+	PageResult *util.PaginationResult
+}
+
+type MailOutboxes []*MailOutbox
+
+// MailForm queues a mail either from a named template with data, or with a raw subject and body.
+type MailForm struct {
+	To       []string
+	Cc       []string
+	Bcc      []string
+	Template string
+	Data     map[string]interface{}
+	Subject  string
+	Body     string
+}
+
+func (a *MailForm) Validate() error {
+	if len(a.To) == 0 {
+		return errors.BadRequest("", "Receivers are required")
+	} else if a.Template == "" && a.Subject == "" {
+		return errors.BadRequest("", "Template or subject is required")
+	}
+	return nil
+}
+
+func (a *MailForm) FillTo(item *MailOutbox) error {
+	item.Receivers = strings.Join(a.To, ",")
+	item.Cc = strings.Join(a.Cc, ",")
+	item.Bcc = strings.Join(a.Bcc, ",")
+	item.Template = a.Template
+	if a.Template == "" {
+		item.Subject = a.Subject
+		item.Body = a.Body
+	}
+	return nil
+}
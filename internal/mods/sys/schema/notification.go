@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"strings"
+	"time"
+)
+
+const (
+	NotificationTypeSystem   = "system"
+	NotificationTypeApproval = "approval"
+)
+
+const (
+	NotificationEventCreated      = "notification"
+	NotificationEventUnreadCount  = "unread_count"
+	NotificationEventAnnouncement = "announcement"
+)
+
+type Notification struct {
+	ID        string
+	UserID    string
+	Type      string
+	Title     string
+	Content   string
+	Link      string
+	ReadAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (a *Notification) TableName() string {
+	return config.C.FormatTableName("notification")
+}
+
+type NotificationQueryParam struct {
+	util.PaginationParam
+	UserID       string     `form:"-"`
+	Type         string     `form:"type"`
+	Unread       bool       `form:"unread"`
+	CreatedAfter *time.Time `form:"-"`
+}
+
+type NotificationQueryOptions struct {
+	util.QueryOptions
+}
+
+type NotificationQueryResult struct {
+	Data       Notifications
+	PageResult *util.PaginationResult
+}
+
+type Notifications []*Notification
+
+type NotificationForm struct {
+	UserIDs []string
+	Type    string
+	Title   string
+	Content string
+	Link    string
+}
+
+func (a *NotificationForm) Validate() error {
+	if len(a.UserIDs) == 0 {
+		return errors.BadRequest("", "Receivers are required")
+	} else if strings.TrimSpace(a.Title) == "" {
+		return errors.BadRequest("", "Title is required")
+	}
+	if a.Type == "" {
+		a.Type = NotificationTypeSystem
+	}
+	return nil
+}
+
+type UnreadCount struct {
+	Count int64
+}
@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/notify"
+	"gin-admin/pkg/util"
+	"time"
+)
+
+const (
+	NotifyScopeSelf = "self" // Only events about the subscriber
+	NotifyScopeAll  = "all"  // Events about any user and system events, root only
+)
+
+const (
+	NotifyChannelInApp = "inapp"
+	NotifyChannelMail  = "mail"
+)
+
+const (
+	NotifySubscriptionStatusEnabled  = "enabled"
+	NotifySubscriptionStatusDisabled = "disabled"
+)
+
+var NotifyEventTypes = []string{
+	notify.EventLoginNewDevice,
+	notify.EventUserRoleChanged,
+	notify.EventLogError,
+}
+
+type NotifySubscription struct {
+	ID        string
+	UserID    string
+	Name      string
+	EventType string
+	Channel   string
+	Target    string // Webhook URL or mail address, depending on the channel
+	Secret    string `json:"-"`
+	Scope     string
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (a *NotifySubscription) TableName() string {
+	return config.C.FormatTableName("notify_subscription")
+}
+
+type NotifySubscriptionQueryParam struct {
+	util.PaginationParam
+	UserID    string `form:"-"`
+	EventType string `form:"eventType"`
+	Channel   string `form:"channel"`
+	Status    string `form:"status"`
+}
+
+type NotifySubscriptionQueryOptions struct {
+	util.QueryOptions
+}
+
+type NotifySubscriptionQueryResult struct {
+	Data       NotifySubscriptions
+	PageResult *util.PaginationResult
+}
+
+type NotifySubscriptions []*NotifySubscription
+
+type NotifySubscriptionForm struct {
+	Name      string
+	EventType string
+	Channel   string
+	Target    string
+	Secret    string // Kept unchanged on update if empty
+	Scope     string
+	Status    string
+}
+
+func (a *NotifySubscriptionForm) Validate() error {
+	var validEvent bool
+	for _, t := range NotifyEventTypes {
+		if t == a.EventType {
+			validEvent = true
+		}
+	}
+	if !validEvent {
+		return errors.BadRequest("", "Invalid event type: %s", a.EventType)
+	}
+
+	switch a.Channel {
+	case NotifyChannelInApp, NotifyChannelMail:
+	case notify.ChannelWebhook, notify.ChannelDingTalk, notify.ChannelWeCom, notify.ChannelSlack:
+		if a.Target == "" {
+			return errors.BadRequest("", "Webhook URL is required")
+		}
+	default:
+		return errors.BadRequest("", "Invalid channel: %s", a.Channel)
+	}
+
+	switch a.Scope {
+	case "":
+		a.Scope = NotifyScopeSelf
+	case NotifyScopeSelf, NotifyScopeAll:
+	default:
+		return errors.BadRequest("", "Invalid scope: %s", a.Scope)
+	}
+
+	switch a.Status {
+	case "":
+		a.Status = NotifySubscriptionStatusEnabled
+	case NotifySubscriptionStatusEnabled, NotifySubscriptionStatusDisabled:
+	default:
+		return errors.BadRequest("", "Invalid status: %s", a.Status)
+	}
+	return nil
+}
+
+func (a *NotifySubscriptionForm) FillTo(item *NotifySubscription) error {
+	item.Name = a.Name
+	item.EventType = a.EventType
+	item.Channel = a.Channel
+	item.Target = a.Target
+	if a.Secret != "" {
+		item.Secret = a.Secret
+	}
+	item.Scope = a.Scope
+	item.Status = a.Status
+	return nil
+}
@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"encoding/json"
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/util"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	ParameterTypeString = "string"
+	ParameterTypeInt    = "int"
+	ParameterTypeBool   = "bool"
+	ParameterTypeJSON   = "json"
+)
+
+const (
+	ParameterActionCreate = "create"
+	ParameterActionUpdate = "update"
+	ParameterActionDelete = "delete"
+)
+
+var parameterCodeRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.\-]{0,127}$`)
+
+type Parameter struct {
+	ID          string
+	Code        string
+	Name        string
+	Value       string
+	Type        string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (a *Parameter) TableName() string {
+	return config.C.FormatTableName("parameter")
+}
+
+func (a *Parameter) Int() (int, error) {
+	return strconv.Atoi(a.Value)
+}
+
+func (a *Parameter) Bool() (bool, error) {
+	return strconv.ParseBool(a.Value)
+}
+
+func (a *Parameter) Unmarshal(v interface{}) error {
+	return json.Unmarshal([]byte(a.Value), v)
+}
+
+type ParameterQueryParam struct {
+	util.PaginationParam
+	LikeCode string   `form:"code"`
+	LikeName string   `form:"name"`
+	Type     string   `form:"type"`
+	InCodes  []string `form:"-"`
+}
+
+type ParameterQueryOptions struct {
+	util.QueryOptions
+}
+
+type ParameterQueryResult struct {
+	Data       Parameters
+	PageResult *util.PaginationResult
+}
+
+type Parameters []*Parameter
+
+func (a Parameters) ToCodeMap() map[string]*Parameter {
+	m := make(map[string]*Parameter)
+	for _, item := range a {
+		m[item.Code] = item
+	}
+	return m
+}
+
+type ParameterForm struct {
+	Code        string
+	Name        string
+	Value       string
+	Type        string
+	Description string
+}
+
+func (a *ParameterForm) Validate() error {
+	if !parameterCodeRegexp.MatchString(a.Code) {
+		return errors.BadRequest("", "Invalid parameter code")
+	}
+	return ValidateParameterValue(a.Type, a.Value)
+}
+
+func (a *ParameterForm) FillTo(parameter *Parameter) error {
+	parameter.Code = a.Code
+	parameter.Name = a.Name
+	parameter.Value = a.Value
+	parameter.Type = a.Type
+	parameter.Description = a.Description
+	return nil
+}
+
+func ValidateParameterValue(typ, value string) error {
+	switch typ {
+	case ParameterTypeString:
+	case ParameterTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return errors.BadRequest("", "Invalid int value: %s", value)
+		}
+	case ParameterTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.BadRequest("", "Invalid bool value: %s", value)
+		}
+	case ParameterTypeJSON:
+		if !json.Valid([]byte(value)) {
+			return errors.BadRequest("", "Invalid json value")
+		}
+	default:
+		return errors.BadRequest("", "Invalid parameter type: %s", typ)
+	}
+	return nil
+}
+
+type ParameterHistory struct {
+	ID          string
+	ParameterID string
+	Code        string
+	Action      string
+	OldValue    string
+	NewValue    string
+	Operator    string
+	CreatedAt   time.Time
+}
+
+func (a *ParameterHistory) TableName() string {
+	return config.C.FormatTableName("parameter_history")
+}
+
+type ParameterHistoryQueryParam struct {
+	util.PaginationParam
+	ParameterID string `form:"-"`
+	Code        string `form:"code"`
+}
+
+type ParameterHistoryQueryOptions struct {
+	util.QueryOptions
+}
+
+type ParameterHistoryQueryResult struct {
+	Data       ParameterHistories
+	PageResult *util.PaginationResult
+}
+
+type ParameterHistories []*ParameterHistory
@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/middleware"
+	"gin-admin/pkg/util"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	RateLimitScopeIP   = "ip"   // Counted per client IP
+	RateLimitScopeUser = "user" // Counted per user, per IP for anonymous requests
+)
+
+const (
+	RateLimitPolicyStatusEnabled  = "enabled"
+	RateLimitPolicyStatusDisabled = "disabled"
+)
+
+// RateLimitPolicy limits the requests to the routes matching Method and Path. Path is a route as it is
+// registered, e.g. /api/v1/users/:id, and matches every route below it when it ends with "*".
+// A policy with a RoleCode only applies to users with that role and wins over one without.
+type RateLimitPolicy struct {
+	ID          string
+	Name        string
+	Method      string // Any method if empty
+	Path        string
+	RoleCode    string
+	Scope       string
+	Algorithm   string
+	Limit       int
+	Period      int // seconds
+	Priority    int // Higher is checked first
+	Status      string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (a *RateLimitPolicy) TableName() string {
+	return config.C.FormatTableName("rate_limit_policy")
+}
+
+// Match reports whether the policy applies to a request for the route.
+func (a *RateLimitPolicy) Match(method, route string) bool {
+	if a.Method != "" && a.Method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(a.Path, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return a.Path == route
+}
+
+type RateLimitPolicyQueryParam struct {
+	util.PaginationParam
+	LikeName string `form:"name"`
+	LikePath string `form:"path"`
+	RoleCode string `form:"roleCode"`
+	Status   string `form:"status"`
+}
+
+type RateLimitPolicyQueryOptions struct {
+	util.QueryOptions
+}
+
+type RateLimitPolicyQueryResult struct {
+	Data       RateLimitPolicies
+	PageResult *util.PaginationResult
+}
+
+type RateLimitPolicies []*RateLimitPolicy
+
+type RateLimitPolicyForm struct {
+	Name        string
+	Method      string
+	Path        string
+	RoleCode    string
+	Scope       string
+	Algorithm   string
+	Limit       int
+	Period      int
+	Priority    int
+	Status      string
+	Description string
+}
+
+func (a *RateLimitPolicyForm) Validate() error {
+	if a.Name == "" {
+		return errors.BadRequest("", "Name is required")
+	}
+
+	a.Method = strings.ToUpper(strings.TrimSpace(a.Method))
+	switch a.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return errors.BadRequest("", "Invalid method: %s", a.Method)
+	}
+
+	a.Path = strings.TrimSpace(a.Path)
+	if !strings.HasPrefix(a.Path, "/") {
+		return errors.BadRequest("", "Path must start with /")
+	}
+
+	switch a.Scope {
+	case "":
+		a.Scope = RateLimitScopeIP
+	case RateLimitScopeIP, RateLimitScopeUser:
+	default:
+		return errors.BadRequest("", "Invalid scope: %s", a.Scope)
+	}
+
+	switch a.Algorithm {
+	case "":
+		a.Algorithm = middleware.RateLimitFixedWindow
+	case middleware.RateLimitFixedWindow, middleware.RateLimitSlidingWindow, middleware.RateLimitTokenBucket:
+	default:
+		return errors.BadRequest("", "Invalid algorithm: %s", a.Algorithm)
+	}
+
+	// The middleware reads a zero limit as no limit, which must not be set by accident.
+	if a.Limit <= 0 {
+		return errors.BadRequest("", "Limit must be positive")
+	} else if a.Period <= 0 {
+		return errors.BadRequest("", "Period must be positive")
+	}
+
+	switch a.Status {
+	case "":
+		a.Status = RateLimitPolicyStatusEnabled
+	case RateLimitPolicyStatusEnabled, RateLimitPolicyStatusDisabled:
+	default:
+		return errors.BadRequest("", "Invalid status: %s", a.Status)
+	}
+	return nil
+}
+
+func (a *RateLimitPolicyForm) FillTo(item *RateLimitPolicy) error {
+	item.Name = a.Name
+	item.Method = a.Method
+	item.Path = a.Path
+	item.RoleCode = a.RoleCode
+	item.Scope = a.Scope
+	item.Algorithm = a.Algorithm
+	item.Limit = a.Limit
+	item.Period = a.Period
+	item.Priority = a.Priority
+	item.Status = a.Status
+	item.Description = a.Description
+	return nil
+}
@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"testing"
+)
+
+func TestRateLimitPolicyMatch(t *testing.T) {
+	tests := []struct {
+		method, path string // Policy
+		reqMethod    string
+		route        string
+		want         bool
+	}{
+		{"", "/api/v1/users", "GET", "/api/v1/users", true},
+		{"", "/api/v1/users", "POST", "/api/v1/users", true},
+		{"GET", "/api/v1/users", "GET", "/api/v1/users", true},
+		{"GET", "/api/v1/users", "POST", "/api/v1/users", false},
+		{"", "/api/v1/users", "GET", "/api/v1/users/:id", false},
+		{"", "/api/v1/users/:id", "GET", "/api/v1/users/:id", true},
+		{"", "/api/v1/users/:id", "GET", "/api/v1/users/123", false},
+		{"", "/api/v1/users*", "GET", "/api/v1/users", true},
+		{"", "/api/v1/users*", "GET", "/api/v1/users/:id", true},
+		{"", "/api/v1/users/*", "GET", "/api/v1/users", false},
+		{"", "/api/v1/users/*", "GET", "/api/v1/users/:id/roles", true},
+		{"", "/api/v1/*", "DELETE", "/api/v1/roles/:id", true},
+		{"", "/api/v1/*", "GET", "/api/v2/roles", false},
+		{"", "*", "GET", "/anything", true},
+	}
+
+	for _, tt := range tests {
+		p := &RateLimitPolicy{Method: tt.method, Path: tt.path}
+		if got := p.Match(tt.reqMethod, tt.route); got != tt.want {
+			t.Errorf("policy %s %s matching %s %s = %v, want %v", tt.method, tt.path, tt.reqMethod, tt.route, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitPolicyFormValidate(t *testing.T) {
+	valid := func() RateLimitPolicyForm {
+		return RateLimitPolicyForm{Name: "users", Path: "/api/v1/users", Limit: 10, Period: 60}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(f *RateLimitPolicyForm)
+		wantErr bool
+	}{
+		{"valid", func(f *RateLimitPolicyForm) {}, false},
+		{"lower case method", func(f *RateLimitPolicyForm) { f.Method = "get" }, false},
+		{"missing name", func(f *RateLimitPolicyForm) { f.Name = "" }, true},
+		{"unknown method", func(f *RateLimitPolicyForm) { f.Method = "FETCH" }, true},
+		{"relative path", func(f *RateLimitPolicyForm) { f.Path = "api/v1/users" }, true},
+		{"unknown scope", func(f *RateLimitPolicyForm) { f.Scope = "tenant" }, true},
+		{"unknown algorithm", func(f *RateLimitPolicyForm) { f.Algorithm = "leaky_bucket" }, true},
+		{"zero limit", func(f *RateLimitPolicyForm) { f.Limit = 0 }, true},
+		{"negative limit", func(f *RateLimitPolicyForm) { f.Limit = -1 }, true},
+		{"zero period", func(f *RateLimitPolicyForm) { f.Period = 0 }, true},
+		{"unknown status", func(f *RateLimitPolicyForm) { f.Status = "paused" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := valid()
+			tt.change(&f)
+			if err := f.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
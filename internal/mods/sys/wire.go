@@ -0,0 +1,110 @@
+package sys
+
+import (
+	"gin-admin/internal/config"
+	"gin-admin/internal/mods/sys/api"
+	"gin-admin/internal/mods/sys/biz"
+	"gin-admin/internal/mods/sys/dal"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/oss"
+	"gin-admin/pkg/sse"
+	"gin-admin/pkg/tus"
+	"gin-admin/pkg/util"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/wire"
+)
+
+var Set = wire.NewSet(
+	wire.Struct(new(SYS), "*"),
+	wire.Struct(new(dal.Parameter), "*"),
+	wire.Struct(new(dal.ParameterHistory), "*"),
+	wire.Struct(new(biz.Parameter), "Cache", "Trans", "ParameterDAL", "ParameterHistoryDAL"),
+	wire.Struct(new(api.Parameter), "*"),
+	wire.Struct(new(dal.Announcement), "*"),
+	wire.Struct(new(biz.Announcement), "Broker", "AnnouncementDAL"),
+	wire.Struct(new(api.Announcement), "*"),
+	wire.Struct(new(dal.Notification), "*"),
+	wire.Struct(new(biz.Notification), "*"),
+	wire.Struct(new(api.Notification), "*"),
+	wire.Struct(new(dal.MailOutbox), "*"),
+	wire.Struct(new(biz.MailOutbox), "Trans", "MailOutboxDAL"),
+	wire.Struct(new(api.MailOutbox), "*"),
+	wire.Struct(new(dal.NotifySubscription), "*"),
+	wire.Struct(new(biz.NotifySubscription), "*"),
+	wire.Struct(new(api.NotifySubscription), "*"),
+	wire.Struct(new(dal.Job), "*"),
+	wire.Struct(new(dal.JobLog), "*"),
+	wire.Struct(new(biz.Job), "Cache", "JobDAL", "JobLogDAL"),
+	wire.Struct(new(api.Job), "*"),
+	wire.Struct(new(dal.RateLimitPolicy), "*"),
+	wire.Struct(new(biz.RateLimitPolicy), "Cache", "RateLimitPolicyDAL", "RoleDAL"),
+	wire.Struct(new(api.RateLimitPolicy), "*"),
+	wire.Struct(new(dal.IPRule), "*"),
+	wire.Struct(new(biz.IPRule), "Cache", "IPRuleDAL"),
+	wire.Struct(new(api.IPRule), "*"),
+	wire.Struct(new(biz.Cache), "*"),
+	wire.Struct(new(api.Cache), "*"),
+	wire.Struct(new(dal.File), "*"),
+	wire.Struct(new(dal.FileRole), "*"),
+	wire.Struct(new(biz.File), "*"),
+	wire.Struct(new(api.File), "*"),
+	NewSSEBroker,
+	NewOSSClient,
+	NewTusHandler,
+)
+
+// NewSSEBroker relays events through the Redis pub/sub when the cache is shared
+// between instances, so a stream receives them whichever instance it is connected to.
+func NewSSEBroker(cache cachex.Cacher) *sse.Broker {
+	cfg := config.C.Util.Notification
+	broker := sse.NewBroker(cfg.BufferSize)
+	if ps, ok := cachex.PubSubOf(cache); ok {
+		channel := cfg.Channel
+		if channel == "" {
+			channel = "sse:events"
+		}
+		broker.SetRelay(ps, channel)
+	}
+	return broker
+}
+
+func NewOSSClient() (oss.IClient, error) {
+	cfg := config.C.Storage.OSS
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "local":
+		return oss.NewLocalClient(oss.LocalClientConfig{
+			Root:          cfg.Local.Root,
+			Domain:        cfg.Local.Domain,
+			BucketName:    cfg.Local.BucketName,
+			Prefix:        cfg.Local.Prefix,
+			SignSecret:    cfg.Local.SignSecret,
+			MaxUploadSize: config.C.Util.File.MaxSize << 20,
+		})
+	case "minio":
+		return oss.NewMinioClient(oss.MinioClientConfig(cfg.Minio))
+	case "s3":
+		return oss.NewS3Client(oss.S3ClientConfig(cfg.S3))
+	}
+	return nil, errors.Errorf("unsupported oss type: %s", cfg.Type)
+}
+
+// NewTusHandler keeps the upload locks in process memory: when several instances share
+// Util.File.Upload.Dir, the requests of an upload must reach the same instance (e.g. sticky routing).
+func NewTusHandler(fileBIZ *biz.File) (*tus.Handler, error) {
+	cfg := config.C.Util.File
+	return tus.NewHandler(tus.Config{
+		Dir:             cfg.Upload.Dir,
+		MaxSize:         cfg.MaxSize << 20,
+		Expiration:      time.Duration(cfg.Upload.Expiration) * time.Second,
+		CleanupInterval: time.Duration(cfg.Upload.CleanupInterval) * time.Second,
+		Finalize:        fileBIZ.FinalizeUpload,
+		Owner: func(c *gin.Context) string {
+			return util.FromUserID(c.Request.Context())
+		},
+	})
+}
@@ -1,17 +1,31 @@
 package cachex
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 
 	"github.com/dgraph-io/badger/v3"
+	"github.com/dgraph-io/badger/v3/pb"
 	"golang.org/x/net/context"
 )
 
 type BadgerConfig struct {
-	Path string
+	Path           string
+	GCInterval     time.Duration // Value log GC interval, disabled if 0
+	GCDiscardRatio float64       // Rewrite a value log file when at least this fraction of it can be discarded, 0.5 by default
+	// OnSize receives the size of the LSM tree and the value log every SizeInterval and after each GC run,
+	// e.g. to export them as metrics.
+	OnSize       func(lsm, vlog int64)
+	SizeInterval time.Duration // 1 minute by default
 }
 
 func NewBadgerCache(cfg BadgerConfig, opts ...Option) Cacher {
@@ -30,15 +44,180 @@ func NewBadgerCache(cfg BadgerConfig, opts ...Option) Cacher {
 		panic(err)
 	}
 
-	return &badgerCache{
+	c := &badgerCache{
 		opts: defaultOpts,
+		cfg:  cfg,
 		db:   db,
+		done: make(chan struct{}),
 	}
+	if cfg.GCInterval > 0 {
+		if c.cfg.GCDiscardRatio <= 0 || c.cfg.GCDiscardRatio >= 1 {
+			c.cfg.GCDiscardRatio = 0.5
+		}
+		c.wg.Add(1)
+		go c.gcLoop()
+	}
+	if cfg.OnSize != nil {
+		if c.cfg.SizeInterval <= 0 {
+			c.cfg.SizeInterval = time.Minute
+		}
+		c.reportSize()
+		c.wg.Add(1)
+		go c.sizeLoop()
+	}
+	return c
 }
 
 type badgerCache struct {
-	opts *options
-	db   *badger.DB
+	opts      *options
+	cfg       BadgerConfig
+	db        *badger.DB
+	loads     loadGroup
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// gcLoop runs the value log GC, badger never reclaims space in the value log on its own.
+func (a *badgerCache) gcLoop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.cfg.GCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.runGC()
+		}
+	}
+}
+
+func (a *badgerCache) runGC() {
+	// One call rewrites at most one file, so keep going until there is nothing left to rewrite.
+	for {
+		select {
+		case <-a.done:
+			return
+		default:
+		}
+		if err := a.db.RunValueLogGC(a.cfg.GCDiscardRatio); err != nil {
+			break
+		}
+	}
+
+	a.reportSize()
+}
+
+// sizeLoop reports the size between GC runs, which may be far apart or disabled.
+func (a *badgerCache) sizeLoop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.cfg.SizeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.reportSize()
+		}
+	}
+}
+
+func (a *badgerCache) reportSize() {
+	if a.cfg.OnSize != nil {
+		a.cfg.OnSize(a.db.Size())
+	}
+}
+
+// Backup writes a snapshot of the cache to w, it is safe to call while the cache is in use.
+func (a *badgerCache) Backup(ctx context.Context, w io.Writer, namespaces ...string) error {
+	stream := a.db.NewStream()
+	stream.LogPrefix = "cachex.Backup"
+	if len(namespaces) > 0 {
+		prefixes := a.nsPrefixes(namespaces)
+		stream.ChooseKey = func(item *badger.Item) bool {
+			return hasAnyPrefix(item.Key(), prefixes)
+		}
+	}
+	_, err := stream.Backup(w, 0)
+	return err
+}
+
+// Restore replaces the content of the cache with a snapshot written by Backup. It may run while
+// the cache is in use, but writes made during the restore can be overwritten by the snapshot.
+// Given namespaces, only those are dropped and loaded, the other entries of the snapshot are skipped.
+func (a *badgerCache) Restore(ctx context.Context, r io.Reader, namespaces ...string) error {
+	if len(namespaces) == 0 {
+		if err := a.db.DropAll(); err != nil {
+			return err
+		}
+		return a.db.Load(r, 256)
+	}
+
+	prefixes := a.nsPrefixes(namespaces)
+	if err := a.db.DropPrefix(prefixes...); err != nil {
+		return err
+	}
+
+	// The entries are written anew rather than with their versions, which db.Load may only do on a
+	// database without other writers.
+	wb := a.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	now := uint64(time.Now().Unix())
+	br := bufio.NewReader(r)
+	for {
+		var size uint64
+		if err := binary.Read(br, binary.LittleEndian, &size); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return err
+		}
+		var list pb.KVList
+		if err := list.Unmarshal(buf); err != nil {
+			return err
+		}
+
+		for _, kv := range list.Kv {
+			deleted := len(kv.Meta) > 0 && kv.Meta[0]&badgerBitDelete != 0
+			if deleted || (kv.ExpiresAt > 0 && kv.ExpiresAt <= now) || !hasAnyPrefix(kv.Key, prefixes) {
+				continue
+			}
+			entry := badger.NewEntry(kv.Key, kv.Value)
+			entry.ExpiresAt = kv.ExpiresAt
+			if err := wb.SetEntry(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return wb.Flush()
+}
+
+// badgerBitDelete marks the delete markers in a backup, as in badger's unexported bitDelete.
+const badgerBitDelete byte = 1 << 0
+
+func (a *badgerCache) nsPrefixes(namespaces []string) [][]byte {
+	prefixes := make([][]byte, 0, len(namespaces))
+	for _, ns := range namespaces {
+		prefixes = append(prefixes, []byte(ns+a.opts.Delimiter))
+	}
+	return prefixes
+}
+
+func hasAnyPrefix(key []byte, prefixes [][]byte) bool {
+	for _, p := range prefixes {
+		if bytes.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
 }
 
 func (a *badgerCache) getKey(ns, key string) string {
@@ -61,7 +240,7 @@ func (a *badgerCache) bytesToStr(b []byte) string {
 func (a *badgerCache) Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error {
 	return a.db.Update(func(txn *badger.Txn) error {
 		entry := badger.NewEntry(a.strToBytes(a.getKey(ns, key)), a.strToBytes(value))
-		if len(expiration) > 0 {
+		if len(expiration) > 0 && expiration[0] > 0 {
 			entry = entry.WithTTL(expiration[0])
 		}
 		return txn.SetEntry(entry)
@@ -88,6 +267,11 @@ func (a *badgerCache) Get(ctx context.Context, ns, key string) (string, bool, er
 	if err != nil {
 		return "", false, err
 	}
+	if ok {
+		a.opts.emit(ns, EventHit)
+	} else {
+		a.opts.emit(ns, EventMiss)
+	}
 	return value, ok, nil
 }
 
@@ -153,7 +337,8 @@ func (a *badgerCache) Iterator(ctx context.Context, ns string, fn func(ctx conte
 				return err
 			}
 
-			key := a.bytesToStr(item.Key())
+			// The key is only valid until Next, so it is copied before fn may keep it.
+			key := a.bytesToStr(item.KeyCopy(nil))
 			if !fn(ctx, strings.TrimPrefix(key, a.getKey(ns, "")), a.bytesToStr(val)) {
 				break
 			}
@@ -163,6 +348,270 @@ func (a *badgerCache) Iterator(ctx context.Context, ns string, fn func(ctx conte
 	})
 }
 
+func (a *badgerCache) IterateKeys(ctx context.Context, ns string, fn func(ctx context.Context, key string) bool) error {
+	return a.db.View(func(txn *badger.Txn) error {
+		iterOpts := badger.DefaultIteratorOptions
+		iterOpts.PrefetchValues = false
+		iterOpts.Prefix = a.strToBytes(a.getKey(ns, ""))
+		it := txn.NewIterator(iterOpts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := a.bytesToStr(it.Item().KeyCopy(nil))
+			if !fn(ctx, strings.TrimPrefix(key, a.getKey(ns, ""))) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+func (a *badgerCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	err := a.db.View(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			item, err := txn.Get(a.strToBytes(a.getKey(ns, key)))
+			if err != nil {
+				if err == badger.ErrKeyNotFound {
+					a.opts.emit(ns, EventMiss)
+					continue
+				}
+				return err
+			}
+
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			a.opts.emit(ns, EventHit)
+			values[key] = a.bytesToStr(val)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (a *badgerCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	wb := a.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for key, value := range values {
+		entry := badger.NewEntry([]byte(a.getKey(ns, key)), []byte(value))
+		if len(expiration) > 0 && expiration[0] > 0 {
+			entry = entry.WithTTL(expiration[0])
+		}
+		if err := wb.SetEntry(entry); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+func (a *badgerCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	wb := a.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, key := range keys {
+		if err := wb.Delete([]byte(a.getKey(ns, key))); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// remaining returns the time to live of an item, 0 if it never expires.
+func (a *badgerCache) remaining(item *badger.Item) time.Duration {
+	expiresAt := item.ExpiresAt()
+	if expiresAt == 0 {
+		return 0
+	}
+	if d := time.Until(time.Unix(int64(expiresAt), 0)); d > 0 {
+		return d
+	}
+	return time.Nanosecond
+}
+
+func (a *badgerCache) Incr(ctx context.Context, ns, key string, delta int64, expiration ...time.Duration) (int64, error) {
+	k := []byte(a.getKey(ns, key))
+	for {
+		var n int64
+		err := a.db.Update(func(txn *badger.Txn) error {
+			var ttl time.Duration
+			item, err := txn.Get(k)
+			if err == nil {
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if n, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+					return fmt.Errorf("cachex: value of %s is not an integer", key)
+				}
+				ttl = a.remaining(item)
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+
+			if ttl == 0 && len(expiration) > 0 {
+				ttl = expiration[0]
+			}
+
+			n += delta
+			entry := badger.NewEntry(k, []byte(strconv.FormatInt(n, 10)))
+			if ttl > 0 {
+				entry = entry.WithTTL(ttl)
+			}
+			return txn.SetEntry(entry)
+		})
+		// Concurrent increments of the same key conflict, the loser retries with the new value.
+		if err == badger.ErrConflict {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+}
+
+func (a *badgerCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	exists := false
+	err := a.db.Update(func(txn *badger.Txn) error {
+		k := []byte(a.getKey(ns, key))
+		item, err := txn.Get(k)
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return nil
+			}
+			return err
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		exists = true
+		entry := badger.NewEntry(k, val)
+		if expiration > 0 {
+			entry = entry.WithTTL(expiration)
+		}
+		return txn.SetEntry(entry)
+	})
+	return exists, err
+}
+
+func (a *badgerCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	var ttl time.Duration
+	exists := false
+	err := a.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(a.getKey(ns, key)))
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return nil
+			}
+			return err
+		}
+		exists = true
+		ttl = a.remaining(item)
+		return nil
+	})
+	return ttl, exists, err
+}
+
+func (a *badgerCache) Clear(ctx context.Context, ns string) error {
+	return a.db.DropPrefix([]byte(a.getKey(ns, "")))
+}
+
+func (a *badgerCache) GetOrLoad(ctx context.Context, ns, key string, ttl time.Duration, loader Loader, opts ...LoadOption) (string, bool, error) {
+	return a.loads.getOrLoad(ctx, a, a.opts.Delimiter, ns, key, ttl, loader, opts...)
+}
+
+// Close stops the background loops and closes the database, later calls do nothing.
 func (a *badgerCache) Close(ctx context.Context) error {
-	return a.db.Close()
+	var err error
+	a.closeOnce.Do(func() {
+		close(a.done)
+		a.wg.Wait()
+		err = a.db.Close()
+	})
+	return err
+}
+
+// Badger transactions are serializable, so a lock check and write in one transaction is atomic.
+// A transaction that lost a race fails with ErrConflict and the lock is reported as taken.
+var errBadgerLockTaken = errors.New("lock taken")
+
+func (a *badgerCache) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, bool, error) {
+	var token int64
+	err := a.db.Update(func(txn *badger.Txn) error {
+		lockKey := []byte(a.getKey(lockNS, key))
+		if _, err := txn.Get(lockKey); err == nil {
+			return errBadgerLockTaken
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		fenceKey := []byte(a.getKey(fenceNS, key))
+		item, err := txn.Get(fenceKey)
+		if err == nil {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			token = int64(binary.BigEndian.Uint64(val))
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+		token = nextToken(token)
+
+		fence := make([]byte, 8)
+		binary.BigEndian.PutUint64(fence, uint64(token))
+		if err := txn.SetEntry(badger.NewEntry(fenceKey, fence).WithTTL(fenceExpiration(ttl))); err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(lockKey, []byte(strconv.FormatInt(token, 10))).WithTTL(ttl))
+	})
+	if err == errBadgerLockTaken || err == badger.ErrConflict {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return &Lock{Key: key, Token: token}, true, nil
+}
+
+func (a *badgerCache) Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return waitLock(ctx, key, ttl, a.TryLock)
+}
+
+func (a *badgerCache) Unlock(ctx context.Context, lock *Lock) error {
+	return a.updateHeldLock(lock, func(txn *badger.Txn, lockKey, val []byte) error {
+		return txn.Delete(lockKey)
+	})
+}
+
+func (a *badgerCache) Refresh(ctx context.Context, lock *Lock, ttl time.Duration) error {
+	return a.updateHeldLock(lock, func(txn *badger.Txn, lockKey, val []byte) error {
+		return txn.SetEntry(badger.NewEntry(lockKey, val).WithTTL(ttl))
+	})
+}
+
+func (a *badgerCache) updateHeldLock(lock *Lock, fn func(txn *badger.Txn, lockKey, val []byte) error) error {
+	return a.db.Update(func(txn *badger.Txn) error {
+		lockKey := []byte(a.getKey(lockNS, lock.Key))
+		item, err := txn.Get(lockKey)
+		if err == badger.ErrKeyNotFound {
+			return ErrLockNotHeld
+		} else if err != nil {
+			return err
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		} else if string(val) != strconv.FormatInt(lock.Token, 10) {
+			return ErrLockNotHeld
+		}
+		return fn(txn, lockKey, val)
+	})
 }
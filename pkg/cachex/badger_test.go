@@ -0,0 +1,99 @@
+package cachex
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBadgerCache(t *testing.T) Cacher {
+	t.Helper()
+
+	c := NewBadgerCache(BadgerConfig{Path: t.TempDir()})
+	t.Cleanup(func() { _ = c.Close(context.Background()) })
+	return c
+}
+
+func TestBadgerBackupRestore(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		want       map[string]string // ns:key to the value after the restore, "" if missing
+	}{
+		{"whole cache", nil, map[string]string{
+			"user:a": "backup", "user:b": "", "secret:a": "backup", "secret:b": "",
+		}},
+		{"only the given namespaces", []string{"user"}, map[string]string{
+			"user:a": "backup", "user:b": "", "secret:a": "changed", "secret:b": "new",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestBadgerCache(t)
+			for _, ns := range []string{"user", "secret"} {
+				if err := c.Set(ctx, ns, "a", "backup"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := c.Set(ctx, "user", "expired", "backup", 10*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := c.(Backuper).Backup(ctx, &buf, tt.namespaces...); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+
+			for _, ns := range []string{"user", "secret"} {
+				_ = c.Set(ctx, ns, "a", "changed")
+				_ = c.Set(ctx, ns, "b", "new")
+			}
+			if err := c.(Backuper).Restore(ctx, &buf, tt.namespaces...); err != nil {
+				t.Fatal(err)
+			}
+
+			for nsKey, want := range tt.want {
+				ns, key, _ := strings.Cut(nsKey, ":")
+				got, _, err := c.Get(ctx, ns, key)
+				if err != nil {
+					t.Fatal(err)
+				} else if got != want {
+					t.Errorf("%s = %q, want %q", nsKey, got, want)
+				}
+			}
+			if ok, _ := c.Exists(ctx, "user", "expired"); ok {
+				t.Error("expired entry was restored")
+			}
+		})
+	}
+}
+
+func TestBadgerBackupNamespaces(t *testing.T) {
+	ctx := context.Background()
+	c := newTestBadgerCache(t)
+	_ = c.Set(ctx, "user", "a", "1")
+	_ = c.Set(ctx, "users", "a", "2")
+	_ = c.Set(ctx, "secret", "a", "3")
+
+	var buf bytes.Buffer
+	if err := c.(Backuper).Backup(ctx, &buf, "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loaded in full into another cache, the backup holds only the namespace asked for.
+	other := newTestBadgerCache(t)
+	if err := other.(Backuper).Restore(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for ns, want := range map[string]bool{"user": true, "users": false, "secret": false} {
+		if ok, _ := other.Exists(ctx, ns, "a"); ok != want {
+			t.Errorf("%s in the backup = %v, want %v", ns, ok, want)
+		}
+	}
+}
@@ -3,7 +3,10 @@ package cachex
 import (
 	"context"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -16,13 +19,72 @@ type Cacher interface {
 	Exists(ctx context.Context, ns, key string) (bool, error)
 	Delete(ctx context.Context, ns, key string) error
 	Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error
+	// MGet returns the values of the existing keys.
+	MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error)
+	MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error
+	MDelete(ctx context.Context, ns string, keys ...string) error
+	// Incr adds delta to the integer value of the key, a missing key counts as 0. The expiration is kept,
+	// a key without one gets the given expiration in the same atomic step, e.g. for counters in a window.
+	Incr(ctx context.Context, ns, key string, delta int64, expiration ...time.Duration) (int64, error)
+	// Expire sets a new expiration on the key and reports whether the key exists.
+	Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error)
+	// TTL returns the remaining time to live of the key, 0 if it never expires. It reports false if the key does not exist.
+	TTL(ctx context.Context, ns, key string) (time.Duration, bool, error)
+	// Clear removes all keys of the namespace.
+	Clear(ctx context.Context, ns string) error
+	// GetOrLoad returns the cached value or loads it with a single loader call shared by concurrent callers.
+	// It reports false if the loader returned ErrNotFound. Keys should be read back with GetOrLoad only.
+	GetOrLoad(ctx context.Context, ns, key string, ttl time.Duration, loader Loader, opts ...LoadOption) (string, bool, error)
 	Close(ctx context.Context) error
 }
 
+// KeyIterator is implemented by caches that can list keys without reading their values.
+type KeyIterator interface {
+	IterateKeys(ctx context.Context, ns string, fn func(ctx context.Context, key string) bool) error
+}
+
+// IterateKeys calls fn for each key of the namespace until it returns false. Caches implementing
+// KeyIterator skip the values, others fall back to Iterator.
+func IterateKeys(ctx context.Context, c Cacher, ns string, fn func(ctx context.Context, key string) bool) error {
+	if tc, ok := c.(*tieredCache); ok {
+		c = tc.remote
+	}
+	if ki, ok := c.(KeyIterator); ok {
+		return ki.IterateKeys(ctx, ns, fn)
+	}
+	return c.Iterator(ctx, ns, func(ctx context.Context, key, value string) bool {
+		return fn(ctx, key)
+	})
+}
+
+// Backuper is implemented by caches that can be dumped to and loaded from a file, currently badger.
+// Given namespaces, only those are backed up and replaced, otherwise the whole cache.
+type Backuper interface {
+	Backup(ctx context.Context, w io.Writer, namespaces ...string) error
+	Restore(ctx context.Context, r io.Reader, namespaces ...string) error
+}
+
 var defaultDelimiter = ":"
 
+// Events reported to the handler set with WithEventHandler, e.g. to export hit ratios as metrics.
+const (
+	EventHit        = "hit"
+	EventMiss       = "miss"
+	EventEviction   = "eviction"   // Removed because of expiry or capacity, not by a delete
+	EventLocalHit   = "local_hit"  // Tiered cache only
+	EventRemoteHit  = "remote_hit" // Tiered cache only
+	EventInvalidate = "invalidate" // Tiered cache only, local copy dropped on a peer's write
+)
+
 type options struct {
 	Delimiter string
+	OnEvent   func(ns, event string)
+}
+
+func (o *options) emit(ns, event string) {
+	if o.OnEvent != nil {
+		o.OnEvent(ns, event)
+	}
 }
 
 type Option func(*options)
@@ -33,6 +95,14 @@ func WithDelimiter(delimiter string) Option {
 	}
 }
 
+// WithEventHandler reports hits, misses and evictions per namespace, e.g. to export them as metrics.
+// Evictions are only known to the memory and tiered caches.
+func WithEventHandler(fn func(ns, event string)) Option {
+	return func(o *options) {
+		o.OnEvent = fn
+	}
+}
+
 type MemoryConfig struct {
 	CleanupInterval time.Duration
 }
@@ -46,15 +116,27 @@ func NewMemoryCache(cfg MemoryConfig, opts ...Option) Cacher {
 		o(defaultOpts)
 	}
 
-	return &memCache{
-		opts:  defaultOpts,
+	return newMemCache(cfg, defaultOpts)
+}
+
+func newMemCache(cfg MemoryConfig, opts *options) *memCache {
+	c := &memCache{
+		opts:  opts,
 		cache: cache.New(0, cfg.CleanupInterval),
 	}
+	if opts.OnEvent != nil {
+		c.cache.OnEvicted(c.onEvicted)
+	}
+	return c
 }
 
 type memCache struct {
 	opts  *options
 	cache *cache.Cache
+	mu    sync.Mutex // Guards read-modify-write operations and locks
+	loads loadGroup
+	// Keys being deleted explicitly, go-cache reports them as evicted too.
+	deleting sync.Map
 }
 
 func (a *memCache) getKey(ns, key string) string {
@@ -71,11 +153,33 @@ func (a *memCache) Set(ctx context.Context, ns, key, value string, expiration ..
 	return nil
 }
 
+func (a *memCache) delete(k string) {
+	if a.opts.OnEvent == nil {
+		a.cache.Delete(k)
+		return
+	}
+
+	a.deleting.Store(k, struct{}{})
+	a.cache.Delete(k)
+	a.deleting.Delete(k)
+}
+
+func (a *memCache) onEvicted(k string, _ interface{}) {
+	if _, ok := a.deleting.Load(k); ok {
+		return
+	}
+	if ns, _, ok := strings.Cut(k, a.opts.Delimiter); ok {
+		a.opts.emit(ns, EventEviction)
+	}
+}
+
 func (a *memCache) Get(ctx context.Context, ns, key string) (string, bool, error) {
 	val, ok := a.cache.Get(a.getKey(ns, key))
 	if !ok {
+		a.opts.emit(ns, EventMiss)
 		return "", false, nil
 	}
+	a.opts.emit(ns, EventHit)
 	return val.(string), ok, nil
 }
 
@@ -85,7 +189,7 @@ func (a *memCache) Exists(ctx context.Context, ns, key string) (bool, error) {
 }
 
 func (a *memCache) Delete(ctx context.Context, ns, key string) error {
-	a.cache.Delete(a.getKey(ns, key))
+	a.delete(a.getKey(ns, key))
 	return nil
 }
 
@@ -97,7 +201,7 @@ func (a *memCache) GetAndDelete(ctx context.Context, ns, key string) (string, bo
 		return "", false, nil
 	}
 
-	a.cache.Delete(a.getKey(ns, key))
+	a.delete(a.getKey(ns, key))
 	return value, true, nil
 }
 
@@ -112,7 +216,159 @@ func (a *memCache) Iterator(ctx context.Context, ns string, fn func(ctx context.
 	return nil
 }
 
+func (a *memCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if val, ok := a.cache.Get(a.getKey(ns, key)); ok {
+			a.opts.emit(ns, EventHit)
+			values[key] = val.(string)
+		} else {
+			a.opts.emit(ns, EventMiss)
+		}
+	}
+	return values, nil
+}
+
+func (a *memCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	for key, value := range values {
+		a.cache.Set(a.getKey(ns, key), value, exp)
+	}
+	return nil
+}
+
+func (a *memCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	for _, key := range keys {
+		a.delete(a.getKey(ns, key))
+	}
+	return nil
+}
+
+// remaining converts an expiration time of go-cache to the duration used by Set, where 0 means none.
+func (a *memCache) remaining(exp time.Time) time.Duration {
+	if exp.IsZero() {
+		return 0
+	}
+	if d := time.Until(exp); d > 0 {
+		return d
+	}
+	return time.Nanosecond
+}
+
+func (a *memCache) Incr(ctx context.Context, ns, key string, delta int64, expiration ...time.Duration) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(ns, key)
+	var n int64
+	var exp time.Duration
+	if val, expiresAt, ok := a.cache.GetWithExpiration(k); ok {
+		v, err := strconv.ParseInt(val.(string), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cachex: value of %s is not an integer", key)
+		}
+		n = v
+		exp = a.remaining(expiresAt)
+	}
+
+	if exp == 0 && len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	n += delta
+	a.cache.Set(k, strconv.FormatInt(n, 10), exp)
+	return n, nil
+}
+
+func (a *memCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(ns, key)
+	val, ok := a.cache.Get(k)
+	if !ok {
+		return false, nil
+	}
+	a.cache.Set(k, val, expiration)
+	return true, nil
+}
+
+func (a *memCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	_, expiresAt, ok := a.cache.GetWithExpiration(a.getKey(ns, key))
+	if !ok {
+		return 0, false, nil
+	}
+	return a.remaining(expiresAt), true, nil
+}
+
+func (a *memCache) Clear(ctx context.Context, ns string) error {
+	prefix := a.getKey(ns, "")
+	for k := range a.cache.Items() {
+		if strings.HasPrefix(k, prefix) {
+			a.delete(k)
+		}
+	}
+	return nil
+}
+
+func (a *memCache) GetOrLoad(ctx context.Context, ns, key string, ttl time.Duration, loader Loader, opts ...LoadOption) (string, bool, error) {
+	return a.loads.getOrLoad(ctx, a, a.opts.Delimiter, ns, key, ttl, loader, opts...)
+}
+
 func (a *memCache) Close(ctx context.Context) error {
 	a.cache.Flush()
 	return nil
 }
+
+func (a *memCache) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(lockNS, key)
+	if _, ok := a.cache.Get(k); ok {
+		return nil, false, nil
+	}
+
+	fk := a.getKey(fenceNS, key)
+	var last int64
+	if val, ok := a.cache.Get(fk); ok {
+		last, _ = strconv.ParseInt(val.(string), 10, 64)
+	}
+	token := nextToken(last)
+	a.cache.Set(fk, strconv.FormatInt(token, 10), fenceExpiration(ttl))
+	a.cache.Set(k, strconv.FormatInt(token, 10), ttl)
+	return &Lock{Key: key, Token: token}, true, nil
+}
+
+func (a *memCache) Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return waitLock(ctx, key, ttl, a.TryLock)
+}
+
+func (a *memCache) Unlock(ctx context.Context, lock *Lock) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(lockNS, lock.Key)
+	if val, ok := a.cache.Get(k); !ok || val.(string) != strconv.FormatInt(lock.Token, 10) {
+		return ErrLockNotHeld
+	}
+	a.delete(k)
+	return nil
+}
+
+func (a *memCache) Refresh(ctx context.Context, lock *Lock, ttl time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(lockNS, lock.Key)
+	val, ok := a.cache.Get(k)
+	if !ok || val.(string) != strconv.FormatInt(lock.Token, 10) {
+		return ErrLockNotHeld
+	}
+	a.cache.Set(k, val, ttl)
+	return nil
+}
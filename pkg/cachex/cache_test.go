@@ -0,0 +1,96 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacheOperations(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		cache func(t *testing.T) Cacher
+	}{
+		{
+			name: "memory",
+			cache: func(t *testing.T) Cacher {
+				return NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+			},
+		},
+		{
+			name: "badger",
+			cache: func(t *testing.T) Cacher {
+				c := NewBadgerCache(BadgerConfig{Path: t.TempDir()})
+				t.Cleanup(func() { _ = c.Close(ctx) })
+				return c
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.cache(t)
+
+			if err := c.MSet(ctx, "ns", map[string]string{"a": "1", "b": "2", "c": "3"}, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Set(ctx, "other", "a", "x"); err != nil {
+				t.Fatal(err)
+			}
+
+			values, err := c.MGet(ctx, "ns", "a", "b", "missing")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(values) != 2 || values["a"] != "1" || values["b"] != "2" {
+				t.Fatalf("MGet = %v", values)
+			}
+
+			if err := c.MDelete(ctx, "ns", "a", "missing"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := c.Get(ctx, "ns", "a"); ok {
+				t.Fatal("a still cached after MDelete")
+			}
+
+			// Incr keeps the expiration of an existing key and counts a missing one as 0.
+			if n, err := c.Incr(ctx, "ns", "b", 5); err != nil || n != 7 {
+				t.Fatalf("Incr existing = %d, %v, want 7", n, err)
+			}
+			if ttl, ok, err := c.TTL(ctx, "ns", "b"); err != nil || !ok || ttl <= 0 || ttl > time.Minute {
+				t.Fatalf("TTL after Incr = %s, %v, %v", ttl, ok, err)
+			}
+			if n, err := c.Incr(ctx, "ns", "n", -2); err != nil || n != -2 {
+				t.Fatalf("Incr missing = %d, %v, want -2", n, err)
+			}
+			if ttl, ok, err := c.TTL(ctx, "ns", "n"); err != nil || !ok || ttl != 0 {
+				t.Fatalf("TTL of a new counter = %s, %v, %v, want no expiry", ttl, ok, err)
+			}
+
+			if ok, err := c.Expire(ctx, "ns", "c", time.Hour); err != nil || !ok {
+				t.Fatalf("Expire = %v, %v", ok, err)
+			}
+			if ttl, ok, err := c.TTL(ctx, "ns", "c"); err != nil || !ok || ttl <= time.Minute || ttl > time.Hour {
+				t.Fatalf("TTL after Expire = %s, %v, %v", ttl, ok, err)
+			}
+			if ok, err := c.Expire(ctx, "ns", "missing", time.Hour); err != nil || ok {
+				t.Fatalf("Expire missing = %v, %v", ok, err)
+			}
+			if _, ok, err := c.TTL(ctx, "ns", "missing"); err != nil || ok {
+				t.Fatalf("TTL missing = %v, %v", ok, err)
+			}
+
+			if err := c.Clear(ctx, "ns"); err != nil {
+				t.Fatal(err)
+			}
+			if values, _ := c.MGet(ctx, "ns", "b", "c", "n"); len(values) != 0 {
+				t.Fatalf("values left after Clear: %v", values)
+			}
+			if _, ok, _ := c.Get(ctx, "other", "a"); !ok {
+				t.Fatal("Clear removed a key of another namespace")
+			}
+		})
+	}
+}
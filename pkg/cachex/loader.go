@@ -0,0 +1,166 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound is returned by a loader when the value does not exist. The absence is cached
+// for the negative TTL, so repeated lookups of a missing key do not reach the loader.
+var ErrNotFound = errors.New("cachex: not found")
+
+// Loader loads the value of a key on a cache miss.
+type Loader func(ctx context.Context) (string, error)
+
+type loadOptions struct {
+	NegativeTTL time.Duration
+	Beta        float64
+}
+
+type LoadOption func(*loadOptions)
+
+// WithNegativeTTL sets how long a not found result is cached, it defaults to a tenth of the TTL.
+func WithNegativeTTL(ttl time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.NegativeTTL = ttl
+	}
+}
+
+// WithEarlyRefresh tunes the probabilistic early refresh. Values are reloaded in the background
+// before they expire, the more likely the closer to expiry and the slower the loader; beta scales
+// this (1 by default, larger refreshes earlier, 0 disables it).
+func WithEarlyRefresh(beta float64) LoadOption {
+	return func(o *loadOptions) {
+		o.Beta = beta
+	}
+}
+
+// Loaded values are stored with a header carrying their expiry and load duration:
+// "\x00<found 1/0>|<expiry unix ms>|<load duration ms>|<value>".
+// Values without the header, e.g. written with Set, are returned as they are.
+const loadedValueMark = "\x00"
+
+type loadedValue struct {
+	found    bool
+	expiry   int64
+	delta    int64
+	value    string
+	complete bool // Has a header
+}
+
+func (v loadedValue) String() string {
+	found := "0"
+	if v.found {
+		found = "1"
+	}
+	return loadedValueMark + found + "|" + strconv.FormatInt(v.expiry, 10) + "|" + strconv.FormatInt(v.delta, 10) + "|" + v.value
+}
+
+func parseLoadedValue(s string) loadedValue {
+	if !strings.HasPrefix(s, loadedValueMark) {
+		return loadedValue{found: true, value: s}
+	}
+
+	parts := strings.SplitN(s[len(loadedValueMark):], "|", 4)
+	if len(parts) != 4 {
+		return loadedValue{found: true, value: s}
+	}
+	expiry, _ := strconv.ParseInt(parts[1], 10, 64)
+	delta, _ := strconv.ParseInt(parts[2], 10, 64)
+	return loadedValue{
+		found:    parts[0] == "1",
+		expiry:   expiry,
+		delta:    delta,
+		value:    parts[3],
+		complete: true,
+	}
+}
+
+// shouldRefresh implements the XFetch algorithm: refresh when now - delta*beta*ln(rand) passes the expiry.
+func (v loadedValue) shouldRefresh(beta float64) bool {
+	if !v.complete || !v.found || v.expiry == 0 || beta <= 0 {
+		return false
+	}
+	gap := float64(v.delta) * beta * -math.Log(1-rand.Float64())
+	return float64(time.Now().UnixMilli())+gap >= float64(v.expiry)
+}
+
+// loadGroup gives each cache a GetOrLoad on top of its Get and Set.
+type loadGroup struct {
+	group singleflight.Group
+}
+
+// getOrLoad returns the cached value of the key, or calls loader once for all concurrent callers
+// and caches its result for ttl. A zero ttl caches the value without expiry.
+func (g *loadGroup) getOrLoad(ctx context.Context, c Cacher, delimiter, ns, key string, ttl time.Duration, loader Loader, opts ...LoadOption) (string, bool, error) {
+	o := &loadOptions{
+		NegativeTTL: ttl / 10,
+		Beta:        1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.NegativeTTL <= 0 {
+		o.NegativeTTL = time.Second
+	}
+
+	val, ok, err := c.Get(ctx, ns, key)
+	if err != nil {
+		return "", false, err
+	} else if ok {
+		v := parseLoadedValue(val)
+		if v.shouldRefresh(o.Beta) {
+			go func() {
+				_, _, _ = g.load(context.WithoutCancel(ctx), c, delimiter, ns, key, ttl, loader, o)
+			}()
+		}
+		return v.value, v.found, nil
+	}
+
+	return g.load(ctx, c, delimiter, ns, key, ttl, loader, o)
+}
+
+func (g *loadGroup) load(ctx context.Context, c Cacher, delimiter, ns, key string, ttl time.Duration, loader Loader, o *loadOptions) (string, bool, error) {
+	result, err, _ := g.group.Do(ns+delimiter+key, func() (interface{}, error) {
+		// Callers share the result, so one canceled caller must not fail the others.
+		ctx := context.WithoutCancel(ctx)
+
+		start := time.Now()
+		value, err := loader(ctx)
+		v := loadedValue{found: true, value: value, delta: time.Since(start).Milliseconds(), complete: true}
+		exp := ttl
+		if errors.Is(err, ErrNotFound) {
+			v = loadedValue{delta: v.delta, complete: true}
+			exp = o.NegativeTTL
+		} else if err != nil {
+			return nil, err
+		}
+
+		if exp > 0 {
+			v.expiry = start.Add(exp).UnixMilli()
+		}
+		if err := c.Set(ctx, ns, key, v.String(), exp); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	v := result.(loadedValue)
+	return v.value, v.found, nil
+}
+
+// UnwrapLoaded strips the header GetOrLoad stores with a value. found is false for a cached miss.
+func UnwrapLoaded(s string) (value string, found bool) {
+	v := parseLoadedValue(s)
+	return v.value, v.found
+}
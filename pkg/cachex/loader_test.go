@@ -0,0 +1,264 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	errLoad := errors.New("load failed")
+
+	tests := []struct {
+		name      string
+		set       string // Value written with Set before the calls, if any
+		results   []error
+		ttl       time.Duration
+		opts      []LoadOption
+		sleep     time.Duration // Between the two calls
+		wantValue string
+		wantFound bool
+		wantErr   error
+		wantLoads int
+	}{
+		{
+			name:      "loaded once and cached",
+			results:   []error{nil},
+			ttl:       time.Minute,
+			wantValue: "v1",
+			wantFound: true,
+			wantLoads: 1,
+		},
+		{
+			name:      "reloaded after expiry",
+			results:   []error{nil, nil},
+			ttl:       30 * time.Millisecond,
+			opts:      []LoadOption{WithEarlyRefresh(0)},
+			sleep:     60 * time.Millisecond,
+			wantValue: "v2",
+			wantFound: true,
+			wantLoads: 2,
+		},
+		{
+			name:      "not found is cached",
+			results:   []error{ErrNotFound},
+			ttl:       time.Minute,
+			wantFound: false,
+			wantLoads: 1,
+		},
+		{
+			name:      "not found expires with the negative ttl",
+			results:   []error{ErrNotFound, nil},
+			ttl:       time.Minute,
+			opts:      []LoadOption{WithNegativeTTL(30 * time.Millisecond)},
+			sleep:     60 * time.Millisecond,
+			wantValue: "v2",
+			wantFound: true,
+			wantLoads: 2,
+		},
+		{
+			name:      "errors are not cached",
+			results:   []error{errLoad, nil},
+			ttl:       time.Minute,
+			wantValue: "v2",
+			wantFound: true,
+			wantLoads: 2,
+		},
+		{
+			name:      "errors are returned",
+			results:   []error{errLoad, errLoad},
+			ttl:       time.Minute,
+			wantErr:   errLoad,
+			wantLoads: 2,
+		},
+		{
+			name:      "plain values are returned as they are",
+			set:       "plain",
+			ttl:       time.Minute,
+			wantValue: "plain",
+			wantFound: true,
+			wantLoads: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+			if tt.set != "" {
+				if err := c.Set(ctx, "ns", "k", tt.set); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var loads int
+			loader := func(ctx context.Context) (string, error) {
+				err := tt.results[loads]
+				loads++
+				if err != nil {
+					return "", err
+				}
+				return "v" + string(rune('0'+loads)), nil
+			}
+
+			var (
+				value string
+				found bool
+				err   error
+			)
+			for i := 0; i < 2; i++ {
+				if i > 0 {
+					time.Sleep(tt.sleep)
+				}
+				value, found, err = c.GetOrLoad(ctx, "ns", "k", tt.ttl, loader, tt.opts...)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if value != tt.wantValue || found != tt.wantFound {
+				t.Fatalf("got %q, %v, want %q, %v", value, found, tt.wantValue, tt.wantFound)
+			}
+			if loads != tt.wantLoads {
+				t.Fatalf("loader called %d times, want %d", loads, tt.wantLoads)
+			}
+		})
+	}
+}
+
+func TestGetOrLoadShared(t *testing.T) {
+	c := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (string, error) {
+		loads.Add(1)
+		<-release
+		return "v", nil
+	}
+
+	var wg sync.WaitGroup
+	values := make([]string, 10)
+	for i := range values {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values[i], _, _ = c.GetOrLoad(context.Background(), "ns", "k", time.Minute, loader)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("loader called %d times, want 1", n)
+	}
+	for i, v := range values {
+		if v != "v" {
+			t.Fatalf("caller %d got %q", i, v)
+		}
+	}
+}
+
+func TestGetOrLoadEarlyRefresh(t *testing.T) {
+	c := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	loader := func(ctx context.Context) (string, error) {
+		loads.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "v", nil
+	}
+
+	// A large beta refreshes right away, in the background.
+	if _, _, err := c.GetOrLoad(ctx, "ns", "k", time.Minute, loader, WithEarlyRefresh(1e9)); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := c.GetOrLoad(ctx, "ns", "k", time.Minute, loader, WithEarlyRefresh(1e9)); err != nil || !ok || v != "v" {
+		t.Fatalf("got %q, %v, %v", v, ok, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for loads.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := loads.Load(); n != 2 {
+		t.Fatalf("loader called %d times, want a background refresh", n)
+	}
+}
+
+func TestUnwrapLoaded(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantValue string
+		wantFound bool
+	}{
+		{loadedValue{found: true, expiry: 1, delta: 2, value: "a|b"}.String(), "a|b", true},
+		{loadedValue{}.String(), "", false},
+		{"plain", "plain", true},
+		{loadedValueMark + "broken", loadedValueMark + "broken", true},
+	}
+
+	for _, tt := range tests {
+		value, found := UnwrapLoaded(tt.in)
+		if value != tt.wantValue || found != tt.wantFound {
+			t.Errorf("UnwrapLoaded(%q) = %q, %v, want %q, %v", tt.in, value, found, tt.wantValue, tt.wantFound)
+		}
+	}
+}
+
+func TestGetOrLoadWithoutExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		cache func(t *testing.T) Cacher
+	}{
+		{
+			name: "memory",
+			cache: func(t *testing.T) Cacher {
+				return NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+			},
+		},
+		{
+			name: "badger",
+			cache: func(t *testing.T) Cacher {
+				c := NewBadgerCache(BadgerConfig{Path: t.TempDir()})
+				t.Cleanup(func() { _ = c.Close(ctx) })
+				return c
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.cache(t)
+
+			if err := c.Set(ctx, "ns", "plain", "v", 0); err != nil {
+				t.Fatal(err)
+			}
+			if value, ok, err := c.Get(ctx, "ns", "plain"); err != nil || !ok || value != "v" {
+				t.Fatalf("Get = %q, %v, %v, want the value set without expiry", value, ok, err)
+			}
+
+			var loads int
+			loader := func(ctx context.Context) (string, error) {
+				loads++
+				return "loaded", nil
+			}
+			for i := 0; i < 2; i++ {
+				value, found, err := c.GetOrLoad(ctx, "ns", "k", 0, loader)
+				if err != nil || !found || value != "loaded" {
+					t.Fatalf("GetOrLoad = %q, %v, %v", value, found, err)
+				}
+			}
+			if loads != 1 {
+				t.Fatalf("loader called %d times, want 1", loads)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrLockNotHeld is returned by Unlock and Refresh when the lock expired or was acquired by someone else.
+	ErrLockNotHeld = errors.New("cachex: lock not held")
+	// ErrLockUnsupported is returned by NewLocker for caches that can not hold locks.
+	ErrLockUnsupported = errors.New("cachex: cache does not support locks")
+)
+
+// Namespaces of lock keys and their fencing counters.
+const (
+	lockNS  = "lock"
+	fenceNS = "lock-fence"
+)
+
+// fenceTTL is how long a fencing counter outlives the last acquisition of its key. Tokens are
+// seeded from the clock, so they keep growing after an idle counter expired.
+const fenceTTL = 24 * time.Hour
+
+// fenceExpiration returns the expiration of a fencing counter, well beyond the lock TTL.
+func fenceExpiration(ttl time.Duration) time.Duration {
+	return max(fenceTTL, 2*ttl)
+}
+
+// nextToken returns the fencing token following last, which is 0 for a missing counter.
+func nextToken(last int64) int64 {
+	return max(last+1, time.Now().UnixMicro())
+}
+
+// Lock is an acquired lock. Token is a fencing token that grows with every acquisition of the key,
+// so a resource can reject writes carrying a token lower than one it has already seen.
+type Lock struct {
+	Key   string
+	Token int64
+}
+
+// Locker provides mutual exclusion across instances sharing a cache. Locks expire after their TTL,
+// so a crashed holder does not block others forever; long running holders must Refresh in time.
+type Locker interface {
+	// TryLock acquires the key if it is free and reports whether it succeeded.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, bool, error)
+	// Lock waits until the key is acquired or the context is done.
+	Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error)
+	Unlock(ctx context.Context, lock *Lock) error
+	// Refresh resets the TTL of a held lock.
+	Refresh(ctx context.Context, lock *Lock, ttl time.Duration) error
+}
+
+// NewLocker returns the Locker of a cache created by this package. Locks of a tiered cache
+// are held in its remote cache. Other caches return ErrLockUnsupported, since a lock that
+// only excludes within this process would silently break mutual exclusion across instances.
+func NewLocker(c Cacher) (Locker, error) {
+	if tc, ok := c.(*tieredCache); ok {
+		c = tc.remote
+	}
+	if l, ok := c.(Locker); ok {
+		return l, nil
+	}
+	return nil, ErrLockUnsupported
+}
+
+const (
+	minLockRetry = 10 * time.Millisecond
+	maxLockRetry = 500 * time.Millisecond
+)
+
+// waitLock retries tryLock with a growing interval until it succeeds or the context is done.
+func waitLock(ctx context.Context, key string, ttl time.Duration,
+	tryLock func(ctx context.Context, key string, ttl time.Duration) (*Lock, bool, error)) (*Lock, error) {
+	retry := minLockRetry
+	for {
+		lock, ok, err := tryLock(ctx, key, ttl)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			return lock, nil
+		}
+
+		timer := time.NewTimer(retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if retry *= 2; retry > maxLockRetry {
+			retry = maxLockRetry
+		}
+	}
+}
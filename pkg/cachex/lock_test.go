@@ -0,0 +1,143 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLocker(t *testing.T) Locker {
+	t.Helper()
+
+	locker, err := NewLocker(NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return locker
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, l Locker)
+	}{
+		{"free key is acquired", func(t *testing.T, l Locker) {
+			lock, ok, err := l.TryLock(ctx, "k", time.Minute)
+			if err != nil || !ok || lock.Key != "k" || lock.Token <= 0 {
+				t.Fatalf("TryLock = %+v, %v, %v", lock, ok, err)
+			}
+		}},
+		{"held key is refused", func(t *testing.T, l Locker) {
+			mustLock(t, l, "k", time.Minute)
+			if _, ok, err := l.TryLock(ctx, "k", time.Minute); err != nil || ok {
+				t.Fatalf("TryLock on held key = %v, %v", ok, err)
+			}
+		}},
+		{"other keys are independent", func(t *testing.T, l Locker) {
+			mustLock(t, l, "a", time.Minute)
+			mustLock(t, l, "b", time.Minute)
+		}},
+		{"unlocked key is acquired again with a higher token", func(t *testing.T, l Locker) {
+			first := mustLock(t, l, "k", time.Minute)
+			if err := l.Unlock(ctx, first); err != nil {
+				t.Fatal(err)
+			}
+			second := mustLock(t, l, "k", time.Minute)
+			if second.Token <= first.Token {
+				t.Fatalf("token %d after %d", second.Token, first.Token)
+			}
+		}},
+		{"expired lock is acquired again", func(t *testing.T, l Locker) {
+			mustLock(t, l, "k", 20*time.Millisecond)
+			time.Sleep(50 * time.Millisecond)
+			mustLock(t, l, "k", time.Minute)
+		}},
+		{"stale token can not unlock or refresh", func(t *testing.T, l Locker) {
+			stale := mustLock(t, l, "k", 20*time.Millisecond)
+			time.Sleep(50 * time.Millisecond)
+			mustLock(t, l, "k", time.Minute)
+			if err := l.Unlock(ctx, stale); !errors.Is(err, ErrLockNotHeld) {
+				t.Fatalf("Unlock = %v, want ErrLockNotHeld", err)
+			}
+			if err := l.Refresh(ctx, stale, time.Minute); !errors.Is(err, ErrLockNotHeld) {
+				t.Fatalf("Refresh = %v, want ErrLockNotHeld", err)
+			}
+		}},
+		{"refresh extends the lock", func(t *testing.T, l Locker) {
+			lock := mustLock(t, l, "k", 50*time.Millisecond)
+			if err := l.Refresh(ctx, lock, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if _, ok, _ := l.TryLock(ctx, "k", time.Minute); ok {
+				t.Fatal("refreshed lock expired")
+			}
+		}},
+		{"lock waits for the holder", func(t *testing.T, l Locker) {
+			held := mustLock(t, l, "k", time.Minute)
+			time.AfterFunc(50*time.Millisecond, func() { _ = l.Unlock(ctx, held) })
+
+			start := time.Now()
+			lock, err := l.Lock(ctx, "k", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			} else if time.Since(start) < 50*time.Millisecond || lock.Token <= held.Token {
+				t.Fatalf("acquired after %s with token %d", time.Since(start), lock.Token)
+			}
+		}},
+		{"lock gives up with the context", func(t *testing.T, l Locker) {
+			mustLock(t, l, "k", time.Minute)
+			ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			if _, err := l.Lock(ctx, "k", time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Lock = %v, want DeadlineExceeded", err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newTestLocker(t))
+		})
+	}
+}
+
+func mustLock(t *testing.T, l Locker, key string, ttl time.Duration) *Lock {
+	t.Helper()
+
+	lock, ok, err := l.TryLock(context.Background(), key, ttl)
+	if err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf("key %s is already locked", key)
+	}
+	return lock
+}
+
+// plainCache is a Cacher that can not hold locks.
+type plainCache struct {
+	Cacher
+}
+
+func TestNewLocker(t *testing.T) {
+	mem := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+
+	if _, err := NewLocker(plainCache{mem}); !errors.Is(err, ErrLockUnsupported) {
+		t.Fatalf("NewLocker(plain) = %v, want ErrLockUnsupported", err)
+	}
+
+	// A tiered cache locks in its remote cache, shared with the other instances.
+	tiered := NewTieredCache(mem, TieredConfig{})
+	locker, err := NewLocker(tiered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustLock(t, locker, "k", time.Minute)
+	remote, _ := NewLocker(mem)
+	if _, ok, _ := remote.TryLock(context.Background(), "k", time.Minute); ok {
+		t.Fatal("tiered lock is not held in the remote cache")
+	}
+}
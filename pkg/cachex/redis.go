@@ -2,27 +2,25 @@ package cachex
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"gin-admin/pkg/redisx"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-type RedisConfig struct {
-	Addr     string
-	Username string
-	Password string
-	DB       int
-}
+type RedisConfig = redisx.Config
 
+// NewRedisCache uses the client shared through redisx.Get, closing the cache releases it.
 func NewRedisCache(cfg RedisConfig, opts ...Option) Cacher {
-	cli := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		Username: cfg.Username,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+	cli, err := redisx.Get(cfg)
+	if err != nil {
+		panic(err)
+	}
 	return newRedisCache(cli, opts...)
 }
 
@@ -34,6 +32,11 @@ func NewRedisCacheWithClusterClient(cli *redis.ClusterClient, opts ...Option) Ca
 	return newRedisCache(cli, opts...)
 }
 
+// NewRedisCacheWithUniversalClient accepts any client, e.g. a shared one from redisx.Get.
+func NewRedisCacheWithUniversalClient(cli redis.UniversalClient, opts ...Option) Cacher {
+	return newRedisCache(cli, opts...)
+}
+
 func newRedisCache(cli redisClienter, opts ...Option) Cacher {
 	defaultOpts := &options{
 		Delimiter: defaultDelimiter,
@@ -54,12 +57,23 @@ type redisClienter interface {
 	Exists(ctx context.Context, keys ...string) *redis.IntCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
 	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Incr(ctx context.Context, key string) *redis.IntCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
+	PExpire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	Persist(ctx context.Context, key string) *redis.BoolCmd
+	PTTL(ctx context.Context, key string) *redis.DurationCmd
+	Pipeline() redis.Pipeliner
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
 	Close() error
 }
 
 type redisCache struct {
-	opts *options
-	cli  redisClienter
+	opts  *options
+	cli   redisClienter
+	loads loadGroup
 }
 
 func (a *redisCache) getKey(ns, key string) string {
@@ -80,10 +94,12 @@ func (a *redisCache) Get(ctx context.Context, ns, key string) (string, bool, err
 	cmd := a.cli.Get(ctx, a.getKey(ns, key))
 	if err := cmd.Err(); err != nil {
 		if err == redis.Nil {
+			a.opts.emit(ns, EventMiss)
 			return "", false, nil
 		}
 		return "", false, err
 	}
+	a.opts.emit(ns, EventHit)
 	return cmd.Val(), true, nil
 }
 
@@ -125,41 +141,263 @@ func (a *redisCache) GetAndDelete(ctx context.Context, ns, key string) (string,
 	return value, true, nil
 }
 
-func (a *redisCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
-	var cursor uint64 = 0
-LB_LOOP:
-	for {
-		cmd := a.cli.Scan(ctx, cursor, a.getKey(ns, "*"), 100)
-		if err := cmd.Err(); err != nil {
-			return err
-		}
+// masterer is implemented by cluster clients and by the shared clients from redisx.
+type masterer interface {
+	ForEachMaster(ctx context.Context, fn func(ctx context.Context, client *redis.Client) error) error
+}
+
+var errStopScan = errors.New("stop scan")
+
+// scan passes the keys matching the pattern to fn in batches until fn returns false.
+// SCAN only sees the keys of the node it runs on, so on a cluster every master is scanned.
+func (a *redisCache) scan(ctx context.Context, match string, count int64, fn func(keys []string) (bool, error)) error {
+	var mu sync.Mutex
+	stopped := false
+	scanNode := func(ctx context.Context, cli redisClienter) error {
+		var cursor uint64
+		for {
+			keys, c, err := cli.Scan(ctx, cursor, match, count).Result()
+			if err != nil {
+				return err
+			}
 
-		keys, c, err := cmd.Result()
-		if err != nil {
-			return err
+			if len(keys) > 0 {
+				mu.Lock()
+				next := !stopped
+				if next {
+					next, err = fn(keys)
+					stopped = !next
+				}
+				mu.Unlock()
+				if err != nil {
+					return err
+				} else if !next {
+					return errStopScan
+				}
+			}
+
+			if c == 0 {
+				return nil
+			}
+			cursor = c
 		}
+	}
+
+	var err error
+	if m, ok := a.cli.(masterer); ok {
+		err = m.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scanNode(ctx, client)
+		})
+	} else {
+		err = scanNode(ctx, a.cli)
+	}
+	if errors.Is(err, errStopScan) {
+		return nil
+	}
+	return err
+}
 
+func (a *redisCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	prefix := a.getKey(ns, "")
+	return a.scan(ctx, a.getKey(ns, "*"), 100, func(keys []string) (bool, error) {
 		for _, key := range keys {
 			cmd := a.cli.Get(ctx, key)
 			if err := cmd.Err(); err != nil {
 				if err == redis.Nil {
 					continue
 				}
-				return err
+				return false, err
 			}
-			if next := fn(ctx, strings.TrimPrefix(key, a.getKey(ns, "")), cmd.Val()); !next {
-				break LB_LOOP
+			if next := fn(ctx, strings.TrimPrefix(key, prefix), cmd.Val()); !next {
+				return false, nil
 			}
 		}
+		return true, nil
+	})
+}
 
-		if c == 0 {
-			break
+func (a *redisCache) IterateKeys(ctx context.Context, ns string, fn func(ctx context.Context, key string) bool) error {
+	prefix := a.getKey(ns, "")
+	return a.scan(ctx, a.getKey(ns, "*"), 100, func(keys []string) (bool, error) {
+		for _, key := range keys {
+			if !fn(ctx, strings.TrimPrefix(key, prefix)) {
+				return false, nil
+			}
 		}
-		cursor = c
+		return true, nil
+	})
+}
+
+// Batch operations are pipelined instead of using MGET/DEL with several keys,
+// which a cluster rejects when the keys are in different slots.
+func (a *redisCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	pipe := a.cli.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, a.getKey(ns, key))
 	}
-	return nil
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(keys))
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			if err == redis.Nil {
+				a.opts.emit(ns, EventMiss)
+				continue
+			}
+			return nil, err
+		}
+		a.opts.emit(ns, EventHit)
+		values[keys[i]] = cmd.Val()
+	}
+	return values, nil
+}
+
+func (a *redisCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	pipe := a.cli.Pipeline()
+	for key, value := range values {
+		pipe.Set(ctx, a.getKey(ns, key), value, exp)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (a *redisCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := a.cli.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, a.getKey(ns, key))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// redisIncrScript increments the key and sets the expiration if the key has none.
+const redisIncrScript = `local n = redis.call("incrby", KEYS[1], ARGV[1]) if redis.call("pttl", KEYS[1]) == -1 then redis.call("pexpire", KEYS[1], ARGV[2]) end return n`
+
+func (a *redisCache) Incr(ctx context.Context, ns, key string, delta int64, expiration ...time.Duration) (int64, error) {
+	if len(expiration) == 0 || expiration[0] <= 0 {
+		return a.cli.IncrBy(ctx, a.getKey(ns, key), delta).Result()
+	}
+	return a.cli.Eval(ctx, redisIncrScript, []string{a.getKey(ns, key)}, delta, expiration[0].Milliseconds()).Int64()
+}
+
+func (a *redisCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	if expiration <= 0 {
+		if err := a.cli.Persist(ctx, a.getKey(ns, key)).Err(); err != nil {
+			return false, err
+		}
+		// PERSIST reports false for keys without an expiration as well.
+		return a.Exists(ctx, ns, key)
+	}
+	return a.cli.PExpire(ctx, a.getKey(ns, key), expiration).Result()
+}
+
+func (a *redisCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	ttl, err := a.cli.PTTL(ctx, a.getKey(ns, key)).Result()
+	if err != nil {
+		return 0, false, err
+	}
+
+	// The reply is -2 for a missing key and -1 for a key without expiration.
+	switch ttl {
+	case -2:
+		return 0, false, nil
+	case -1:
+		return 0, true, nil
+	}
+	return ttl, true, nil
+}
+
+func (a *redisCache) Clear(ctx context.Context, ns string) error {
+	return a.scan(ctx, a.getKey(ns, "*"), 500, func(keys []string) (bool, error) {
+		pipe := a.cli.Pipeline()
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		_, err := pipe.Exec(ctx)
+		return err == nil, err
+	})
+}
+
+func (a *redisCache) GetOrLoad(ctx context.Context, ns, key string, ttl time.Duration, loader Loader, opts ...LoadOption) (string, bool, error) {
+	return a.loads.getOrLoad(ctx, a, a.opts.Delimiter, ns, key, ttl, loader, opts...)
 }
 
 func (a *redisCache) Close(ctx context.Context) error {
 	return a.cli.Close()
 }
+
+func (a *redisCache) Publish(ctx context.Context, channel, msg string) error {
+	return a.cli.Publish(ctx, channel, msg).Err()
+}
+
+// Subscribe delivers messages until unsubscribe is called. The client reconnects on its own,
+// messages published while it is disconnected are lost.
+func (a *redisCache) Subscribe(ctx context.Context, channel string, fn func(msg string)) func() error {
+	ps := a.cli.Subscribe(ctx, channel)
+	go func() {
+		for msg := range ps.Channel() {
+			fn(msg.Payload)
+		}
+	}()
+	return ps.Close
+}
+
+// The lock value is its fencing token, so only the holder of the token can release or extend it.
+// The fence script bumps the counter to at least the seed from the clock and renews its expiration.
+const (
+	redisFenceScript   = `local t = redis.call("incr", KEYS[1]) local s = tonumber(ARGV[1]) if t < s then t = s redis.call("set", KEYS[1], ARGV[1]) end redis.call("pexpire", KEYS[1], ARGV[2]) return t`
+	redisUnlockScript  = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`
+	redisRefreshScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`
+)
+
+func (a *redisCache) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, bool, error) {
+	// Tokens consumed by failed attempts are skipped, which keeps them increasing.
+	token, err := a.cli.Eval(ctx, redisFenceScript, []string{a.getKey(fenceNS, key)},
+		nextToken(0), fenceExpiration(ttl).Milliseconds()).Int64()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := a.cli.SetNX(ctx, a.getKey(lockNS, key), strconv.FormatInt(token, 10), ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return &Lock{Key: key, Token: token}, true, nil
+}
+
+func (a *redisCache) Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return waitLock(ctx, key, ttl, a.TryLock)
+}
+
+func (a *redisCache) Unlock(ctx context.Context, lock *Lock) error {
+	n, err := a.cli.Eval(ctx, redisUnlockScript, []string{a.getKey(lockNS, lock.Key)}, strconv.FormatInt(lock.Token, 10)).Int64()
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (a *redisCache) Refresh(ctx context.Context, lock *Lock, ttl time.Duration) error {
+	n, err := a.cli.Eval(ctx, redisRefreshScript, []string{a.getKey(lockNS, lock.Key)},
+		strconv.FormatInt(lock.Token, 10), ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+package cachex
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+type TieredConfig struct {
+	Size       int           // Max number of locally cached keys
+	TTL        time.Duration // Max age of a local copy, bounds staleness when an invalidation is lost
+	Channel    string        // Pub/sub channel for invalidations, used when the remote cache is Redis
+	Namespaces []string      // Namespaces cached locally, all if empty
+}
+
+// NewTieredCache puts an in-process LRU in front of a remote cache. Writes go to the remote cache
+// first and are broadcast to the other instances, which drop their local copies of the key.
+func NewTieredCache(remote Cacher, cfg TieredConfig, opts ...Option) Cacher {
+	defaultOpts := &options{
+		Delimiter: defaultDelimiter,
+	}
+	for _, o := range opts {
+		o(defaultOpts)
+	}
+
+	if cfg.Size <= 0 {
+		cfg.Size = 10000
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = "cachex:invalidate"
+	}
+
+	c := &tieredCache{
+		opts:     defaultOpts,
+		cfg:      cfg,
+		remote:   remote,
+		instance: xid.New().String(),
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+	if len(cfg.Namespaces) > 0 {
+		c.namespaces = make(map[string]bool, len(cfg.Namespaces))
+		for _, ns := range cfg.Namespaces {
+			c.namespaces[ns] = true
+		}
+	}
+
+	if ps, ok := remote.(PubSuber); ok {
+		c.pubsub = ps
+		c.unsubscribe = ps.Subscribe(context.Background(), cfg.Channel, c.onInvalidate)
+	}
+	return c
+}
+
+// PubSuber is implemented by remote caches shared between instances.
+type PubSuber interface {
+	Publish(ctx context.Context, channel, msg string) error
+	Subscribe(ctx context.Context, channel string, fn func(msg string)) (unsubscribe func() error)
+}
+
+// PubSubOf returns the pub/sub of the cache shared between instances, looking through
+// a tiered cache to its remote. It reports false for process-local caches.
+func PubSubOf(c Cacher) (PubSuber, bool) {
+	if tc, ok := c.(*tieredCache); ok {
+		return tc.pubsub, tc.pubsub != nil
+	}
+	ps, ok := c.(PubSuber)
+	return ps, ok
+}
+
+type tieredEntry struct {
+	key       string
+	ns        string
+	value     string
+	expiresAt time.Time
+}
+
+type tieredCache struct {
+	opts        *options
+	cfg         TieredConfig
+	remote      Cacher
+	pubsub      PubSuber
+	unsubscribe func() error
+	instance    string
+	namespaces  map[string]bool
+	mu          sync.Mutex
+	items       map[string]*list.Element
+	lru         *list.List
+	loads       loadGroup
+}
+
+func (a *tieredCache) getKey(ns, key string) string {
+	return ns + a.opts.Delimiter + key
+}
+
+func (a *tieredCache) cacheable(ns string) bool {
+	return a.namespaces == nil || a.namespaces[ns]
+}
+
+func (a *tieredCache) getLocal(ns, key string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	el, ok := a.items[a.getKey(ns, key)]
+	if !ok {
+		return "", false
+	}
+
+	entry := el.Value.(*tieredEntry)
+	if time.Now().After(entry.expiresAt) {
+		a.lru.Remove(el)
+		delete(a.items, entry.key)
+		return "", false
+	}
+	a.lru.MoveToFront(el)
+	return entry.value, true
+}
+
+func (a *tieredCache) setLocal(ns, key, value string, expiration time.Duration) {
+	ttl := a.cfg.TTL
+	if expiration > 0 && expiration < ttl {
+		ttl = expiration
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(ns, key)
+	if el, ok := a.items[k]; ok {
+		entry := el.Value.(*tieredEntry)
+		entry.value = value
+		entry.expiresAt = time.Now().Add(ttl)
+		a.lru.MoveToFront(el)
+		return
+	}
+
+	a.items[k] = a.lru.PushFront(&tieredEntry{
+		key:       k,
+		ns:        ns,
+		value:     value,
+		expiresAt: time.Now().Add(ttl),
+	})
+
+	for a.lru.Len() > a.cfg.Size {
+		el := a.lru.Back()
+		entry := el.Value.(*tieredEntry)
+		a.lru.Remove(el)
+		delete(a.items, entry.key)
+		a.opts.emit(entry.ns, EventEviction)
+	}
+}
+
+// cacheRemote keeps a local copy of a remote hit, no longer than the remote cache keeps the key, so
+// that short lived keys and cached misses of GetOrLoad expire on time. The expiry of loaded values is
+// in their header, the others cost a TTL call, which the following local hits make up for.
+func (a *tieredCache) cacheRemote(ctx context.Context, ns, key, value string) {
+	var ttl time.Duration
+	if v := parseLoadedValue(value); v.complete {
+		if v.expiry > 0 {
+			ttl = time.Until(time.UnixMilli(v.expiry))
+			if ttl <= 0 {
+				return
+			}
+		}
+	} else {
+		remaining, ok, err := a.remote.TTL(ctx, ns, key)
+		if err != nil || !ok {
+			return
+		}
+		ttl = remaining
+	}
+	a.setLocal(ns, key, value, ttl)
+}
+
+func (a *tieredCache) deleteLocal(ns, key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(ns, key)
+	if el, ok := a.items[k]; ok {
+		a.lru.Remove(el)
+		delete(a.items, k)
+	}
+}
+
+// invalidate drops the key locally and tells the other instances to do the same.
+func (a *tieredCache) invalidate(ctx context.Context, ns, key string) error {
+	if !a.cacheable(ns) {
+		return nil
+	}
+
+	a.deleteLocal(ns, key)
+	if a.pubsub == nil {
+		return nil
+	}
+	return a.pubsub.Publish(ctx, a.cfg.Channel, a.instance+"\n"+ns+"\n"+key)
+}
+
+func (a *tieredCache) clearLocal(ns string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for el := a.lru.Front(); el != nil; {
+		next := el.Next()
+		if entry := el.Value.(*tieredEntry); entry.ns == ns {
+			a.lru.Remove(el)
+			delete(a.items, entry.key)
+		}
+		el = next
+	}
+}
+
+// Messages are "<instance>\n<ns>\n<key>", or "<instance>\n<ns>" when the namespace was cleared.
+func (a *tieredCache) onInvalidate(msg string) {
+	parts := strings.SplitN(msg, "\n", 3)
+	if len(parts) < 2 || parts[0] == a.instance {
+		return
+	}
+
+	if len(parts) == 2 {
+		a.clearLocal(parts[1])
+	} else {
+		a.deleteLocal(parts[1], parts[2])
+	}
+	a.opts.emit(parts[1], EventInvalidate)
+}
+
+func (a *tieredCache) Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error {
+	if err := a.remote.Set(ctx, ns, key, value, expiration...); err != nil {
+		return err
+	}
+	if err := a.invalidate(ctx, ns, key); err != nil {
+		return err
+	}
+
+	if a.cacheable(ns) {
+		var exp time.Duration
+		if len(expiration) > 0 {
+			exp = expiration[0]
+		}
+		a.setLocal(ns, key, value, exp)
+	}
+	return nil
+}
+
+func (a *tieredCache) Get(ctx context.Context, ns, key string) (string, bool, error) {
+	if !a.cacheable(ns) {
+		return a.remote.Get(ctx, ns, key)
+	}
+
+	if value, ok := a.getLocal(ns, key); ok {
+		a.opts.emit(ns, EventLocalHit)
+		return value, true, nil
+	}
+
+	value, ok, err := a.remote.Get(ctx, ns, key)
+	if err != nil {
+		return "", false, err
+	} else if !ok {
+		a.opts.emit(ns, EventMiss)
+		return "", false, nil
+	}
+
+	a.opts.emit(ns, EventRemoteHit)
+	a.cacheRemote(ctx, ns, key, value)
+	return value, true, nil
+}
+
+func (a *tieredCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
+	value, ok, err := a.remote.GetAndDelete(ctx, ns, key)
+	if err != nil {
+		return "", false, err
+	}
+	if err := a.invalidate(ctx, ns, key); err != nil {
+		return "", false, err
+	}
+	return value, ok, nil
+}
+
+func (a *tieredCache) Exists(ctx context.Context, ns, key string) (bool, error) {
+	if a.cacheable(ns) {
+		if _, ok := a.getLocal(ns, key); ok {
+			return true, nil
+		}
+	}
+	return a.remote.Exists(ctx, ns, key)
+}
+
+func (a *tieredCache) Delete(ctx context.Context, ns, key string) error {
+	if err := a.remote.Delete(ctx, ns, key); err != nil {
+		return err
+	}
+	return a.invalidate(ctx, ns, key)
+}
+
+func (a *tieredCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	return a.remote.Iterator(ctx, ns, fn)
+}
+
+func (a *tieredCache) GetOrLoad(ctx context.Context, ns, key string, ttl time.Duration, loader Loader, opts ...LoadOption) (string, bool, error) {
+	return a.loads.getOrLoad(ctx, a, a.opts.Delimiter, ns, key, ttl, loader, opts...)
+}
+
+func (a *tieredCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	if !a.cacheable(ns) {
+		return a.remote.MGet(ctx, ns, keys...)
+	}
+
+	values := make(map[string]string, len(keys))
+	var missing []string
+	for _, key := range keys {
+		if value, ok := a.getLocal(ns, key); ok {
+			a.opts.emit(ns, EventLocalHit)
+			values[key] = value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	remoteValues, err := a.remote.MGet(ctx, ns, missing...)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range missing {
+		value, ok := remoteValues[key]
+		if !ok {
+			a.opts.emit(ns, EventMiss)
+			continue
+		}
+		a.opts.emit(ns, EventRemoteHit)
+		a.cacheRemote(ctx, ns, key, value)
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (a *tieredCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	if err := a.remote.MSet(ctx, ns, values, expiration...); err != nil {
+		return err
+	}
+	for key := range values {
+		if err := a.invalidate(ctx, ns, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *tieredCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	if err := a.remote.MDelete(ctx, ns, keys...); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := a.invalidate(ctx, ns, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *tieredCache) Incr(ctx context.Context, ns, key string, delta int64, expiration ...time.Duration) (int64, error) {
+	n, err := a.remote.Incr(ctx, ns, key, delta, expiration...)
+	if err != nil {
+		return 0, err
+	}
+	return n, a.invalidate(ctx, ns, key)
+}
+
+func (a *tieredCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	ok, err := a.remote.Expire(ctx, ns, key, expiration)
+	if err != nil {
+		return false, err
+	}
+	return ok, a.invalidate(ctx, ns, key)
+}
+
+func (a *tieredCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	return a.remote.TTL(ctx, ns, key)
+}
+
+func (a *tieredCache) Clear(ctx context.Context, ns string) error {
+	if err := a.remote.Clear(ctx, ns); err != nil {
+		return err
+	}
+	if !a.cacheable(ns) {
+		return nil
+	}
+
+	a.clearLocal(ns)
+	if a.pubsub == nil {
+		return nil
+	}
+	return a.pubsub.Publish(ctx, a.cfg.Channel, a.instance+"\n"+ns)
+}
+
+func (a *tieredCache) Close(ctx context.Context) error {
+	if a.unsubscribe != nil {
+		_ = a.unsubscribe()
+	}
+	return a.remote.Close(ctx)
+}
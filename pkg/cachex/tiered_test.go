@@ -0,0 +1,206 @@
+package cachex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pubsubCache is a remote cache with an in-process pub/sub, shared by the tiered caches of a test.
+type pubsubCache struct {
+	Cacher
+	mu   sync.Mutex
+	subs map[string][]func(msg string)
+}
+
+func newPubSubCache() *pubsubCache {
+	return &pubsubCache{
+		Cacher: NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}),
+		subs:   make(map[string][]func(msg string)),
+	}
+}
+
+func (a *pubsubCache) Publish(ctx context.Context, channel, msg string) error {
+	a.mu.Lock()
+	subs := append([]func(msg string){}, a.subs[channel]...)
+	a.mu.Unlock()
+	for _, fn := range subs {
+		fn(msg)
+	}
+	return nil
+}
+
+func (a *pubsubCache) Subscribe(ctx context.Context, channel string, fn func(msg string)) func() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.subs[channel] = append(a.subs[channel], fn)
+	return func() error { return nil }
+}
+
+// eventCounter counts the events of a tiered cache.
+type eventCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *eventCounter) handle(ns, event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[event]++
+}
+
+func (c *eventCounter) get(event string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[event]
+}
+
+func TestTieredCacheLocalCopy(t *testing.T) {
+	ctx := context.Background()
+	remote := newPubSubCache()
+	var events eventCounter
+	c := NewTieredCache(remote, TieredConfig{TTL: time.Minute}, WithEventHandler(events.handle))
+
+	_ = remote.Set(ctx, "ns", "k", "v1")
+	for i := 0; i < 3; i++ {
+		if v, ok, err := c.Get(ctx, "ns", "k"); err != nil || !ok || v != "v1" {
+			t.Fatalf("Get = %q, %v, %v", v, ok, err)
+		}
+	}
+	if events.get(EventRemoteHit) != 1 || events.get(EventLocalHit) != 2 {
+		t.Fatalf("events %v, want 1 remote and 2 local hits", events.counts)
+	}
+
+	// A change made behind the tiered cache is only seen once the local copy expires.
+	_ = remote.Set(ctx, "ns", "k", "v2")
+	if v, _, _ := c.Get(ctx, "ns", "k"); v != "v1" {
+		t.Fatalf("Get = %q, want the local copy", v)
+	}
+}
+
+func TestTieredCacheRemoteTTL(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		set  func(t *testing.T, remote Cacher)
+		get  func(c Cacher) bool // Reports whether the key is found
+	}{
+		{"short lived key", func(t *testing.T, remote Cacher) {
+			if err := remote.Set(ctx, "ns", "k", "v", 50*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+		}, func(c Cacher) bool {
+			_, ok, _ := c.Get(ctx, "ns", "k")
+			return ok
+		}},
+		{"short lived key in a batch", func(t *testing.T, remote Cacher) {
+			if err := remote.Set(ctx, "ns", "k", "v", 50*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+		}, func(c Cacher) bool {
+			values, _ := c.MGet(ctx, "ns", "k")
+			_, ok := values["k"]
+			return ok
+		}},
+		{"cached miss of GetOrLoad", func(t *testing.T, remote Cacher) {
+			_, _, err := remote.GetOrLoad(ctx, "ns", "k", time.Minute, func(ctx context.Context) (string, error) {
+				return "", ErrNotFound
+			}, WithNegativeTTL(50*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, func(c Cacher) bool {
+			_, ok, _ := c.Get(ctx, "ns", "k")
+			return ok
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := newPubSubCache()
+			c := NewTieredCache(remote, TieredConfig{TTL: time.Minute})
+			tt.set(t, remote)
+			if !tt.get(c) {
+				t.Fatal("key not found")
+			}
+			time.Sleep(100 * time.Millisecond)
+			if tt.get(c) {
+				t.Fatal("local copy outlived the remote key")
+			}
+		})
+	}
+}
+
+func TestTieredCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	remote := newPubSubCache()
+	var events eventCounter
+	a := NewTieredCache(remote, TieredConfig{TTL: time.Minute})
+	b := NewTieredCache(remote, TieredConfig{TTL: time.Minute}, WithEventHandler(events.handle))
+
+	tests := []struct {
+		name   string
+		change func() error // Made on a, b must not keep its local copy
+		want   string       // Value read on b afterwards, "" if missing
+	}{
+		{"set", func() error { return a.Set(ctx, "ns", "k", "new") }, "new"},
+		{"delete", func() error { return a.Delete(ctx, "ns", "k") }, ""},
+		{"batch set", func() error { return a.MSet(ctx, "ns", map[string]string{"k": "new"}) }, "new"},
+		{"increment", func() error { _, err := a.Incr(ctx, "ns", "k", 1); return err }, "2"},
+		{"clear", func() error { return a.Clear(ctx, "ns") }, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = a.Set(ctx, "ns", "k", "1")
+			if v, _, _ := b.Get(ctx, "ns", "k"); v != "1" {
+				t.Fatalf("b read %q before the change", v)
+			}
+			invalidated := events.get(EventInvalidate)
+
+			if err := tt.change(); err != nil {
+				t.Fatal(err)
+			}
+			if v, _, _ := b.Get(ctx, "ns", "k"); v != tt.want {
+				t.Fatalf("b read %q after the change, want %q", v, tt.want)
+			}
+			if events.get(EventInvalidate) == invalidated {
+				t.Fatal("no invalidation reported")
+			}
+		})
+	}
+}
+
+func TestTieredCacheLimits(t *testing.T) {
+	ctx := context.Background()
+	remote := newPubSubCache()
+	var events eventCounter
+	c := NewTieredCache(remote, TieredConfig{Size: 2, TTL: time.Minute, Namespaces: []string{"local"}},
+		WithEventHandler(events.handle))
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = remote.Set(ctx, "local", key, key)
+		_, _, _ = c.Get(ctx, "local", key)
+	}
+	if n := events.get(EventEviction); n != 1 {
+		t.Fatalf("%d evictions, want 1", n)
+	}
+	// The least recently used key was evicted and comes from the remote cache again.
+	_, _, _ = c.Get(ctx, "local", "a")
+	if n := events.get(EventRemoteHit); n != 4 {
+		t.Fatalf("%d remote hits, want 4", n)
+	}
+
+	// Other namespaces are not kept locally.
+	_ = remote.Set(ctx, "remote", "k", "v1")
+	_, _, _ = c.Get(ctx, "remote", "k")
+	_ = remote.Set(ctx, "remote", "k", "v2")
+	if v, _, _ := c.Get(ctx, "remote", "k"); v != "v2" {
+		t.Fatalf("Get = %q, want the remote value", v)
+	}
+}
@@ -0,0 +1,140 @@
+package cachex
+
+import (
+	"context"
+	"gin-admin/pkg/encoding/json"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes typed values into cache strings.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+var (
+	JSON    Codec = jsonCodec{}
+	Msgpack Codec = msgpackCodec{} // Smaller and faster than JSON, but not human readable
+)
+
+// Typed stores values of type T in one namespace of a cache.
+type Typed[T any] struct {
+	cache Cacher
+	codec Codec
+	ns    string
+}
+
+func NewTyped[T any](cache Cacher, codec Codec, ns string) *Typed[T] {
+	return &Typed[T]{cache: cache, codec: codec, ns: ns}
+}
+
+func NewJSON[T any](cache Cacher, ns string) *Typed[T] {
+	return NewTyped[T](cache, JSON, ns)
+}
+
+func NewMsgpack[T any](cache Cacher, ns string) *Typed[T] {
+	return NewTyped[T](cache, Msgpack, ns)
+}
+
+func (a *Typed[T]) decode(s string) (T, error) {
+	var v T
+	err := a.codec.Unmarshal([]byte(s), &v)
+	return v, err
+}
+
+func (a *Typed[T]) encode(v T) (string, error) {
+	b, err := a.codec.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (a *Typed[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	s, ok, err := a.cache.Get(ctx, a.ns, key)
+	if err != nil || !ok {
+		return zero, false, err
+	}
+
+	v, err := a.decode(s)
+	if err != nil {
+		return zero, false, err
+	}
+	return v, true, nil
+}
+
+func (a *Typed[T]) Set(ctx context.Context, key string, value T, expiration ...time.Duration) error {
+	s, err := a.encode(value)
+	if err != nil {
+		return err
+	}
+	return a.cache.Set(ctx, a.ns, key, s, expiration...)
+}
+
+func (a *Typed[T]) Delete(ctx context.Context, key string) error {
+	return a.cache.Delete(ctx, a.ns, key)
+}
+
+// MGet returns the values of the existing keys.
+func (a *Typed[T]) MGet(ctx context.Context, keys ...string) (map[string]T, error) {
+	values, err := a.cache.MGet(ctx, a.ns, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]T, len(values))
+	for key, s := range values {
+		v, err := a.decode(s)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = v
+	}
+	return result, nil
+}
+
+func (a *Typed[T]) MSet(ctx context.Context, values map[string]T, expiration ...time.Duration) error {
+	encoded := make(map[string]string, len(values))
+	for key, v := range values {
+		s, err := a.encode(v)
+		if err != nil {
+			return err
+		}
+		encoded[key] = s
+	}
+	return a.cache.MSet(ctx, a.ns, encoded, expiration...)
+}
+
+// GetOrLoad is Cacher.GetOrLoad for typed values, the loader returns ErrNotFound for missing values.
+func (a *Typed[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error), opts ...LoadOption) (T, bool, error) {
+	var zero T
+	s, ok, err := a.cache.GetOrLoad(ctx, a.ns, key, ttl, func(ctx context.Context) (string, error) {
+		v, err := loader(ctx)
+		if err != nil {
+			return "", err
+		}
+		return a.encode(v)
+	}, opts...)
+	if err != nil || !ok {
+		return zero, false, err
+	}
+
+	v, err := a.decode(s)
+	if err != nil {
+		return zero, false, err
+	}
+	return v, true, nil
+}
@@ -0,0 +1,136 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type typedTestValue struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+func TestTyped(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{"json", JSON},
+		{"msgpack", Msgpack},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+			typed := NewTyped[typedTestValue](c, tt.codec, "ns")
+			want := typedTestValue{Name: "a", Count: 1, Tags: []string{"x", "y"}}
+
+			if _, ok, err := typed.Get(ctx, "k"); err != nil || ok {
+				t.Fatalf("Get missing = %v, %v", ok, err)
+			}
+
+			if err := typed.Set(ctx, "k", want, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			got, ok, err := typed.Get(ctx, "k")
+			if err != nil || !ok || !equalTypedTestValue(got, want) {
+				t.Fatalf("Get = %+v, %v, %v, want %+v", got, ok, err, want)
+			}
+
+			if err := typed.Delete(ctx, "k"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := typed.Get(ctx, "k"); ok {
+				t.Fatal("value still cached after Delete")
+			}
+		})
+	}
+}
+
+func TestTypedBatch(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	typed := NewMsgpack[typedTestValue](c, "ns")
+
+	values := map[string]typedTestValue{
+		"a": {Name: "a", Count: 1},
+		"b": {Name: "b", Count: 2},
+	}
+	if err := typed.MSet(ctx, values, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := typed.MGet(ctx, "a", "b", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("MGet returned %d values, want 2", len(got))
+	}
+	for key, want := range values {
+		if !equalTypedTestValue(got[key], want) {
+			t.Fatalf("MGet[%s] = %+v, want %+v", key, got[key], want)
+		}
+	}
+}
+
+func TestTypedDecodeError(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	typed := NewJSON[typedTestValue](c, "ns")
+
+	if err := c.Set(ctx, "ns", "k", "not json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := typed.Get(ctx, "k"); err == nil || ok {
+		t.Fatalf("Get = %v, %v, want a decode error", ok, err)
+	}
+	if _, err := typed.MGet(ctx, "k"); err == nil {
+		t.Fatal("MGet returned no decode error")
+	}
+}
+
+func TestTypedGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	typed := NewJSON[typedTestValue](c, "ns")
+
+	var loads int
+	loader := func(ctx context.Context) (typedTestValue, error) {
+		loads++
+		return typedTestValue{Name: "loaded", Count: loads}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		got, ok, err := typed.GetOrLoad(ctx, "k", time.Minute, loader)
+		if err != nil || !ok || got.Name != "loaded" || got.Count != 1 {
+			t.Fatalf("GetOrLoad = %+v, %v, %v", got, ok, err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("loader called %d times, want 1", loads)
+	}
+
+	missing := func(ctx context.Context) (typedTestValue, error) {
+		return typedTestValue{}, ErrNotFound
+	}
+	if _, ok, err := typed.GetOrLoad(ctx, "missing", time.Minute, missing); err != nil || ok {
+		t.Fatalf("GetOrLoad missing = %v, %v", ok, err)
+	}
+}
+
+func equalTypedTestValue(a, b typedTestValue) bool {
+	if a.Name != b.Name || a.Count != b.Count || len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for i := range a.Tags {
+		if a.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
+	return true
+}
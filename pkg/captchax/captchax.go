@@ -0,0 +1,162 @@
+package captchax
+
+import (
+	"bytes"
+	cryptorand "crypto/rand"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/LyricTian/captcha"
+	"github.com/LyricTian/captcha/store"
+)
+
+const (
+	TypeDigit = "digit" // Random digits to type in, can also be played as audio
+	TypeMath  = "math"  // Arithmetic expression to solve, image only
+)
+
+var (
+	ErrNotFound         = captcha.ErrNotFound
+	ErrAudioUnsupported = errors.New("captcha: audio is not available for this captcha")
+)
+
+// A math captcha is stored as the mark followed by the operands and the operator index,
+// digit captchas store the digits 0-9, so the two never collide.
+const mathMark = 0xff
+
+var mathOperators = []string{"+", "-", "x"}
+
+// Manager creates and verifies captchas in a store, unlike the captcha package it doesn't use global state.
+type Manager struct {
+	store store.Store
+}
+
+func NewManager(s store.Store) *Manager {
+	return &Manager{store: s}
+}
+
+// New creates a captcha of the type and returns its id. length is the number of digits of a digit captcha.
+func (m *Manager) New(typ string, length int) string {
+	id := newID()
+	m.store.Set(id, m.generate(typ, length))
+	return id
+}
+
+// newID returns 128 random bits from crypto/rand, so IDs handed to other clients can not be predicted.
+func newID() string {
+	return cryptorand.Text()
+}
+
+func (m *Manager) generate(typ string, length int) []byte {
+	if typ == TypeMath {
+		op := rand.IntN(len(mathOperators))
+		a, b := rand.IntN(20)+1, rand.IntN(20)+1
+		switch mathOperators[op] {
+		case "-":
+			if a < b {
+				a, b = b, a
+			}
+		case "x":
+			b = rand.IntN(9) + 1
+		}
+		return []byte{mathMark, byte(a), byte(op), byte(b)}
+	}
+
+	if length <= 0 {
+		length = captcha.DefaultLen
+	}
+	return captcha.RandomDigits(length)
+}
+
+// Reload replaces the solution of a captcha with a new one of the same type and reports whether it exists.
+func (m *Manager) Reload(id string) bool {
+	old := m.store.Get(id, false)
+	if old == nil {
+		return false
+	}
+
+	if isMath(old) {
+		m.store.Set(id, m.generate(TypeMath, 0))
+	} else {
+		m.store.Set(id, m.generate(TypeDigit, len(old)))
+	}
+	return true
+}
+
+func (m *Manager) WriteImage(w io.Writer, id string, width, height int) error {
+	d := m.store.Get(id, false)
+	if d == nil {
+		return ErrNotFound
+	}
+
+	if isMath(d) {
+		return writeTextImage(w, mathExpression(d)+"=?", width, height)
+	}
+	_, err := captcha.NewImage(id, d, width, height).WriteTo(w)
+	return err
+}
+
+// WriteAudio writes a WAV file reading out the digits in the language, English if lang isn't supported.
+func (m *Manager) WriteAudio(w io.Writer, id string, lang string) error {
+	d := m.store.Get(id, false)
+	if d == nil {
+		return ErrNotFound
+	} else if isMath(d) {
+		return ErrAudioUnsupported
+	}
+
+	_, err := captcha.NewAudio(id, d, lang).WriteTo(w)
+	return err
+}
+
+// Verify checks the answer and deletes the captcha, so that each one can be used only once.
+func (m *Manager) Verify(id, answer string) bool {
+	answer = strings.TrimSpace(answer)
+	if id == "" || answer == "" {
+		return false
+	}
+
+	d := m.store.Get(id, true)
+	if d == nil {
+		return false
+	}
+
+	if isMath(d) {
+		n, err := strconv.Atoi(answer)
+		return err == nil && n == mathResult(d)
+	}
+
+	digits := make([]byte, 0, len(answer))
+	for _, c := range answer {
+		switch {
+		case '0' <= c && c <= '9':
+			digits = append(digits, byte(c-'0'))
+		case c == ' ' || c == ',':
+		default:
+			return false
+		}
+	}
+	return bytes.Equal(digits, d)
+}
+
+func isMath(d []byte) bool {
+	return len(d) == 4 && d[0] == mathMark
+}
+
+func mathExpression(d []byte) string {
+	return strconv.Itoa(int(d[1])) + mathOperators[d[2]%3] + strconv.Itoa(int(d[3]))
+}
+
+func mathResult(d []byte) int {
+	a, b := int(d[1]), int(d[3])
+	switch mathOperators[d[2]%3] {
+	case "-":
+		return a - b
+	case "x":
+		return a * b
+	}
+	return a + b
+}
@@ -0,0 +1,62 @@
+package captchax
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"math/rand/v2"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/inconsolata"
+	"golang.org/x/image/math/fixed"
+)
+
+// writeTextImage renders text with a bitmap font, then scales it up to the requested size with a wave
+// distortion and draws noise over it, similar to the digit images of the captcha package.
+func writeTextImage(w io.Writer, text string, width, height int) error {
+	face := inconsolata.Bold8x16
+	src := image.NewAlpha(image.Rect(0, 0, font.MeasureString(face, text).Ceil()+4, face.Height+4))
+	d := &font.Drawer{
+		Dst:  src,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.P(2, face.Ascent+2),
+	}
+	d.DrawString(text)
+
+	fg := color.RGBA{R: uint8(rand.IntN(128)), G: uint8(rand.IntN(128)), B: uint8(rand.IntN(128)), A: 0xff}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	sb := src.Bounds()
+	amplitude := float64(height) / 12
+	period := float64(width) / (2 + rand.Float64()*2)
+	phase := rand.Float64() * 2 * math.Pi
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dy := amplitude * math.Sin(2*math.Pi*float64(x)/period+phase)
+			sx := x * sb.Dx() / width
+			sy := int((float64(y) + dy) * float64(sb.Dy()) / float64(height))
+			if sy < 0 || sy >= sb.Dy() {
+				continue
+			}
+			if src.AlphaAt(sx, sy).A > 0x7f {
+				img.Set(x, y, fg)
+			}
+		}
+	}
+
+	for i := 0; i < width*height/20; i++ {
+		img.Set(rand.IntN(width), rand.IntN(height), fg)
+	}
+	for i := 0; i < 3; i++ {
+		y0, y1 := rand.IntN(height), rand.IntN(height)
+		for x := 0; x < width; x++ {
+			img.Set(x, y0+(y1-y0)*x/width, fg)
+		}
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,46 @@
+package captchax
+
+import (
+	"context"
+	"gin-admin/pkg/cachex"
+	"time"
+
+	"github.com/LyricTian/captcha/store"
+)
+
+// NewCacheStore keeps captchas in a cache, so that every instance behind a load balancer can verify them.
+func NewCacheStore(cache cachex.Cacher, ns string, expiration time.Duration) store.Store {
+	return &cacheStore{
+		cache:      cache,
+		ns:         ns,
+		expiration: expiration,
+	}
+}
+
+type cacheStore struct {
+	cache      cachex.Cacher
+	ns         string
+	expiration time.Duration
+}
+
+// Set can't report errors, a captcha that failed to be stored is simply not found later.
+func (s *cacheStore) Set(id string, digits []byte) {
+	_ = s.cache.Set(context.Background(), s.ns, id, string(digits), s.expiration)
+}
+
+func (s *cacheStore) Get(id string, clear bool) []byte {
+	var (
+		value string
+		ok    bool
+		err   error
+	)
+	if clear {
+		value, ok, err = s.cache.GetAndDelete(context.Background(), s.ns, id)
+	} else {
+		value, ok, err = s.cache.Get(context.Background(), s.ns, id)
+	}
+	if err != nil || !ok {
+		return nil
+	}
+	return []byte(value)
+}
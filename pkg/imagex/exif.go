@@ -0,0 +1,106 @@
+package imagex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const tagOrientation = 0x0112
+
+// ReadOrientation returns the EXIF orientation (1-8) of a JPEG stream, or 1 if the
+// stream has no EXIF data.
+func ReadOrientation(r io.Reader) int {
+	br := &byteReader{r: r}
+	if br.u16(binary.BigEndian) != 0xFFD8 {
+		return 1
+	}
+
+	for br.err == nil {
+		marker := br.u16(binary.BigEndian)
+		if marker&0xFF00 != 0xFF00 || marker == 0xFFDA || marker == 0xFFD9 {
+			return 1
+		}
+
+		size := int(br.u16(binary.BigEndian)) - 2
+		if size < 0 || br.err != nil {
+			return 1
+		}
+
+		if marker != 0xFFE1 {
+			br.skip(size)
+			continue
+		}
+
+		data := br.bytes(size)
+		if len(data) >= 6 && bytes.Equal(data[:6], []byte("Exif\x00\x00")) {
+			return parseOrientation(data[6:])
+		}
+	}
+	return 1
+}
+
+func parseOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == tagOrientation {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+type byteReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *byteReader) bytes(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	buf := make([]byte, n)
+	_, b.err = io.ReadFull(b.r, buf)
+	return buf
+}
+
+func (b *byteReader) u16(order binary.ByteOrder) uint16 {
+	buf := b.bytes(2)
+	if b.err != nil {
+		return 0
+	}
+	return order.Uint16(buf)
+}
+
+func (b *byteReader) skip(n int) {
+	if b.err != nil {
+		return
+	}
+	_, b.err = io.CopyN(io.Discard, b.r, int64(n))
+}
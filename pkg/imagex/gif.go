@@ -0,0 +1,95 @@
+package imagex
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// IsAnimatedGIF reports whether r is a GIF with more than one frame. It walks the block
+// structure without decoding any pixels, and reports false for other formats and for
+// truncated streams, which are left to the decoder to reject.
+func IsAnimatedGIF(r io.Reader) (bool, error) {
+	animated, err := isAnimatedGIF(bufio.NewReader(r))
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
+	return animated, err
+}
+
+func isAnimatedGIF(br *bufio.Reader) (bool, error) {
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return false, nil
+	} else if !bytes.HasPrefix(header, []byte("GIF87a")) && !bytes.HasPrefix(header, []byte("GIF89a")) {
+		return false, nil
+	}
+
+	// Global color table
+	if header[10]&0x80 != 0 {
+		if err := skipBytes(br, 3<<(header[10]&0x07+1)); err != nil {
+			return false, err
+		}
+	}
+
+	frames := 0
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return false, err
+		}
+
+		switch b {
+		case 0x21: // Extension
+			if _, err := br.ReadByte(); err != nil {
+				return false, err
+			}
+			if err := skipSubBlocks(br); err != nil {
+				return false, err
+			}
+		case 0x2C: // Image descriptor
+			if frames++; frames > 1 {
+				return true, nil
+			}
+			desc := make([]byte, 9)
+			if _, err := io.ReadFull(br, desc); err != nil {
+				return false, err
+			}
+			if desc[8]&0x80 != 0 {
+				if err := skipBytes(br, 3<<(desc[8]&0x07+1)); err != nil {
+					return false, err
+				}
+			}
+			// LZW minimum code size, then the image data
+			if _, err := br.ReadByte(); err != nil {
+				return false, err
+			}
+			if err := skipSubBlocks(br); err != nil {
+				return false, err
+			}
+		case 0x3B: // Trailer
+			return false, nil
+		default:
+			return false, nil
+		}
+	}
+}
+
+func skipBytes(br *bufio.Reader, n int) error {
+	_, err := br.Discard(n)
+	return err
+}
+
+func skipSubBlocks(br *bufio.Reader) error {
+	for {
+		n, err := br.ReadByte()
+		if err != nil {
+			return err
+		} else if n == 0 {
+			return nil
+		}
+		if err := skipBytes(br, int(n)); err != nil {
+			return err
+		}
+	}
+}
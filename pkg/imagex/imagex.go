@@ -0,0 +1,245 @@
+// Package imagex provides image decoding, orientation fixes, resizing and encoding in pure Go.
+// Images are always re-encoded, which drops all embedded metadata such as EXIF GPS tags.
+package imagex
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+
+	ModeFit  = "fit"  // Scale down to fit within the box, keeping the aspect ratio
+	ModeFill = "fill" // Scale and center crop to cover the box exactly
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooLarge          = errors.New("image dimensions too large")
+	// ErrAnimated is returned for animated GIFs, which are kept as they are because
+	// re-encoding would drop all frames but the first.
+	ErrAnimated = errors.New("animated image")
+)
+
+type Options struct {
+	Width     int
+	Height    int
+	Mode      string
+	Format    string // Output format, empty keeps the source format when it can be encoded
+	Quality   int    // JPEG quality, 1-100
+	MaxPixels int    // Larger images are rejected before they are decoded, unlimited if 0
+}
+
+// IsSupported reports whether images of the content type can be decoded.
+func IsSupported(contentType string) bool {
+	switch strings.ToLower(contentType) {
+	case "image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp":
+		return true
+	}
+	return false
+}
+
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+func Ext(format string) string {
+	if format == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+// ParseFormat normalizes a format name or file extension.
+func ParseFormat(s string) (string, error) {
+	switch strings.TrimPrefix(strings.ToLower(s), ".") {
+	case "jpg", "jpeg":
+		return FormatJPEG, nil
+	case "png":
+		return FormatPNG, nil
+	case "gif":
+		return FormatGIF, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Decode decodes an image and applies its EXIF orientation. The dimensions are read from
+// the header first, images of more than maxPixels pixels are rejected with ErrTooLarge
+// so a small file can not claim a huge canvas. A maxPixels of 0 disables the check.
+func Decode(r io.ReadSeeker, maxPixels int) (image.Image, string, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, "", err
+	} else if maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return nil, "", ErrTooLarge
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	orientation := ReadOrientation(r)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	img, format, err := image.Decode(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return Orient(img, orientation), format, nil
+}
+
+// Orient transforms the image so that it is displayed upright for the given EXIF orientation.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// Resize scales the image into the width x height box. A zero width or height is derived
+// from the aspect ratio. Images are never scaled up.
+func Resize(img image.Image, width, height int, mode string) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 || (width <= 0 && height <= 0) {
+		return img
+	}
+
+	if width <= 0 {
+		width = w * height / h
+	} else if height <= 0 {
+		height = h * width / w
+	}
+
+	srcRect := b
+	if mode == ModeFill {
+		// Crop the source to the target aspect ratio first.
+		if w*height > h*width {
+			cw := h * width / height
+			srcRect = image.Rect(b.Min.X+(w-cw)/2, b.Min.Y, b.Min.X+(w-cw)/2+cw, b.Max.Y)
+		} else {
+			ch := w * height / width
+			srcRect = image.Rect(b.Min.X, b.Min.Y+(h-ch)/2, b.Max.X, b.Min.Y+(h-ch)/2+ch)
+		}
+		if width > srcRect.Dx() || height > srcRect.Dy() {
+			width, height = srcRect.Dx(), srcRect.Dy()
+		}
+	} else {
+		if w*height > h*width {
+			height = max(1, h*width/w)
+		} else {
+			width = max(1, w*height/h)
+		}
+		if width > w || height > h {
+			width, height = w, h
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Rect, img, srcRect, draw.Src, nil)
+	return dst
+}
+
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case FormatJPEG:
+		if quality <= 0 || quality > 100 {
+			quality = 85
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatGIF:
+		return gif.Encode(w, img, nil)
+	}
+	return ErrUnsupportedFormat
+}
+
+// Process decodes, orients, resizes and re-encodes an image. It returns the encoded
+// bytes and the output format, or ErrAnimated for GIFs with more than one frame.
+func Process(r io.ReadSeeker, opts Options) ([]byte, string, error) {
+	if animated, err := IsAnimatedGIF(r); err != nil {
+		return nil, "", err
+	} else if animated {
+		return nil, "", ErrAnimated
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	img, format, err := Decode(r, opts.MaxPixels)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if opts.Format != "" {
+		format = opts.Format
+	} else if _, err := ParseFormat(format); err != nil {
+		// Formats we can only decode (webp, bmp) are converted.
+		format = FormatPNG
+	}
+
+	img = Resize(img, opts.Width, opts.Height, opts.Mode)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, format, opts.Quality); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), format, nil
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if v, ok := img.(*image.NRGBA); ok && v.Rect.Min == (image.Point{}) {
+		return v
+	}
+
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Rect, img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,63 @@
+// Package jobx provides a registry of named job handlers and a cron based scheduler.
+package jobx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Handler runs a job. Args is the raw argument string stored with the job and
+// anything written to out is kept as the execution output.
+type Handler func(ctx context.Context, args string, out io.Writer) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]Handler)
+)
+
+// Register makes a handler available to jobs under the given name. It panics if the name is already taken.
+func Register(name string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+
+	if _, ok := handlers[name]; ok {
+		panic(fmt.Sprintf("jobx: handler %s registered twice", name))
+	}
+	handlers[name] = handler
+}
+
+// Lookup returns the handler registered under the given name.
+func Lookup(name string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+
+	handler, ok := handlers[name]
+	return handler, ok
+}
+
+// Names returns the sorted names of all registered handlers.
+func Names() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The seconds field is optional, so both "*/5 * * * *" and "0 */5 * * * *" are accepted,
+// as well as descriptors like "@daily" and "@every 1h30m".
+var parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseSpec parses a cron expression.
+func ParseSpec(spec string) (cron.Schedule, error) {
+	return parser.Parse(spec)
+}
@@ -0,0 +1,126 @@
+package jobx
+
+import (
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type entry struct {
+	id      cron.EntryID
+	spec    string
+	version string
+}
+
+// firedSchedule remembers the activations cron asked for, so a job can tell the time it
+// was scheduled for. Cron starts the job before asking for the following activation, so
+// the fire time is the latest answer that is not in the future.
+type firedSchedule struct {
+	cron.Schedule
+	mu   sync.Mutex
+	prev time.Time
+	next time.Time
+}
+
+func (s *firedSchedule) Next(t time.Time) time.Time {
+	next := s.Schedule.Next(t)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prev, s.next = s.next, next
+	return next
+}
+
+func (s *firedSchedule) fired() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next.After(time.Now()) {
+		return s.prev
+	}
+	return s.next
+}
+
+// Scheduler keeps one cron entry per job ID. Entries are only replaced when the spec or version changes,
+// so it is safe to call Set for every job on each sync.
+type Scheduler struct {
+	cron    *cron.Cron
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+func NewScheduler(loc *time.Location) *Scheduler {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Scheduler{
+		cron:    cron.New(cron.WithParser(parser), cron.WithLocation(loc)),
+		entries: make(map[string]entry),
+	}
+}
+
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Set schedules fn for the job, replacing the previous entry if spec or version changed.
+// fn receives the scheduled fire time, which is the same on every instance sharing the spec.
+func (s *Scheduler) Set(id, spec, version string, fn func(at time.Time)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[id]; ok {
+		if e.spec == spec && e.version == version {
+			return nil
+		}
+		s.cron.Remove(e.id)
+		delete(s.entries, id)
+	}
+
+	schedule, err := ParseSpec(spec)
+	if err != nil {
+		return err
+	}
+	fs := &firedSchedule{Schedule: schedule}
+	entryID := s.cron.Schedule(fs, cron.FuncJob(func() { fn(fs.fired()) }))
+	s.entries[id] = entry{id: entryID, spec: spec, version: version}
+	return nil
+}
+
+func (s *Scheduler) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[id]; ok {
+		s.cron.Remove(e.id)
+		delete(s.entries, id)
+	}
+}
+
+// IDs returns the IDs of all scheduled jobs.
+func (s *Scheduler) IDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Next returns the next time the job runs, or the zero time if it is not scheduled.
+func (s *Scheduler) Next(id string) time.Time {
+	s.mu.Lock()
+	e, ok := s.entries[id]
+	s.mu.Unlock()
+	if !ok {
+		return time.Time{}
+	}
+	return s.cron.Entry(e.id).Next
+}
+
+// Stop stops the scheduler and waits for running callbacks to return.
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 
 	"github.com/pelletier/go-toml"
 	"go.uber.org/zap"
@@ -40,6 +42,37 @@ type HookConfig struct {
 
 type HookHandlerFunc func(ctx context.Context, hookCfg *HookConfig) (*Hook, error)
 
+var (
+	entryHooksLock sync.Mutex
+	entryHooks     atomic.Pointer[[]func(zapcore.Entry) error]
+)
+
+// AddEntryHook registers fn to be called for every entry written by the logger built by
+// InitWithConfig, also when it is added after the logger was built. Hooks run on the
+// logging goroutine and must not block.
+func AddEntryHook(fn func(zapcore.Entry) error) {
+	entryHooksLock.Lock()
+	defer entryHooksLock.Unlock()
+
+	var hooks []func(zapcore.Entry) error
+	if v := entryHooks.Load(); v != nil {
+		hooks = append(hooks, *v...)
+	}
+	hooks = append(hooks, fn)
+	entryHooks.Store(&hooks)
+}
+
+func runEntryHooks(entry zapcore.Entry) error {
+	if v := entryHooks.Load(); v != nil {
+		for _, fn := range *v {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func LoadConfigFromToml(filename string) (*LoggerConfig, error) {
 	cfg := &Config{}
 	buf, err := os.ReadFile(filename)
@@ -109,6 +142,7 @@ func InitWithConfig(ctx context.Context, cfg *LoggerConfig, hookHandle ...HookHa
 		zap.WithCaller(true),
 		zap.AddStacktrace(zap.ErrorLevel),
 		zap.AddCallerSkip(skip),
+		zap.Hooks(runEntryHooks),
 	)
 
 	for _, h := range cfg.Hooks {
@@ -2,9 +2,10 @@ package mail
 
 import (
 	"context"
-	"gopkg.in/gomail.v2"
 	"sync"
 	"time"
+
+	"gopkg.in/gomail.v2"
 )
 
 var (
@@ -18,6 +19,10 @@ func SetSender(sender *SmtpSender) {
 	})
 }
 
+func Enabled() bool {
+	return globalSender != nil
+}
+
 func Send(ctx context.Context, to []string, cc []string, bcc []string, subject string, body string, file ...string) error {
 	return globalSender.Send(ctx, to, cc, bcc, subject, body, file...)
 }
@@ -26,6 +31,19 @@ func SendTo(ctx context.Context, to string, subject string, body string, file ..
 	return globalSender.SendTo(ctx, to, subject, body, file...)
 }
 
+func SendMessage(ctx context.Context, msg *Message) error {
+	return globalSender.SendMessage(ctx, msg)
+}
+
+type Message struct {
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Body    string // HTML
+	Files   []string
+}
+
 type SmtpSender struct {
 	SmtpHost string
 	Port     int
@@ -36,15 +54,30 @@ type SmtpSender struct {
 }
 
 func (s *SmtpSender) Send(ctx context.Context, to []string, cc []string, bcc []string, subject string, body string, file ...string) error {
+	return s.SendMessage(ctx, &Message{
+		To:      to,
+		Cc:      cc,
+		Bcc:     bcc,
+		Subject: subject,
+		Body:    body,
+		Files:   file,
+	})
+}
+
+func (s *SmtpSender) SendMessage(ctx context.Context, msg *Message) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", m.FormatAddress(s.FromMail, s.FromName))
-	m.SetHeader("To", to...)
-	m.SetHeader("Cc", cc...)
-	m.SetHeader("Bcc", bcc...)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", body)
+	m.SetHeader("To", msg.To...)
+	if len(msg.Cc) > 0 {
+		m.SetHeader("Cc", msg.Cc...)
+	}
+	if len(msg.Bcc) > 0 {
+		m.SetHeader("Bcc", msg.Bcc...)
+	}
+	m.SetHeader("Subject", msg.Subject)
+	m.SetBody("text/html", msg.Body)
 
-	for _, f := range file {
+	for _, f := range msg.Files {
 		m.Attach(f)
 	}
 
@@ -52,16 +85,22 @@ func (s *SmtpSender) Send(ctx context.Context, to []string, cc []string, bcc []s
 	return d.DialAndSend(m)
 }
 
+// SendTo sends to a single receiver, retrying up to 3 times with an increasing delay.
 func (s *SmtpSender) SendTo(ctx context.Context, to string, subject string, body string, file ...string) error {
 	var err error
 	for i := 0; i < 3; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(i) * 500 * time.Millisecond):
+			}
+		}
+
 		err = s.Send(ctx, []string{to}, nil, nil, subject, body, file...)
 		if err == nil {
-			time.Sleep(time.Millisecond * 500)
-			continue
+			return nil
 		}
-		err = nil
-		break
 	}
 	return err
 }
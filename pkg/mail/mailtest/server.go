@@ -0,0 +1,161 @@
+// Package mailtest provides an in-process SMTP server that records received messages,
+// for exercising mail delivery without a real mail server.
+package mailtest
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+type Server struct {
+	listener net.Listener
+	lock     sync.Mutex
+	messages []*Message
+	failures int
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a random local port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// FailNext makes the next n transactions fail with a temporary error.
+func (s *Server) FailNext(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = n
+}
+
+func (s *Server) Messages() []*Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
+
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(code int, msg string) {
+		_, _ = conn.Write([]byte(strconv.Itoa(code) + " " + msg + "\r\n"))
+	}
+
+	reply(220, "mailtest ready")
+	msg := new(Message)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply(250, "mailtest")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.lock.Lock()
+			fail := s.failures > 0
+			if fail {
+				s.failures--
+			}
+			s.lock.Unlock()
+
+			if fail {
+				reply(451, "temporary failure")
+				continue
+			}
+			msg = &Message{From: trimAddress(line[len("MAIL FROM:"):])}
+			reply(250, "OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, trimAddress(line[len("RCPT TO:"):]))
+			reply(250, "OK")
+		case cmd == "DATA":
+			reply(354, "end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" || l == ".\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+
+			s.lock.Lock()
+			s.messages = append(s.messages, msg)
+			s.lock.Unlock()
+			msg = new(Message)
+			reply(250, "OK")
+		case cmd == "RSET":
+			msg = new(Message)
+			reply(250, "OK")
+		case cmd == "NOOP":
+			reply(250, "OK")
+		case cmd == "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+func trimAddress(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	return strings.Trim(s, "<>")
+}
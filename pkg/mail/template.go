@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Templates holds named mail templates. Layouts are loaded from layouts/*.html and shared
+// by every template; each other *.html file is a template named after its file name
+// without extension. A template defines a "subject" block and its body, usually by
+// defining "content" and invoking a layout:
+//
+//	{{define "subject"}}Welcome {{.Name}}{{end}}
+//	{{define "content"}}<p>Hello {{.Name}}</p>{{end}}
+//	{{template "layout" .}}
+type Templates struct {
+	templates map[string]*template.Template
+}
+
+func LoadTemplates(fsys fs.FS) (*Templates, error) {
+	layouts := template.New("")
+	layoutFiles, err := fs.Glob(fsys, "layouts/*.html")
+	if err != nil {
+		return nil, err
+	}
+	if len(layoutFiles) > 0 {
+		if layouts, err = layouts.ParseFS(fsys, layoutFiles...); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := fs.Glob(fsys, "*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{templates: make(map[string]*template.Template)}
+	for _, name := range files {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		tpl, err := layouts.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		key := strings.TrimSuffix(path.Base(name), ".html")
+		if tpl, err = tpl.New(key).Parse(string(b)); err != nil {
+			return nil, err
+		}
+		t.templates[key] = tpl
+	}
+	return t, nil
+}
+
+func (t *Templates) Has(name string) bool {
+	_, ok := t.templates[name]
+	return ok
+}
+
+// Render executes the named template and returns the subject and HTML body.
+func (t *Templates) Render(name string, data interface{}) (string, string, error) {
+	tpl, ok := t.templates[name]
+	if !ok {
+		return "", "", fmt.Errorf("mail template %q not found", name)
+	}
+
+	var subject bytes.Buffer
+	if tpl.Lookup("subject") == nil {
+		return "", "", errors.New("mail template " + name + " does not define a subject")
+	} else if err := tpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", err
+	}
+
+	var body bytes.Buffer
+	if err := tpl.ExecuteTemplate(&body, name, data); err != nil {
+		return "", "", err
+	}
+	// The subject is a header, not HTML, so undo the escaping of html/template.
+	return strings.TrimSpace(html.UnescapeString(subject.String())), body.String(), nil
+}
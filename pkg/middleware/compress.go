@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+type CompressConfig struct {
+	Enable              bool
+	SkippedPathPrefixes []string
+	ContentTypes        []string // Media types to compress
+	MinLength           int      // Smaller responses are sent as is
+	Brotli              bool     // Prefer br over gzip when the client accepts both
+}
+
+var DefaultCompressConfig = CompressConfig{
+	ContentTypes: []string{
+		"application/json",
+		"application/javascript",
+		"application/xml",
+		"image/svg+xml",
+		"text/css",
+		"text/csv",
+		"text/html",
+		"text/javascript",
+		"text/plain",
+		"text/xml",
+	},
+	MinLength: 1024,
+	Brotli:    true,
+}
+
+var (
+	gzipWriterPool   = sync.Pool{New: func() any { w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression); return w }}
+	brotliWriterPool = sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, brotli.DefaultCompression) }}
+)
+
+// CompressWithConfig compresses the responses with an allowed content type once they reach MinLength.
+// Responses that already have a Content-Encoding, e.g. precompressed static files, are left alone.
+func CompressWithConfig(config CompressConfig) gin.HandlerFunc {
+	if !config.Enable {
+		return Empty()
+	}
+	if len(config.ContentTypes) == 0 {
+		config.ContentTypes = DefaultCompressConfig.ContentTypes
+	}
+
+	return func(c *gin.Context) {
+		if SkippedPathPrefixes(c, config.SkippedPathPrefixes...) || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), config.Brotli)
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			config:         &config,
+			encoding:       encoding,
+		}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiateEncoding picks br or gzip from an Accept-Encoding header, "" if neither is acceptable.
+// A wildcard stands for gzip unless gzip is listed on its own, e.g. refused with q=0.
+func negotiateEncoding(header string, allowBrotli bool) string {
+	if allowBrotli && acceptsEncoding(header, "br") {
+		return "br"
+	}
+	if q, listed := encodingQuality(header, "gzip"); listed {
+		if q > 0 {
+			return "gzip"
+		}
+	} else if acceptsEncoding(header, "*") {
+		return "gzip"
+	}
+	return ""
+}
+
+// compressWriter holds the body back until it knows whether to compress: when MinLength is reached,
+// the handler flushes or the response ends.
+type compressWriter struct {
+	gin.ResponseWriter
+	config   *CompressConfig
+	encoding string
+	buf      []byte
+	decided  bool
+	enc      io.WriteCloser
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.config.MinLength {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.decided || len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	h := w.Header()
+	switch status := w.Status(); {
+	case status < http.StatusOK, status == http.StatusNoContent, status == http.StatusPartialContent, status == http.StatusNotModified:
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || len(w.buf) < w.config.MinLength {
+		return false
+	}
+
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf)
+		h.Set("Content-Type", contentType)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return slices.Contains(w.config.ContentTypes, mediaType)
+}
+
+// decide sets the headers, which are still unsent, and writes the buffered body.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	if w.shouldCompress() {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		h.Add("Vary", "Accept-Encoding")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// The compressed bytes differ, so a strong ETag no longer applies to them.
+			h.Set("ETag", "W/"+etag)
+		}
+
+		switch w.encoding {
+		case "br":
+			bw := brotliWriterPool.Get().(*brotli.Writer)
+			bw.Reset(w.ResponseWriter)
+			w.enc = bw
+		default:
+			gw := gzipWriterPool.Get().(*gzip.Writer)
+			gw.Reset(w.ResponseWriter)
+			w.enc = gw
+		}
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return
+		}
+		_ = w.decide()
+	}
+	if w.enc == nil {
+		return
+	}
+
+	_ = w.enc.Close()
+	switch enc := w.enc.(type) {
+	case *brotli.Writer:
+		enc.Reset(nil)
+		brotliWriterPool.Put(enc)
+	case *gzip.Writer:
+		enc.Reset(nil)
+		gzipWriterPool.Put(enc)
+	}
+	w.enc = nil
+}
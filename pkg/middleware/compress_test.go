@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header      string
+		allowBrotli bool
+		want        string
+	}{
+		{"", true, ""},
+		{"identity", true, ""},
+		{"gzip", true, "gzip"},
+		{"br", true, "br"},
+		{"br", false, ""},
+		{"gzip, deflate, br", true, "br"},
+		{"gzip, deflate, br", false, "gzip"},
+		{"GZIP", true, "gzip"},
+		{"br;q=0, gzip;q=0.5", true, "gzip"},
+		{"br; q=0.0, gzip", true, "gzip"},
+		{"gzip;Q=0", true, ""},
+		{"*", true, "gzip"},
+		{"*;q=0", true, ""},
+		{"gzip;q=0, *", true, ""},
+		{"br;q=0, *", true, "gzip"},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header, tt.allowBrotli); got != tt.want {
+			t.Errorf("negotiateEncoding(%q, %v) = %q, want %q", tt.header, tt.allowBrotli, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		encoding string
+		want     bool
+	}{
+		{"gzip", "gzip", true},
+		{"gzip", "br", false},
+		{" br , gzip ", "gzip", true},
+		{"gzip;q=0.1", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"gzip;q=0.000", "gzip", false},
+		{"gzip;q=x", "gzip", true},
+		{"xgzip", "gzip", false},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsEncoding(tt.header, tt.encoding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", tt.header, tt.encoding, got, tt.want)
+		}
+	}
+}
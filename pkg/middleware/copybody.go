@@ -60,7 +60,7 @@ func CopyBodyWithConfig(config CopyBodyConfig) gin.HandlerFunc {
 		c.Request.Body.Close()
 		bf := bytes.NewBuffer(requestBody)
 		c.Request.Body = io.NopCloser(bf)
-		c.Set(util.ResBodyKey, requestBody)
+		c.Set(util.ReqVBodyKey, requestBody)
 		c.Next()
 
 	}
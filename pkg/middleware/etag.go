@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"gin-admin/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ETagConfig struct {
+	Enable              bool
+	SkippedPathPrefixes []string
+}
+
+// ETagWithConfig makes util.ResJSON tag the GET responses with a hash of their body and answer
+// 304 Not Modified when the If-None-Match header of the request holds it.
+func ETagWithConfig(config ETagConfig) gin.HandlerFunc {
+	if !config.Enable {
+		return Empty()
+	}
+
+	return func(c *gin.Context) {
+		if !SkippedPathPrefixes(c, config.SkippedPathPrefixes...) {
+			c.Set(util.ETagKey, true)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/encoding/json"
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/util"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type IdempotencyConfig struct {
+	Enable              bool
+	AllowedPathPrefixes []string
+	SkippedPathPrefixes []string
+	Methods             []string
+	HeaderKey           string
+	Expiration          time.Duration // How long a response is replayed
+	LockExpiration      time.Duration // How long a request may stay in flight, in case the instance dies
+	MaxContentLen       int64         // Largest body read for the fingerprint when CopyBody has not run
+	Cache               cachex.Cacher // Must support locks, see cachex.NewLocker
+	Namespace           string
+}
+
+var DefaultIdempotencyConfig = IdempotencyConfig{
+	Methods:        []string{http.MethodPost, http.MethodPut, http.MethodDelete},
+	HeaderKey:      "Idempotency-Key",
+	Expiration:     24 * time.Hour,
+	LockExpiration: time.Minute,
+	MaxContentLen:  32 << 20,
+	Namespace:      "idempotency",
+}
+
+const maxIdempotencyKeyLen = 255
+
+type idempotentResponse struct {
+	Fingerprint string
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyWithConfig replays the first response of a request for the retries with the same
+// Idempotency-Key. Keys are scoped by user, or by client IP for anonymous requests, so it belongs after
+// the auth middleware, and after CopyBody to reuse the copied body. Only responses written by util.ResJSON are stored, 5xx responses are not,
+// so that they can be retried.
+func IdempotencyWithConfig(config IdempotencyConfig) gin.HandlerFunc {
+	if !config.Enable || config.Cache == nil {
+		return Empty()
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = DefaultIdempotencyConfig.Methods
+	}
+	if config.HeaderKey == "" {
+		config.HeaderKey = DefaultIdempotencyConfig.HeaderKey
+	}
+	if config.Expiration <= 0 {
+		config.Expiration = DefaultIdempotencyConfig.Expiration
+	}
+	if config.LockExpiration <= 0 {
+		config.LockExpiration = DefaultIdempotencyConfig.LockExpiration
+	}
+	if config.MaxContentLen <= 0 {
+		config.MaxContentLen = DefaultIdempotencyConfig.MaxContentLen
+	}
+	if config.Namespace == "" {
+		config.Namespace = DefaultIdempotencyConfig.Namespace
+	}
+	locker, err := cachex.NewLocker(config.Cache)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		idemKey := c.GetHeader(config.HeaderKey)
+		if idemKey == "" ||
+			!slices.Contains(config.Methods, c.Request.Method) ||
+			!AllowedPathPrefixes(c, config.AllowedPathPrefixes...) ||
+			SkippedPathPrefixes(c, config.SkippedPathPrefixes...) {
+			c.Next()
+			return
+		}
+
+		if len(idemKey) > maxIdempotencyKeyLen {
+			util.ResError(c, errors.BadRequest("", "%s is too long", config.HeaderKey))
+			return
+		}
+
+		ctx := c.Request.Context()
+		fingerprint, err := idempotencyFingerprint(c, config.MaxContentLen)
+		if err != nil {
+			util.ResError(c, errors.RequestEntityTooLarge("", "req %d ", config.MaxContentLen))
+			return
+		}
+
+		// Anonymous clients must not replay each other's responses.
+		scope := util.FromUserID(ctx)
+		if scope == "" {
+			scope = "ip:" + c.ClientIP()
+		}
+		key := scope + ":" + idemKey
+
+		replay := func() bool {
+			val, ok, err := config.Cache.Get(ctx, config.Namespace, key)
+			if err != nil {
+				logging.Context(ctx).Error("Failed to get idempotent response", zap.Error(err))
+				return false
+			} else if !ok {
+				return false
+			}
+
+			var res idempotentResponse
+			if err := json.Unmarshal([]byte(val), &res); err != nil {
+				logging.Context(ctx).Error("Failed to decode idempotent response", zap.Error(err))
+				return false
+			}
+			if res.Fingerprint != fingerprint {
+				util.ResError(c, errors.Conflict("", "%s was already used for a different request", config.HeaderKey))
+				return true
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(res.Status, res.ContentType, res.Body)
+			c.Abort()
+			return true
+		}
+
+		if replay() {
+			return
+		}
+
+		lock, ok, err := locker.TryLock(ctx, config.Namespace+":"+key, config.LockExpiration)
+		if err != nil {
+			logging.Context(ctx).Error("Idempotency middleware error", zap.Error(err))
+			util.ResError(c, errors.InternalServerError("", "Internal server error, please try again later."))
+			return
+		} else if !ok {
+			// The first request may have finished in the meantime.
+			if !replay() {
+				util.ResError(c, errors.Conflict("", "A request with the same %s is in progress", config.HeaderKey))
+			}
+			return
+		}
+		defer func() {
+			if err := locker.Unlock(ctx, lock); err != nil {
+				logging.Context(ctx).Error("Failed to release idempotency lock", zap.Error(err))
+			}
+		}()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		v, ok := c.Get(util.ResBodyKey)
+		if !ok {
+			return
+		}
+		body, ok := v.([]byte)
+		if !ok {
+			return
+		}
+
+		buf, err := json.Marshal(idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: c.Writer.Header().Get("Content-Type"),
+			Body:        body,
+		})
+		if err != nil {
+			logging.Context(ctx).Error("Failed to encode idempotent response", zap.Error(err))
+			return
+		}
+		if err := config.Cache.Set(ctx, config.Namespace, key, string(buf), config.Expiration); err != nil {
+			logging.Context(ctx).Error("Failed to store idempotent response", zap.Error(err))
+		}
+	}
+}
+
+// idempotencyFingerprint hashes the method, path, query and body, so that a key reused for another
+// request is told apart from a retry.
+func idempotencyFingerprint(c *gin.Context, maxContentLen int64) (string, error) {
+	body := util.GetBodyData(c)
+	if body == nil && c.Request.Body != nil {
+		b, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxContentLen))
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + "\n" + c.Request.URL.Path + "\n" + c.Request.URL.RawQuery + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newIdempotencyTestEngine(calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(IdempotencyWithConfig(IdempotencyConfig{
+		Enable:        true,
+		MaxContentLen: 16,
+		Cache:         cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Minute}),
+	}))
+	e.POST("/items", func(c *gin.Context) {
+		*calls++
+		util.ResSuccess(c, *calls)
+	})
+	return e
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      string // RemoteAddr and body of the first request
+		second     string // RemoteAddr and body of the retry
+		wantStatus int
+		wantCalls  int
+	}{
+		{"retry is replayed", "10.0.0.1:1 a", "10.0.0.1:2 a", http.StatusOK, 1},
+		{"other body is refused", "10.0.0.1:1 a", "10.0.0.1:2 b", http.StatusConflict, 1},
+		{"anonymous clients are kept apart", "10.0.0.1:1 a", "10.0.0.2:1 a", http.StatusOK, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			e := newIdempotencyTestEngine(&calls)
+
+			var bodies []string
+			for _, req := range []string{tt.first, tt.second} {
+				addr, body, _ := strings.Cut(req, " ")
+				r := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+				r.RemoteAddr = addr
+				r.Header.Set("Idempotency-Key", "k")
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, r)
+				bodies = append(bodies, w.Body.String())
+				if req == tt.second && w.Code != tt.wantStatus {
+					t.Fatalf("retry status %d, want %d", w.Code, tt.wantStatus)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Fatalf("handler called %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantCalls == 1 && tt.wantStatus == http.StatusOK && bodies[0] != bodies[1] {
+				t.Fatalf("replayed %s, want %s", bodies[1], bodies[0])
+			}
+		})
+	}
+}
+
+func TestIdempotencyBodyTooLarge(t *testing.T) {
+	var calls int
+	e := newIdempotencyTestEngine(&calls)
+
+	r := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(strings.Repeat("a", 17)))
+	r.Header.Set("Idempotency-Key", "k")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge || calls != 0 {
+		t.Fatalf("status %d after %d calls", w.Code, calls)
+	}
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"gin-admin/pkg/errors"
+	"gin-admin/pkg/logging"
+	"gin-admin/pkg/util"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	IPRuleAllow = "allow"
+	IPRuleDeny  = "deny"
+)
+
+// IPRule allows or denies the clients in Prefixes or, when a Country lookup is configured, in Countries
+// to request the paths below PathPrefix.
+type IPRule struct {
+	PathPrefix string
+	Action     string
+	Prefixes   []netip.Prefix
+	Countries  []string // ISO 3166-1 alpha-2 codes
+}
+
+type IPFilterConfig struct {
+	Enable              bool
+	SkippedPathPrefixes []string
+	// Rules returns the current rules, it is called for every request.
+	Rules func() []IPRule
+	// Country looks up the country code of the client for geo-fencing, optional.
+	Country func(addr netip.Addr) string
+}
+
+// IPFilterWithConfig checks the client IP, as resolved by gin with its trusted proxies, against the rules.
+func IPFilterWithConfig(config IPFilterConfig) gin.HandlerFunc {
+	if !config.Enable || config.Rules == nil {
+		return Empty()
+	}
+
+	return func(c *gin.Context) {
+		if SkippedPathPrefixes(c, config.SkippedPathPrefixes...) {
+			c.Next()
+			return
+		}
+
+		addr, err := netip.ParseAddr(c.ClientIP())
+		if err != nil {
+			logging.Context(c.Request.Context()).Warn("Invalid client IP", zap.String("client_ip", c.ClientIP()))
+			util.ResError(c, errors.Forbidden("", "Access from your IP address is not allowed"))
+			return
+		}
+		addr = addr.Unmap()
+
+		var country string
+		if config.Country != nil {
+			country = config.Country(addr)
+		}
+
+		if !CheckIPRules(config.Rules(), c.Request.URL.Path, addr, country) {
+			util.ResError(c, errors.Forbidden("", "Access from your IP address is not allowed"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// CheckIPRules reports whether the client may request the path. Only the rules with the longest prefix of
+// the path count: the client is denied if a deny rule matches, or if there are allow rules and none matches.
+// Prefixes match whole path segments, /api/v1/user does not cover /api/v1/users.
+func CheckIPRules(rules []IPRule, path string, addr netip.Addr, country string) bool {
+	longest := -1
+	for _, rule := range rules {
+		if matchPathPrefix(path, rule.PathPrefix) && len(rule.PathPrefix) > longest {
+			longest = len(rule.PathPrefix)
+		}
+	}
+	if longest < 0 {
+		return true
+	}
+
+	var hasAllow, allowed bool
+	for _, rule := range rules {
+		if len(rule.PathPrefix) != longest || !matchPathPrefix(path, rule.PathPrefix) {
+			continue
+		}
+
+		matched := util.ContainsIP(rule.Prefixes, addr) ||
+			(country != "" && slices.Contains(rule.Countries, country))
+		switch rule.Action {
+		case IPRuleDeny:
+			if matched {
+				return false
+			}
+		default:
+			hasAllow = true
+			allowed = allowed || matched
+		}
+	}
+	return !hasAllow || allowed
+}
+
+func matchPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// TrustedProxyConfig decides which proxy headers gin believes when resolving the client IP. The headers
+// are only read from requests sent by the trusted proxies, with no proxies they are ignored.
+type TrustedProxyConfig struct {
+	Proxies         []string // IPs or CIDRs
+	RemoteIPHeaders []string // e.g. X-Forwarded-For, X-Real-IP
+	Platform        string   // A header set by the platform, e.g. CF-Connecting-IP, trusted as is
+}
+
+// SetTrustedProxies applies the config to the engine. gin trusts every proxy by default, which lets any
+// client choose its IP with a header, so this should be called even with an empty config.
+func SetTrustedProxies(e *gin.Engine, config TrustedProxyConfig) error {
+	if len(config.RemoteIPHeaders) > 0 {
+		e.RemoteIPHeaders = config.RemoteIPHeaders
+	}
+	e.TrustedPlatform = config.Platform
+	return e.SetTrustedProxies(config.Proxies)
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestCheckIPRules(t *testing.T) {
+	office := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	blocked := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}
+
+	tests := []struct {
+		name    string
+		rules   []IPRule
+		path    string
+		addr    string
+		country string
+		want    bool
+	}{
+		{"no rules", nil, "/api/v1/users", "1.2.3.4", "", true},
+		{"no rule for the path", []IPRule{
+			{PathPrefix: "/api/v1/users", Action: IPRuleAllow, Prefixes: office},
+		}, "/api/v1/roles", "1.2.3.4", "", true},
+		{"allowed", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleAllow, Prefixes: office},
+		}, "/api/v1/users", "10.2.3.4", "", true},
+		{"not in the allow rules", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleAllow, Prefixes: office},
+		}, "/api/v1/users", "1.2.3.4", "", false},
+		{"denied", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleDeny, Prefixes: blocked},
+		}, "/api/v1/users", "10.1.2.3", "", false},
+		{"deny wins over allow", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleAllow, Prefixes: office},
+			{PathPrefix: "/api", Action: IPRuleDeny, Prefixes: blocked},
+		}, "/api/v1/users", "10.1.2.3", "", false},
+		{"longest prefix wins", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleAllow, Prefixes: office},
+			{PathPrefix: "/api/v1/public", Action: IPRuleDeny, Prefixes: blocked},
+		}, "/api/v1/public/info", "1.2.3.4", "", true},
+		{"prefix matches the whole path", []IPRule{
+			{PathPrefix: "/api/v1/user", Action: IPRuleAllow, Prefixes: office},
+		}, "/api/v1/user", "1.2.3.4", "", false},
+		{"prefix matches whole segments only", []IPRule{
+			{PathPrefix: "/api/v1/user", Action: IPRuleAllow, Prefixes: office},
+		}, "/api/v1/users", "1.2.3.4", "", true},
+		{"prefix with a trailing slash", []IPRule{
+			{PathPrefix: "/api/", Action: IPRuleAllow, Prefixes: office},
+		}, "/api/v1/users", "1.2.3.4", "", false},
+		{"shorter prefix when the longer one misses the segment", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleAllow, Prefixes: office},
+			{PathPrefix: "/api/v1/user", Action: IPRuleDeny, Prefixes: office},
+		}, "/api/v1/users", "10.2.3.4", "", true},
+		{"allowed country", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleAllow, Countries: []string{"DE"}},
+		}, "/api/v1/users", "1.2.3.4", "DE", true},
+		{"denied country", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleDeny, Countries: []string{"DE"}},
+		}, "/api/v1/users", "1.2.3.4", "DE", false},
+		{"unknown country is not allowed", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleAllow, Countries: []string{"DE"}},
+		}, "/api/v1/users", "1.2.3.4", "", false},
+		{"IPv4-mapped IPv6 address", []IPRule{
+			{PathPrefix: "/api", Action: IPRuleAllow, Prefixes: office},
+		}, "/api/v1/users", "::ffff:10.2.3.4", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CheckIPRules(tt.rules, tt.path, netip.MustParseAddr(tt.addr), tt.country)
+			if got != tt.want {
+				t.Fatalf("CheckIPRules(%s, %s) = %v, want %v", tt.path, tt.addr, got, tt.want)
+			}
+		})
+	}
+}
@@ -58,8 +58,8 @@ func LoggerWithConfig(config LoggerConfig) gin.HandlerFunc {
 		if c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPut {
 			mediaType, _, _ := mime.ParseMediaType(contentType)
 			if mediaType == "application/json" {
-				if v, ok := c.Get(util.ResBodyKey); ok {
-					if b, ok := v.([]byte); ok && len(b) <= config.MaxOutputResponseBodyLen {
+				if v, ok := c.Get(util.ReqVBodyKey); ok {
+					if b, ok := v.([]byte); ok && len(b) <= config.MaxOutputRequestBodyLen {
 						fileds = append(fileds, zap.String("body", string(b)))
 					}
 				}
@@ -4,27 +4,41 @@ import (
 	"context"
 	"gin-admin/pkg/errors"
 	"gin-admin/pkg/logging"
+	"gin-admin/pkg/redisx"
 	"gin-admin/pkg/util"
+	"math"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis/v8"
-	"github.com/go-redis/redis_rate/v9"
 	"github.com/patrickmn/go-cache"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/xid"
 	"go.uber.org/zap"
-	"golang.org/x/time/rate"
+)
+
+// Rate limiting algorithms, each allows Limit requests per Period.
+const (
+	RateLimitFixedWindow   = "fixed_window"   // Counts requests in a window that starts with the first request
+	RateLimitSlidingWindow = "sliding_window" // Keeps the time of every request in the last period, exact but uses more memory
+	RateLimitTokenBucket   = "token_bucket"   // A bucket of Limit tokens refilled evenly over the period, allows bursts
 )
 
 type RateLimiterConfig struct {
 	Enable              bool
 	AllowedPathPrefixes []string
 	SkippedPathPrefixes []string
+	Algorithm           string // fixed_window (default), sliding_window or token_bucket
 	Period              int
 	MaxRequestsPerIP    int
 	MaxRequestsPerUser  int
 	StoreType           string
 	MemoryStoreConfig   RateLimiterMemoryConfig
 	RedisStoreConfig    RateLimiterRedisConfig
+	// Policy picks the limit for the request ahead of the per IP and per user limits above. The identifier
+	// returned keeps the counters of the policy apart from the others.
+	Policy func(c *gin.Context) (identifier string, limit RateLimit, ok bool)
 }
 
 func RateLimiterWithConfig(config RateLimiterConfig) gin.HandlerFunc {
@@ -47,22 +61,34 @@ func RateLimiterWithConfig(config RateLimiterConfig) gin.HandlerFunc {
 			return
 		}
 
-		var (
-			allowed bool
-			err     error
-		)
-
 		ctx := c.Request.Context()
-		if userID := util.FromUserID(ctx); userID != "" {
-			allowed, err = store.Allow(ctx, userID, time.Second*time.Duration(config.Period), config.MaxRequestsPerUser)
-		} else {
-			allowed, err = store.Allow(ctx, c.ClientIP(), time.Second*time.Duration(config.Period), config.MaxRequestsPerIP)
+		identifier, limit, ok := "", RateLimit{}, false
+		if config.Policy != nil {
+			identifier, limit, ok = config.Policy(c)
+		}
+		if !ok {
+			limit = RateLimit{
+				Algorithm: config.Algorithm,
+				Period:    time.Second * time.Duration(config.Period),
+			}
+			identifier = c.ClientIP()
+			if userID := util.FromUserID(ctx); userID != "" {
+				identifier = userID
+				limit.Limit = config.MaxRequestsPerUser
+			} else {
+				limit.Limit = config.MaxRequestsPerIP
+			}
 		}
 
+		result, err := store.Allow(ctx, identifier, limit)
 		if err != nil {
 			logging.Context(ctx).Error("Rate limiter middleware error", zap.Error(err))
 			util.ResError(c, errors.InternalServerError("", "Internal server error, please try again later."))
-		} else if allowed {
+			return
+		}
+
+		setRateLimitHeaders(c, result)
+		if result.Allowed {
 			c.Next()
 		} else {
 			util.ResError(c, errors.TooManyRequests("", "Too many requests, please try again later."))
@@ -70,8 +96,44 @@ func RateLimiterWithConfig(config RateLimiterConfig) gin.HandlerFunc {
 	}
 }
 
+func setRateLimitHeaders(c *gin.Context, result *RateLimitResult) {
+	if result.Limit <= 0 {
+		return
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// RateLimit allows Limit requests per Period. A Limit or Period of 0 means no limit.
+type RateLimit struct {
+	Algorithm string
+	Limit     int
+	Period    time.Duration
+}
+
+func (l RateLimit) disabled() bool {
+	return l.Limit <= 0 || l.Period <= 0
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // Until the full limit is available again
+	RetryAfter time.Duration // Until the next request is allowed, 0 if this one was
+}
+
 type RateLimiterStorer interface {
-	Allow(ctx context.Context, identifier string, period time.Duration, maxRequests int) (bool, error)
+	Allow(ctx context.Context, identifier string, limit RateLimit) (*RateLimitResult, error)
 }
 
 func NewRateLimiterMemoryStore(config RateLimiterMemoryConfig) RateLimiterStorer {
@@ -86,59 +148,211 @@ type RateLimiterMemoryConfig struct {
 }
 
 type RateLimiterMemoryStore struct {
+	mu    sync.Mutex
 	cache *cache.Cache
 }
 
-func (s *RateLimiterMemoryStore) Allow(ctx context.Context, identifier string, period time.Duration, maxRequests int) (bool, error) {
-	if period.Seconds() <= 0 || maxRequests <= 0 {
-		return true, nil
-	}
+type fixedWindowState struct {
+	count   int
+	resetAt time.Time
+}
 
-	if limiter, exists := s.cache.Get(identifier); exists {
-		isAllow := limiter.(*rate.Limiter).Allow()
-		s.cache.SetDefault(identifier, limiter)
-		return isAllow, nil
+type tokenBucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+// Allow keeps one state per identifier and algorithm, which expires once it is back to the full limit.
+func (s *RateLimiterMemoryStore) Allow(ctx context.Context, identifier string, limit RateLimit) (*RateLimitResult, error) {
+	if limit.disabled() {
+		return &RateLimitResult{Allowed: true}, nil
 	}
 
-	limiter := rate.NewLimiter(rate.Every(period), maxRequests)
-	limiter.Allow()
-	s.cache.SetDefault(identifier, limiter)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := limit.Algorithm + ":" + identifier
+	result := &RateLimitResult{Limit: limit.Limit}
+
+	switch limit.Algorithm {
+	case RateLimitSlidingWindow:
+		var log []time.Time
+		if v, ok := s.cache.Get(key); ok {
+			log = v.([]time.Time)
+		}
+		start := now.Add(-limit.Period)
+		i := 0
+		for i < len(log) && !log[i].After(start) {
+			i++
+		}
+		log = log[i:]
+
+		if len(log) < limit.Limit {
+			log = append(log, now)
+			result.Allowed = true
+		}
+		result.Remaining = limit.Limit - len(log)
+		result.ResetAfter = log[len(log)-1].Add(limit.Period).Sub(now)
+		if !result.Allowed {
+			result.RetryAfter = log[0].Add(limit.Period).Sub(now)
+		}
+		s.cache.Set(key, log, limit.Period)
+	case RateLimitTokenBucket:
+		state := tokenBucketState{tokens: float64(limit.Limit), last: now}
+		if v, ok := s.cache.Get(key); ok {
+			state = v.(tokenBucketState)
+		}
+		rate := float64(limit.Limit) / float64(limit.Period)
+		state.tokens = math.Min(float64(limit.Limit), state.tokens+float64(now.Sub(state.last))*rate)
+		state.last = now
+
+		if state.tokens >= 1 {
+			state.tokens--
+			result.Allowed = true
+		}
+		fillTokenBucketResult(result, state.tokens, rate)
+		s.cache.Set(key, state, limit.Period)
+	default:
+		state := fixedWindowState{resetAt: now.Add(limit.Period)}
+		if v, ok := s.cache.Get(key); ok && v.(fixedWindowState).resetAt.After(now) {
+			state = v.(fixedWindowState)
+		}
 
-	return true, nil
+		if state.count < limit.Limit {
+			state.count++
+			result.Allowed = true
+		}
+		result.Remaining = limit.Limit - state.count
+		result.ResetAfter = state.resetAt.Sub(now)
+		if !result.Allowed {
+			result.RetryAfter = result.ResetAfter
+		}
+		s.cache.Set(key, state, result.ResetAfter)
+	}
+	return result, nil
 }
 
-type RateLimiterRedisConfig struct {
-	Addr     string
-	Username string
-	Password string
-	DB       int
+// fillTokenBucketResult derives the headers from the tokens left and the refill rate in tokens per nanosecond.
+func fillTokenBucketResult(result *RateLimitResult, tokens, rate float64) {
+	result.Remaining = int(tokens)
+	result.ResetAfter = time.Duration((float64(result.Limit) - tokens) / rate)
+	if !result.Allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / rate)
+	}
 }
 
+type RateLimiterRedisConfig = redisx.Config
+
+// NewRateLimiterRedisStore uses the client shared with the other users of the same Redis connection.
 func NewRateLimiterRedisStore(config RateLimiterRedisConfig) RateLimiterStorer {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     config.Addr,
-		Username: config.Username,
-		Password: config.Password,
-		DB:       config.DB,
-	})
+	rdb, err := redisx.Get(config)
+	if err != nil {
+		panic(err)
+	}
+	return NewRateLimiterRedisStoreWithClient(rdb)
+}
 
+func NewRateLimiterRedisStoreWithClient(rdb redis.UniversalClient) RateLimiterStorer {
 	return &RateLimiterRedisStore{
-		limiter: redis_rate.NewLimiter(rdb),
+		cli: rdb,
 	}
 }
 
+// The scripts take the time from Redis, so that instances with skewed clocks share the same windows.
+var (
+	redisFixedWindowScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {n, redis.call('PTTL', KEYS[1])}
+`)
+
+	redisSlidingWindowScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local period, limit = tonumber(ARGV[1]), tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - period)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], period)
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+return {allowed, count, tonumber(oldest[2]) + period - now, tonumber(newest[2]) + period - now}
+`)
+
+	redisTokenBucketScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local period, limit = tonumber(ARGV[1]), tonumber(ARGV[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - ts) * limit / period)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+)
+
 type RateLimiterRedisStore struct {
-	limiter *redis_rate.Limiter
+	cli redis.UniversalClient
 }
 
-func (s *RateLimiterRedisStore) Allow(ctx context.Context, identifier string, period time.Duration, maxRequests int) (bool, error) {
-	if period.Seconds() <= 0 || maxRequests <= 0 {
-		return true, nil
+func (s *RateLimiterRedisStore) Allow(ctx context.Context, identifier string, limit RateLimit) (*RateLimitResult, error) {
+	if limit.disabled() {
+		return &RateLimitResult{Allowed: true}, nil
 	}
 
-	result, err := s.limiter.Allow(ctx, identifier, redis_rate.PerSecond(maxRequests/int(period.Seconds())))
-	if err != nil {
-		return false, err
+	key := "ratelimit:" + limit.Algorithm + ":" + identifier
+	period := limit.Period.Milliseconds()
+	result := &RateLimitResult{Limit: limit.Limit}
+
+	switch limit.Algorithm {
+	case RateLimitSlidingWindow:
+		v, err := redisSlidingWindowScript.Run(ctx, s.cli, []string{key}, period, limit.Limit, xid.New().String()).Int64Slice()
+		if err != nil {
+			return nil, err
+		}
+		result.Allowed = v[0] == 1
+		result.Remaining = limit.Limit - int(v[1])
+		result.ResetAfter = time.Duration(v[3]) * time.Millisecond
+		if !result.Allowed {
+			result.RetryAfter = time.Duration(v[2]) * time.Millisecond
+		}
+	case RateLimitTokenBucket:
+		v, err := redisTokenBucketScript.Run(ctx, s.cli, []string{key}, period, limit.Limit).Slice()
+		if err != nil {
+			return nil, err
+		}
+		tokens, err := strconv.ParseFloat(v[1].(string), 64)
+		if err != nil {
+			return nil, err
+		}
+		result.Allowed = v[0].(int64) == 1
+		fillTokenBucketResult(result, tokens, float64(limit.Limit)/float64(limit.Period))
+	default:
+		v, err := redisFixedWindowScript.Run(ctx, s.cli, []string{key}, period).Int64Slice()
+		if err != nil {
+			return nil, err
+		}
+		result.Allowed = int(v[0]) <= limit.Limit
+		result.Remaining = max(limit.Limit-int(v[0]), 0)
+		result.ResetAfter = time.Duration(v[1]) * time.Millisecond
+		if !result.Allowed {
+			result.RetryAfter = result.ResetAfter
+		}
 	}
-	return result.Allowed > 0, nil
+	return result, nil
 }
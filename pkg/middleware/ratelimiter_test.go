@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRateLimiterStore() RateLimiterStorer {
+	return NewRateLimiterMemoryStore(RateLimiterMemoryConfig{
+		Expiration:      time.Minute,
+		CleanupInterval: time.Minute,
+	})
+}
+
+func TestRateLimiterMemoryStore(t *testing.T) {
+	const period = 300 * time.Millisecond
+
+	tests := []struct {
+		algorithm string
+		// After the limit is used up, waiting this long allows one more request.
+		wait time.Duration
+	}{
+		{RateLimitFixedWindow, period},
+		{RateLimitSlidingWindow, period},
+		{RateLimitTokenBucket, period / 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			store := newTestRateLimiterStore()
+			ctx := context.Background()
+			limit := RateLimit{Algorithm: tt.algorithm, Limit: 3, Period: period}
+
+			for i := 0; i < 3; i++ {
+				result, err := store.Allow(ctx, "ip", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !result.Allowed || result.Remaining != 2-i || result.RetryAfter != 0 {
+					t.Fatalf("request %d: %+v", i+1, result)
+				}
+				if result.ResetAfter <= 0 || result.ResetAfter > period {
+					t.Fatalf("request %d: reset after %s", i+1, result.ResetAfter)
+				}
+			}
+
+			result, err := store.Allow(ctx, "ip", limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed || result.Remaining != 0 {
+				t.Fatalf("request over the limit: %+v", result)
+			}
+			if result.RetryAfter <= 0 || result.RetryAfter > tt.wait {
+				t.Fatalf("retry after %s, want up to %s", result.RetryAfter, tt.wait)
+			}
+
+			// Other identifiers have their own limit.
+			if result, _ := store.Allow(ctx, "other", limit); !result.Allowed {
+				t.Fatalf("other identifier: %+v", result)
+			}
+
+			time.Sleep(tt.wait + 20*time.Millisecond)
+			if result, _ := store.Allow(ctx, "ip", limit); !result.Allowed {
+				t.Fatalf("request after %s: %+v", tt.wait, result)
+			}
+		})
+	}
+}
+
+func TestRateLimiterMemoryStoreSlidingWindow(t *testing.T) {
+	store := newTestRateLimiterStore()
+	ctx := context.Background()
+	limit := RateLimit{Algorithm: RateLimitSlidingWindow, Limit: 2, Period: 300 * time.Millisecond}
+
+	// A fixed window would start over after the period of the first request,
+	// the sliding window only frees the slot of that request.
+	_, _ = store.Allow(ctx, "ip", limit)
+	time.Sleep(150 * time.Millisecond)
+	_, _ = store.Allow(ctx, "ip", limit)
+	time.Sleep(170 * time.Millisecond)
+
+	if result, _ := store.Allow(ctx, "ip", limit); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("request after the first one expired: %+v", result)
+	}
+	if result, _ := store.Allow(ctx, "ip", limit); result.Allowed {
+		t.Fatalf("second request in the window: %+v", result)
+	}
+}
+
+func TestRateLimiterMemoryStoreTokenBucketRefill(t *testing.T) {
+	store := newTestRateLimiterStore()
+	ctx := context.Background()
+	limit := RateLimit{Algorithm: RateLimitTokenBucket, Limit: 10, Period: time.Second}
+
+	for i := 0; i < 10; i++ {
+		_, _ = store.Allow(ctx, "ip", limit)
+	}
+
+	// Two tokens come back in 200ms, not the whole bucket.
+	time.Sleep(210 * time.Millisecond)
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if result, _ := store.Allow(ctx, "ip", limit); result.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Fatalf("%d requests allowed after 200ms, want 2", allowed)
+	}
+}
+
+func TestRateLimiterMemoryStoreDisabled(t *testing.T) {
+	store := newTestRateLimiterStore()
+
+	tests := []RateLimit{
+		{Algorithm: RateLimitFixedWindow, Limit: 0, Period: time.Second},
+		{Algorithm: RateLimitTokenBucket, Limit: 1, Period: 0},
+	}
+
+	for _, limit := range tests {
+		for i := 0; i < 5; i++ {
+			result, err := store.Allow(context.Background(), "ip", limit)
+			if err != nil || !result.Allowed || result.Limit != 0 {
+				t.Fatalf("%+v: %+v, %v", limit, result, err)
+			}
+		}
+	}
+}
+
+func TestRateLimiterWithConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	e := gin.New()
+	e.Use(RateLimiterWithConfig(RateLimiterConfig{
+		Enable:              true,
+		SkippedPathPrefixes: []string{"/health"},
+		Period:              60,
+		MaxRequestsPerIP:    2,
+		MemoryStoreConfig:   RateLimiterMemoryConfig{Expiration: time.Minute, CleanupInterval: time.Minute},
+	}))
+	e.GET("/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+	e.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(path, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		path          string
+		ip            string
+		wantStatus    int
+		wantRemaining string
+		wantRetry     bool
+	}{
+		{"/items", "10.0.0.1", http.StatusOK, "1", false},
+		{"/items", "10.0.0.1", http.StatusOK, "0", false},
+		{"/items", "10.0.0.1", http.StatusTooManyRequests, "0", true},
+		{"/items", "10.0.0.2", http.StatusOK, "1", false},
+		{"/health", "10.0.0.1", http.StatusOK, "", false},
+	}
+
+	for i, tt := range tests {
+		w := do(tt.path, tt.ip)
+		if w.Code != tt.wantStatus {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Fatalf("request %d: X-RateLimit-Remaining = %q, want %q", i+1, got, tt.wantRemaining)
+		}
+		if tt.wantRemaining != "" {
+			if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+				t.Fatalf("request %d: X-RateLimit-Limit = %q, want 2", i+1, got)
+			}
+			if got := w.Header().Get("X-RateLimit-Reset"); got != "60" {
+				t.Fatalf("request %d: X-RateLimit-Reset = %q, want 60", i+1, got)
+			}
+		}
+		if got := w.Header().Get("Retry-After") != ""; got != tt.wantRetry {
+			t.Fatalf("request %d: Retry-After = %q", i+1, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestCeilSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int64
+	}{
+		{0, 0},
+		{time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+	}
+
+	for _, tt := range tests {
+		if got := ceilSeconds(tt.d); got != tt.want {
+			t.Fatalf("ceilSeconds(%s) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
@@ -1,31 +1,229 @@
 package middleware
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
 	"os"
-	"path/filepath"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
 type StaticConfig struct {
 	SkippedPathPrefixes []string
-	Root                string
+	// Root is a directory on disk, it takes precedence over FS so that a UI under development can be
+	// served by a binary with an embedded one.
+	Root string
+	FS   fs.FS
+	// APIPathPrefixes never fall back to index.html, so that a missing API answers 404 and not a page.
+	APIPathPrefixes []string
+	MaxAge          int // seconds, Cache-Control max-age of the files other than index.html
+	// ImmutablePattern matches the paths of the files with a content hash in their name, which are
+	// cached for a year without revalidation.
+	ImmutablePattern *regexp.Regexp
 }
 
+var DefaultStaticConfig = StaticConfig{
+	APIPathPrefixes:  []string{"/api/"},
+	MaxAge:           3600,
+	ImmutablePattern: regexp.MustCompile(`^/assets/.+[.-][0-9A-Za-z_-]{8,}\.[0-9a-z]+$`),
+}
+
+const staticIndexFile = "index.html"
+
+// StaticWithConfig serves a single page app. index.html is served for the paths without a file, so that
+// the client side router can handle them, and is always revalidated. A .br or .gz sibling of a file is
+// served in its place when the client accepts the encoding.
 func StaticWithConfig(config StaticConfig) gin.HandlerFunc {
+	if config.APIPathPrefixes == nil {
+		config.APIPathPrefixes = DefaultStaticConfig.APIPathPrefixes
+	}
+
+	fsys := config.FS
+	if config.Root != "" {
+		fsys = os.DirFS(config.Root)
+	}
+	if fsys == nil {
+		return Empty()
+	}
+	s := &staticFS{fsys: fsys}
+
 	return func(c *gin.Context) {
-		if SkippedPathPrefixes(c, config.SkippedPathPrefixes...) {
+		if SkippedPathPrefixes(c, config.SkippedPathPrefixes...) ||
+			(c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
 			c.Next()
 			return
 		}
 
-		p := c.Request.URL.Path
-		fpath := filepath.Join(config.Root, filepath.FromSlash(p))
-		_, err := os.Stat(fpath)
-		if err != nil && os.IsNotExist(err) {
-			fpath = filepath.Join(config.Root, "index.html")
+		name := path.Clean("/" + c.Request.URL.Path)
+		if strings.HasSuffix(name, "/") {
+			name += staticIndexFile
+		}
+		info, err := fs.Stat(fsys, strings.TrimPrefix(name, "/"))
+		if err != nil || info.IsDir() {
+			if !spaFallback(c, config.APIPathPrefixes, name) {
+				c.Next()
+				return
+			}
+			name = "/" + staticIndexFile
+		}
+
+		if path.Base(name) == staticIndexFile {
+			c.Header("Cache-Control", "no-cache")
+		} else if config.ImmutablePattern != nil && config.ImmutablePattern.MatchString(name) {
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		} else if config.MaxAge > 0 {
+			c.Header("Cache-Control", "public, max-age="+strconv.Itoa(config.MaxAge))
 		}
-		c.File(fpath)
+		s.serveFile(c, name)
 		c.Abort()
 	}
 }
+
+// spaFallback reports whether index.html stands in for a missing file: not for the API and not for paths
+// with an extension, which are missing assets rather than routes of the app.
+func spaFallback(c *gin.Context, apiPrefixes []string, name string) bool {
+	for _, p := range apiPrefixes {
+		if strings.HasPrefix(c.Request.URL.Path, p) {
+			return false
+		}
+	}
+	return path.Ext(name) == "" || path.Base(name) == staticIndexFile
+}
+
+var precompressedEncodings = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// staticFS serves the files of a fs.FS. Embedded files have no modification time, their ETag is a hash
+// of the content, computed once since they never change.
+type staticFS struct {
+	fsys   fs.FS
+	hashes sync.Map
+}
+
+// open opens a regular file, which is read in memory when it can't seek, as http.ServeContent requires.
+func (s *staticFS) open(name string) (io.ReadSeeker, fs.FileInfo, func(), error) {
+	f, err := s.fsys.Open(strings.TrimPrefix(name, "/"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil, nil, fs.ErrNotExist
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, info, func() { f.Close() }, nil
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return bytes.NewReader(b), info, func() {}, nil
+}
+
+func (s *staticFS) etag(name string, info fs.FileInfo, content io.ReadSeeker, encoding string) string {
+	var tag string
+	if !info.ModTime().IsZero() {
+		tag = fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+	} else if v, ok := s.hashes.Load(name); ok {
+		tag = v.(string)
+	} else {
+		h := sha256.New()
+		if _, err := io.Copy(h, content); err != nil {
+			return ""
+		}
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return ""
+		}
+		tag = hex.EncodeToString(h.Sum(nil)[:16])
+		s.hashes.Store(name, tag)
+	}
+
+	if encoding != "" {
+		tag += "-" + encoding
+	}
+	return `"` + tag + `"`
+}
+
+func (s *staticFS) serveFile(c *gin.Context, name string) {
+	accepted := c.GetHeader("Accept-Encoding")
+	for _, pc := range precompressedEncodings {
+		if !acceptsEncoding(accepted, pc.encoding) {
+			continue
+		}
+
+		content, info, closeFn, err := s.open(name + pc.ext)
+		if err != nil {
+			continue
+		}
+
+		h := c.Writer.Header()
+		h.Add("Vary", "Accept-Encoding")
+		h.Set("Content-Encoding", pc.encoding)
+		if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+			h.Set("Content-Type", ctype)
+		}
+		if etag := s.etag(name+pc.ext, info, content, pc.encoding); etag != "" {
+			h.Set("ETag", etag)
+		}
+		http.ServeContent(c.Writer, c.Request, name, info.ModTime(), content)
+		closeFn()
+		return
+	}
+
+	content, info, closeFn, err := s.open(name)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer closeFn()
+
+	c.Header("Vary", "Accept-Encoding")
+	if etag := s.etag(name, info, content, ""); etag != "" {
+		c.Header("ETag", etag)
+	}
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), content)
+}
+
+func acceptsEncoding(header, encoding string) bool {
+	q, listed := encodingQuality(header, encoding)
+	return listed && q > 0
+}
+
+// encodingQuality returns the q value of an encoding in an Accept-Encoding header and whether it is listed.
+func encodingQuality(header, encoding string) (float64, bool) {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(params)), "q=")
+		if !ok {
+			return 1, true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		if err != nil {
+			return 1, true
+		}
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,158 @@
+// Package notify routes application events through an in-process bus to pluggable
+// notification channels such as webhooks and chat bots.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	EventLoginNewDevice   = "login.new_device"
+	EventUserRoleChanged  = "user.role_changed"
+	EventLogError         = "log.error"
+	EventSubscriptionTest = "subscription.test"
+)
+
+type Event struct {
+	Type       string
+	UserID     string // The user the event is about, if any
+	Title      string
+	Content    string
+	Data       map[string]interface{}
+	OccurredAt time.Time
+}
+
+type Handler func(ctx context.Context, ev Event)
+
+// Bus delivers published events to all subscribed handlers on a background goroutine,
+// so that publishers are never blocked by slow notification channels.
+type Bus struct {
+	lock     sync.RWMutex
+	handlers []Handler
+	queue    chan Event
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewBus(queueSize int) *Bus {
+	if queueSize <= 0 {
+		queueSize = 256
+	}
+
+	b := &Bus{
+		queue: make(chan Event, queueSize),
+		done:  make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.run()
+	return b
+}
+
+func (b *Bus) Subscribe(h Handler) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish queues the event. Events are dropped when the queue is full.
+func (b *Bus) Publish(ev Event) bool {
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = time.Now()
+	}
+
+	select {
+	case <-b.done:
+		return false
+	default:
+	}
+
+	select {
+	case b.queue <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Bus) run() {
+	defer b.wg.Done()
+	for {
+		select {
+		case <-b.done:
+			// Deliver what was queued before Close.
+			for {
+				select {
+				case ev := <-b.queue:
+					b.dispatch(ev)
+				default:
+					return
+				}
+			}
+		case ev := <-b.queue:
+			b.dispatch(ev)
+		}
+	}
+}
+
+func (b *Bus) dispatch(ev Event) {
+	b.lock.RLock()
+	handlers := b.handlers
+	b.lock.RUnlock()
+
+	for _, h := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					zap.L().Warn("notify handler panic", zap.String("event", ev.Type), zap.String("panic", fmt.Sprint(r)))
+				}
+			}()
+			h(context.Background(), ev)
+		}()
+	}
+}
+
+func (b *Bus) Close() {
+	select {
+	case <-b.done:
+		return
+	default:
+		close(b.done)
+	}
+	b.wg.Wait()
+}
+
+var defaultBus atomic.Pointer[Bus]
+
+// Init starts the default bus. Events published before Init or after Close are dropped.
+func Init(queueSize int) {
+	if old := defaultBus.Swap(NewBus(queueSize)); old != nil {
+		old.Close()
+	}
+}
+
+// Close stops the default bus after the queued events are handled.
+func Close() {
+	if b := defaultBus.Swap(nil); b != nil {
+		b.Close()
+	}
+}
+
+// Subscribe adds a handler to the default bus, it must be called after Init.
+func Subscribe(h Handler) {
+	if b := defaultBus.Load(); b != nil {
+		b.Subscribe(h)
+	}
+}
+
+func Publish(ev Event) bool {
+	if b := defaultBus.Load(); b != nil {
+		return b.Publish(ev)
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LogHook returns a zap hook that publishes EventLogError for entries at or above level.
+// Repeated messages from the same caller are published at most once per interval.
+func LogHook(level zapcore.Level, interval time.Duration) func(zapcore.Entry) error {
+	var (
+		lock sync.Mutex
+		last = make(map[string]time.Time)
+	)
+
+	return func(entry zapcore.Entry) error {
+		if entry.Level < level {
+			return nil
+		}
+
+		key := entry.Caller.TrimmedPath() + ":" + entry.Message
+		lock.Lock()
+		if t, ok := last[key]; ok && entry.Time.Sub(t) < interval {
+			lock.Unlock()
+			return nil
+		}
+		last[key] = entry.Time
+		if len(last) > 1000 {
+			last = make(map[string]time.Time)
+		}
+		lock.Unlock()
+
+		Publish(Event{
+			Type:    EventLogError,
+			Title:   "[" + entry.Level.CapitalString() + "] " + entry.Message,
+			Content: entry.Caller.TrimmedPath() + "\n" + entry.Message,
+			Data: map[string]interface{}{
+				"level":  entry.Level.String(),
+				"caller": entry.Caller.TrimmedPath(),
+				"stack":  entry.Stack,
+			},
+			OccurredAt: entry.Time,
+		})
+		return nil
+	}
+}
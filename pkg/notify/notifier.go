@@ -0,0 +1,273 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	ChannelWebhook  = "webhook"
+	ChannelDingTalk = "dingtalk"
+	ChannelWeCom    = "wecom"
+	ChannelSlack    = "slack"
+)
+
+type Message struct {
+	Event      string                 `json:"event"`
+	Title      string                 `json:"title"`
+	Content    string                 `json:"content"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+func NewMessage(ev Event) *Message {
+	return &Message{
+		Event:      ev.Type,
+		Title:      ev.Title,
+		Content:    ev.Content,
+		Data:       ev.Data,
+		OccurredAt: ev.OccurredAt,
+	}
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, msg *Message) error
+}
+
+// ErrAddressNotAllowed is returned for targets that resolve to loopback, link-local,
+// private or unspecified addresses, which must not be reachable through user supplied URLs.
+var ErrAddressNotAllowed = errors.New("notify: target address is not allowed")
+
+// deniedPrefixes are the ranges that are not public internet, IPv4-mapped addresses are unmapped first.
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "This" network
+	netip.MustParsePrefix("10.0.0.0/8"),     // Private
+	netip.MustParsePrefix("100.64.0.0/10"),  // Carrier-grade NAT, also Alibaba Cloud metadata at 100.100.100.200
+	netip.MustParsePrefix("127.0.0.0/8"),    // Loopback
+	netip.MustParsePrefix("169.254.0.0/16"), // Link-local, cloud metadata
+	netip.MustParsePrefix("172.16.0.0/12"),  // Private
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("192.168.0.0/16"), // Private
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("224.0.0.0/4"),    // Multicast
+	netip.MustParsePrefix("240.0.0.0/4"),    // Reserved and broadcast
+	netip.MustParsePrefix("::/96"),          // Unspecified, loopback and IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, maps onto any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local-use NAT64
+	netip.MustParsePrefix("2002::/16"),      // 6to4, embeds an IPv4 address
+	netip.MustParsePrefix("fc00::/7"),       // Unique local
+	netip.MustParsePrefix("fe80::/10"),      // Link-local
+	netip.MustParsePrefix("ff00::/8"),       // Multicast
+}
+
+// httpClient checks every address it connects to, after name resolution, so neither DNS
+// records nor redirects can point a target at the internal network. Proxies are not used
+// because the check would only see the proxy address.
+var httpClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: checkDialAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return ErrAddressNotAllowed
+	}
+	addr = addr.Unmap()
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return ErrAddressNotAllowed
+		}
+	}
+	return nil
+}
+
+// New creates a notifier for a channel. The secret is optional for webhooks and
+// DingTalk robots and ignored by the others.
+func New(channel, target, secret string) (Notifier, error) {
+	if target == "" {
+		return nil, errors.New("notify: target url is required")
+	}
+
+	switch channel {
+	case ChannelWebhook:
+		return &WebhookNotifier{URL: target, Secret: secret}, nil
+	case ChannelDingTalk:
+		return &DingTalkNotifier{URL: target, Secret: secret}, nil
+	case ChannelWeCom:
+		return &WeComNotifier{URL: target}, nil
+	case ChannelSlack:
+		return &SlackNotifier{URL: target}, nil
+	}
+	return nil, fmt.Errorf("notify: unsupported channel %s", channel)
+}
+
+func postJSON(ctx context.Context, urlStr string, body interface{}, header http.Header) ([]byte, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return post(ctx, urlStr, b, header)
+}
+
+func post(ctx context.Context, urlStr string, b []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrAddressNotAllowed) {
+			return nil, ErrAddressNotAllowed
+		}
+		return nil, errors.New("notify: request failed")
+	}
+	defer resp.Body.Close()
+
+	// The body is only read to check chat robot results and is never part of an error,
+	// so a target can not be used to read responses from other servers.
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("notify: unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+}
+
+// WebhookNotifier posts the message as JSON. With a secret, the request carries
+// X-Timestamp and X-Signature headers, where the signature is the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>".
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, msg *Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	header := make(http.Header)
+	if n.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(b)
+		header.Set("X-Timestamp", ts)
+		header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	_, err = post(ctx, n.URL, b, header)
+	return err
+}
+
+// chatResult is the response body of DingTalk and WeCom robots.
+type chatResult struct {
+	ErrCode int `json:"errcode"`
+}
+
+func checkChatResult(data []byte) error {
+	var result chatResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return errors.New("notify: invalid response")
+	} else if result.ErrCode != 0 {
+		return fmt.Errorf("notify: errcode %d", result.ErrCode)
+	}
+	return nil
+}
+
+type DingTalkNotifier struct {
+	URL    string
+	Secret string
+}
+
+func (n *DingTalkNotifier) Notify(ctx context.Context, msg *Message) error {
+	urlStr := n.URL
+	if n.Secret != "" {
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write([]byte(ts + "\n" + n.Secret))
+
+		u, err := url.Parse(n.URL)
+		if err != nil {
+			return err
+		}
+		query := u.Query()
+		query.Set("timestamp", ts)
+		query.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		u.RawQuery = query.Encode()
+		urlStr = u.String()
+	}
+
+	data, err := postJSON(ctx, urlStr, map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": msg.Title,
+			"text":  "#### " + msg.Title + "\n\n" + msg.Content,
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return checkChatResult(data)
+}
+
+type WeComNotifier struct {
+	URL string
+}
+
+func (n *WeComNotifier) Notify(ctx context.Context, msg *Message) error {
+	data, err := postJSON(ctx, n.URL, map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": "**" + msg.Title + "**\n" + msg.Content,
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return checkChatResult(data)
+}
+
+type SlackNotifier struct {
+	URL string
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, msg *Message) error {
+	_, err := postJSON(ctx, n.URL, map[string]interface{}{
+		"text": "*" + msg.Title + "*\n" + msg.Content,
+	}, nil)
+	return err
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestCheckDialAddress(t *testing.T) {
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"0.1.2.3", false},
+		{"10.0.0.1", false},
+		{"100.64.0.1", false},
+		{"100.100.100.200", false},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.0.0.170", false},
+		{"192.168.1.1", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"::7f00:1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"64:ff9b:1::1", false},
+		{"2002:a00:1::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		{"not-an-ip", false},
+
+		{"8.8.8.8", true},
+		{"100.63.255.255", true},
+		{"100.128.0.1", true},
+		{"172.32.0.1", true},
+		{"198.20.0.1", true},
+		{"::ffff:8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+	}
+
+	for _, tt := range tests {
+		err := checkDialAddress("tcp", net.JoinHostPort(tt.host, "443"), nil)
+		if tt.allowed && err != nil {
+			t.Errorf("%s: %v, want allowed", tt.host, err)
+		} else if !tt.allowed && !errors.Is(err, ErrAddressNotAllowed) {
+			t.Errorf("%s: %v, want ErrAddressNotAllowed", tt.host, err)
+		}
+	}
+}
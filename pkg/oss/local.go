@@ -0,0 +1,618 @@
+package oss
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/xid"
+)
+
+const (
+	localMetaDir      = ".meta"
+	localMultipartDir = ".multipart"
+)
+
+type LocalClientConfig struct {
+	Root       string // Directory that holds one sub directory per bucket
+	Domain     string // URL prefix the objects are served from, e.g. http://127.0.0.1:8040/api/v1/oss
+	BucketName string
+	Prefix     string
+	SignSecret string // If set, served objects require a signed URL
+	// Max body size of a presigned PUT in bytes, unlimited if 0
+	MaxUploadSize int64
+}
+
+var _ IClient = (*LocalClient)(nil)
+
+// LocalClient stores objects on the local disk as <Root>/<bucket>/<key>. Content type
+// and user metadata are kept in JSON sidecar files under <Root>/.meta/<bucket>/<key>.json,
+// unfinished multipart uploads under <Root>/.multipart/<uploadID>.
+type LocalClient struct {
+	config LocalClientConfig
+}
+
+type localObjectMeta struct {
+	ContentType  string            `json:"content_type"`
+	ETag         string            `json:"etag"`
+	UserMetadata map[string]string `json:"user_metadata,omitempty"`
+}
+
+func NewLocalClient(config LocalClientConfig) (*LocalClient, error) {
+	if config.Root == "" {
+		config.Root = "data/oss"
+	}
+	if config.BucketName == "" {
+		config.BucketName = "default"
+	}
+	config.Domain = strings.TrimSuffix(config.Domain, "/")
+
+	if err := os.MkdirAll(filepath.Join(config.Root, config.BucketName), 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalClient{
+		config: config,
+	}, nil
+}
+
+func (c *LocalClient) objectPath(bucketName, objectName string) (string, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	name := path.Clean("/" + objectName)
+	if name == "/" || bucketName == "" || strings.HasPrefix(bucketName, ".") || strings.ContainsAny(bucketName, `/\`) {
+		return "", errors.New("invalid object name")
+	}
+	return filepath.Join(c.config.Root, bucketName, filepath.FromSlash(name)), nil
+}
+
+func (c *LocalClient) metaPath(bucketName, objectName string) string {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	name := path.Clean("/" + objectName)
+	return filepath.Join(c.config.Root, localMetaDir, bucketName, filepath.FromSlash(name)+".json")
+}
+
+func (c *LocalClient) readMeta(bucketName, objectName string) (*localObjectMeta, error) {
+	b, err := os.ReadFile(c.metaPath(bucketName, objectName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &localObjectMeta{}, nil
+		}
+		return nil, err
+	}
+
+	var meta localObjectMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func (c *LocalClient) writeMeta(bucketName, objectName string, meta *localObjectMeta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	name := c.metaPath(bucketName, objectName)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, b, 0644)
+}
+
+func (c *LocalClient) PutObject(ctx context.Context, bucketName, objectName string, reader io.ReadSeeker, objectSize int64, options ...PutObjectOptions) (*PutObjectResult, error) {
+	var opt PutObjectOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	name, err := c.objectPath(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+
+	// Write to a temporary file first so readers never observe a partial object.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), reader)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return nil, err
+	}
+
+	meta := &localObjectMeta{
+		ContentType:  opt.ContentType,
+		ETag:         hex.EncodeToString(h.Sum(nil)),
+		UserMetadata: opt.UserMetadata,
+	}
+	if err := c.writeMeta(bucketName, objectName, meta); err != nil {
+		return nil, err
+	}
+
+	return &PutObjectResult{
+		URL:  c.config.Domain + "/" + objectName,
+		Key:  objectName,
+		ETag: meta.ETag,
+		Size: size,
+	}, nil
+}
+
+func (c *LocalClient) GetObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, error) {
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	name, err := c.objectPath(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(name)
+}
+
+func (c *LocalClient) RemoveObject(ctx context.Context, bucketName, objectName string) error {
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	name, err := c.objectPath(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(c.metaPath(bucketName, objectName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *LocalClient) RemoveObjectByURL(ctx context.Context, urlStr string) error {
+	prefix := c.config.Domain + "/"
+	if !strings.HasPrefix(urlStr, prefix) {
+		return nil
+	}
+
+	// The URL carries the client prefix, which RemoveObject adds back.
+	objectName := trimPrefix(c.config.Prefix, strings.TrimPrefix(urlStr, prefix))
+	return c.RemoveObject(ctx, "", objectName)
+}
+
+func (c *LocalClient) StatObjectByURL(ctx context.Context, urlStr string) (*ObjectStat, error) {
+	prefix := c.config.Domain + "/"
+	if !strings.HasPrefix(urlStr, prefix) {
+		return nil, nil
+	}
+
+	objectName := trimPrefix(c.config.Prefix, strings.TrimPrefix(urlStr, prefix))
+	return c.StatObject(ctx, "", objectName)
+}
+
+func (c *LocalClient) StatObject(ctx context.Context, bucketName, objectName string) (*ObjectStat, error) {
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	return c.statObject(bucketName, objectName)
+}
+
+func (c *LocalClient) statObject(bucketName, objectName string) (*ObjectStat, error) {
+	name, err := c.objectPath(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	meta, err := c.readMeta(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectStat{
+		Key:          objectName,
+		Etag:         meta.ETag,
+		LastModified: info.ModTime(),
+		Size:         info.Size(),
+		ContextType:  meta.ContentType,
+		UserMetadata: meta.UserMetadata,
+	}, nil
+}
+
+func (c *LocalClient) sign(method, bucketName, objectName string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(c.config.SignSecret))
+	mac.Write([]byte(method + "\n" + bucketName + "\n" + objectName + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PresignedGetObject returns a URL served by Handler that stays valid until expires elapses.
+func (c *LocalClient) PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error) {
+	return c.presign(http.MethodGet, bucketName, objectName, expires)
+}
+
+// PresignedPutObject returns a URL that accepts the object body with a PUT request.
+// It requires SignSecret to be configured.
+func (c *LocalClient) PresignedPutObject(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error) {
+	if c.config.SignSecret == "" {
+		return "", errors.New("presigned put requires a sign secret")
+	}
+	return c.presign(http.MethodPut, bucketName, objectName, expires)
+}
+
+func (c *LocalClient) presign(method, bucketName, objectName string, expires time.Duration) (string, error) {
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	if _, err := c.objectPath(bucketName, objectName); err != nil {
+		return "", err
+	}
+
+	if bucketName == c.config.BucketName {
+		bucketName = ""
+	}
+
+	exp := time.Now().Add(expires).Unix()
+	query := url.Values{}
+	if bucketName != "" {
+		query.Set("bucket", bucketName)
+	}
+	query.Set("expires", strconv.FormatInt(exp, 10))
+	query.Set("signature", c.sign(method, bucketName, objectName, exp))
+	return c.config.Domain + "/" + objectName + "?" + query.Encode(), nil
+}
+
+// Handler serves objects with support for Range and conditional requests, and accepts
+// uploads from URLs issued by PresignedPutObject. It must be registered with a wildcard
+// parameter for GET and PUT, e.g. g.Match([]string{"GET", "PUT"}, "/oss/*key", client.Handler("key")).
+// When SignSecret is configured only presigned URLs are accepted. Objects whose type is
+// not safe to render in the serving origin are sent as attachments.
+func (c *LocalClient) Handler(param string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		objectName := strings.TrimPrefix(ctx.Param(param), "/")
+		bucketName := ctx.Query("bucket")
+		method := ctx.Request.Method
+		if method == http.MethodHead {
+			method = http.MethodGet
+		}
+
+		if objectName == "" {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		if c.config.SignSecret != "" {
+			exp, err := strconv.ParseInt(ctx.Query("expires"), 10, 64)
+			if err != nil || time.Now().Unix() > exp {
+				ctx.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+
+			signature := ctx.Query("signature")
+			if !hmac.Equal([]byte(signature), []byte(c.sign(method, bucketName, objectName, exp))) {
+				ctx.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		} else if bucketName != "" || method != http.MethodGet {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		if method == http.MethodPut {
+			c.serveUpload(ctx, bucketName, objectName)
+			return
+		}
+
+		stat, err := c.statObject(bucketName, objectName)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		name, _ := c.objectPath(bucketName, objectName)
+		f, err := os.Open(name)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		if stat.ContextType != "" {
+			ctx.Header("Content-Type", stat.ContextType)
+		}
+		ctx.Header("X-Content-Type-Options", "nosniff")
+		if !IsInlineSafe(stat.ContextType) {
+			ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": stat.GetName()}))
+		}
+		if stat.Etag != "" {
+			ctx.Header("ETag", `"`+stat.Etag+`"`)
+		}
+		http.ServeContent(ctx.Writer, ctx.Request, stat.GetName(), stat.LastModified, f)
+		ctx.Abort()
+	}
+}
+
+func (c *LocalClient) serveUpload(ctx *gin.Context, bucketName, objectName string) {
+	// The body is not seekable, so spool it to a temp file first.
+	tmp, err := os.CreateTemp("", "oss-put-*")
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	body := ctx.Request.Body
+	if c.config.MaxUploadSize > 0 {
+		body = http.MaxBytesReader(ctx.Writer, body, c.config.MaxUploadSize)
+	}
+
+	size, err := io.Copy(tmp, body)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			_ = ctx.AbortWithError(http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	contentType, err := DetectContentType(tmp, objectName)
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// objectName already carries the client prefix.
+	result, err := c.PutObject(ctx.Request.Context(), bucketName, "/"+trimPrefix(c.config.Prefix, objectName), tmp, size, PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("ETag", `"`+result.ETag+`"`)
+	ctx.Status(http.StatusOK)
+	ctx.Abort()
+}
+
+func (c *LocalClient) ListObjects(ctx context.Context, bucketName, prefix string) ([]*ObjectStat, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	prefix = formatPrefix(c.config.Prefix, prefix)
+	root := filepath.Join(c.config.Root, bucketName)
+
+	var list []*ObjectStat
+	err := filepath.WalkDir(root, func(name string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			// Skip directories that can not contain matching keys.
+			if key != "." && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if !strings.HasPrefix(key, prefix) || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		stat, err := c.statObject(bucketName, key)
+		if err != nil {
+			return err
+		}
+		stat.Key = trimPrefix(c.config.Prefix, key)
+		list = append(list, stat)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+type localMultipartUpload struct {
+	BucketName string           `json:"bucket_name"`
+	ObjectName string           `json:"object_name"`
+	Options    PutObjectOptions `json:"options"`
+}
+
+func (c *LocalClient) multipartPath(uploadID string) (string, error) {
+	if _, err := xid.FromString(uploadID); err != nil {
+		return "", errors.New("invalid upload id")
+	}
+	return filepath.Join(c.config.Root, localMultipartDir, uploadID), nil
+}
+
+func (c *LocalClient) loadMultipart(uploadID, bucketName, objectName string) (string, *localMultipartUpload, error) {
+	dir, err := c.multipartPath(uploadID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var upload localMultipartUpload
+	if err := json.Unmarshal(b, &upload); err != nil {
+		return "", nil, err
+	}
+
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+	if upload.BucketName != bucketName || upload.ObjectName != objectName {
+		return "", nil, errors.New("upload id does not match the object")
+	}
+	return dir, &upload, nil
+}
+
+func (c *LocalClient) InitiateMultipartUpload(ctx context.Context, bucketName, objectName string, options ...PutObjectOptions) (string, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	upload := &localMultipartUpload{
+		BucketName: bucketName,
+		ObjectName: objectName,
+	}
+	if len(options) > 0 {
+		upload.Options = options[0]
+	}
+
+	b, err := json.Marshal(upload)
+	if err != nil {
+		return "", err
+	}
+
+	uploadID := xid.New().String()
+	dir, _ := c.multipartPath(uploadID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "upload.json"), b, 0644); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (c *LocalClient) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, partNumber int, reader io.ReadSeeker, partSize int64) (*ObjectPart, error) {
+	if partNumber < 1 || partNumber > 10000 {
+		return nil, errors.New("invalid part number")
+	}
+
+	dir, _, err := c.loadMultipart(uploadID, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(filepath.Join(dir, strconv.Itoa(partNumber)))
+	if err != nil {
+		return nil, err
+	}
+
+	h := md5.New()
+	size, err := io.Copy(io.MultiWriter(f, h), reader)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectPart{
+		PartNumber: partNumber,
+		ETag:       hex.EncodeToString(h.Sum(nil)),
+		Size:       size,
+	}, nil
+}
+
+func (c *LocalClient) CompleteMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []ObjectPart) (*PutObjectResult, error) {
+	dir, upload, err := c.loadMultipart(uploadID, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+
+	f, err := os.Create(filepath.Join(dir, "object"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	for _, part := range parts {
+		if err := appendFile(f, filepath.Join(dir, strconv.Itoa(part.PartNumber))); err != nil {
+			return nil, err
+		}
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.PutObject(ctx, upload.BucketName, objectName, f, size, upload.Options)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.RemoveAll(dir)
+	return result, nil
+}
+
+func (c *LocalClient) AbortMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string) error {
+	dir, _, err := c.loadMultipart(uploadID, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func appendFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,225 @@
+package oss
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testLocalDomain = "http://example.com/oss"
+
+func newTestLocalClient(t *testing.T, config LocalClientConfig) *LocalClient {
+	t.Helper()
+
+	config.Root = t.TempDir()
+	config.Domain = testLocalDomain
+	c, err := NewLocalClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func putTestObject(t *testing.T, c *LocalClient, bucketName, objectName, content string) *PutObjectResult {
+	t.Helper()
+
+	result, err := c.PutObject(context.Background(), bucketName, objectName, strings.NewReader(content), int64(len(content)), PutObjectOptions{
+		ContentType: "text/plain",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestLocalClientObjects(t *testing.T) {
+	ctx := context.Background()
+	c := newTestLocalClient(t, LocalClientConfig{Prefix: "p"})
+
+	result := putTestObject(t, c, "", "/a/b.txt", "hello")
+	if result.Key != "p/a/b.txt" || result.URL != testLocalDomain+"/p/a/b.txt" || result.Size != 5 {
+		t.Fatalf("put result = %+v", result)
+	}
+
+	r, err := c.GetObject(ctx, "", "a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(b) != "hello" {
+		t.Fatalf("content = %q, want hello", b)
+	}
+
+	stat, err := c.StatObjectByURL(ctx, result.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size != 5 || stat.ContextType != "text/plain" || stat.Etag != result.ETag {
+		t.Fatalf("stat = %+v", stat)
+	}
+
+	if err := c.RemoveObjectByURL(ctx, result.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StatObject(ctx, "", "a/b.txt"); !os.IsNotExist(err) {
+		t.Fatalf("stat after remove: %v", err)
+	}
+	// Removing a missing object is not an error.
+	if err := c.RemoveObject(ctx, "", "a/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalClientObjectPath(t *testing.T) {
+	c := newTestLocalClient(t, LocalClientConfig{})
+	bucketDir := filepath.Join(c.config.Root, c.config.BucketName)
+
+	tests := []struct {
+		name       string
+		bucketName string
+		objectName string
+		wantPath   string // Relative to the bucket directory
+		wantErr    bool
+	}{
+		{name: "plain", objectName: "a/b.txt", wantPath: "a/b.txt"},
+		{name: "parent references stay in the bucket", objectName: "../../etc/passwd", wantPath: "etc/passwd"},
+		{name: "absolute", objectName: "/etc/passwd", wantPath: "etc/passwd"},
+		{name: "empty", objectName: "", wantErr: true},
+		{name: "root", objectName: "a/..", wantErr: true},
+		{name: "meta bucket", bucketName: localMetaDir, objectName: "a", wantErr: true},
+		{name: "nested bucket", bucketName: "a/b", objectName: "c", wantErr: true},
+		{name: "parent bucket", bucketName: "..", objectName: "c", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.objectPath(tt.bucketName, tt.objectName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if want := filepath.Join(bucketDir, filepath.FromSlash(tt.wantPath)); got != want {
+				t.Fatalf("path = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func serveTestLocal(c *LocalClient, target string, header http.Header) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/oss/*key", c.Handler("key"))
+
+	u, _ := url.Parse(target)
+	r := httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	return w
+}
+
+func TestLocalClientHandlerSigned(t *testing.T) {
+	ctx := context.Background()
+	c := newTestLocalClient(t, LocalClientConfig{SignSecret: "secret"})
+	putTestObject(t, c, "", "a.txt", "hello world")
+	putTestObject(t, c, "", "b.txt", "other")
+	putTestObject(t, c, "private", "a.txt", "private")
+
+	signed, err := c.PresignedGetObject(ctx, "", "a.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := c.PresignedGetObject(ctx, "", "a.txt", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedBucket, err := c.PresignedGetObject(ctx, "private", "a.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedMissing, err := c.PresignedGetObject(ctx, "", "missing.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(signed)
+	query := u.Query()
+	otherObject := testLocalDomain + "/b.txt?" + query.Encode()
+	query.Set("bucket", "private")
+	otherBucket := testLocalDomain + "/a.txt?" + query.Encode()
+	query = u.Query()
+	query.Set("expires", query.Get("expires")+"0")
+	longerExpiry := testLocalDomain + "/a.txt?" + query.Encode()
+
+	tests := []struct {
+		name       string
+		url        string
+		header     http.Header
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "signed", url: signed, wantStatus: http.StatusOK, wantBody: "hello world"},
+		{name: "signed bucket", url: signedBucket, wantStatus: http.StatusOK, wantBody: "private"},
+		{name: "range", url: signed, header: http.Header{"Range": {"bytes=6-"}}, wantStatus: http.StatusPartialContent, wantBody: "world"},
+		{name: "unsigned", url: testLocalDomain + "/a.txt", wantStatus: http.StatusForbidden},
+		{name: "expired", url: expired, wantStatus: http.StatusForbidden},
+		{name: "signature of another object", url: otherObject, wantStatus: http.StatusForbidden},
+		{name: "signature of another bucket", url: otherBucket, wantStatus: http.StatusForbidden},
+		{name: "tampered expiry", url: longerExpiry, wantStatus: http.StatusForbidden},
+		{name: "missing object", url: signedMissing, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveTestLocal(c, tt.url, tt.header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLocalClientHandlerPublic(t *testing.T) {
+	c := newTestLocalClient(t, LocalClientConfig{})
+	result := putTestObject(t, c, "", "a.txt", "hello")
+	putTestObject(t, c, "private", "a.txt", "private")
+
+	tests := []struct {
+		name       string
+		url        string
+		header     http.Header
+		wantStatus int
+	}{
+		{name: "public", url: result.URL, wantStatus: http.StatusOK},
+		{name: "other bucket", url: result.URL + "?bucket=private", wantStatus: http.StatusForbidden},
+		{name: "not modified", url: result.URL, header: http.Header{"If-None-Match": {`"` + result.ETag + `"`}}, wantStatus: http.StatusNotModified},
+		{name: "missing", url: testLocalDomain + "/missing.txt", wantStatus: http.StatusNotFound},
+		{name: "directory", url: testLocalDomain + "/", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveTestLocal(c, tt.url, tt.header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -23,6 +24,7 @@ var _ IClient = (*MinioClient)(nil)
 type MinioClient struct {
 	config MinioClientConfig
 	client *minio.Client
+	core   *minio.Core
 }
 
 func NewMinioClient(config MinioClientConfig) (*MinioClient, error) {
@@ -46,10 +48,11 @@ func NewMinioClient(config MinioClientConfig) (*MinioClient, error) {
 	return &MinioClient{
 		config: config,
 		client: client,
+		core:   &minio.Core{Client: client},
 	}, nil
 }
 
-func (c *MinioClient) PutObject(ctx context.Context, bucketName, objectName string, reader io.ReadSeekCloser, objectSize int64, options ...PutObjectOptions) (*PutObjectResult, error) {
+func (c *MinioClient) PutObject(ctx context.Context, bucketName, objectName string, reader io.ReadSeeker, objectSize int64, options ...PutObjectOptions) (*PutObjectResult, error) {
 	if bucketName == "" {
 		bucketName = c.config.BucketName
 	}
@@ -135,3 +138,125 @@ func (c *MinioClient) StatObject(ctx context.Context, bucketName, objectName str
 		UserMetadata: info.UserMetadata,
 	}, nil
 }
+
+func (c *MinioClient) PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	u, err := c.client.PresignedGetObject(ctx, bucketName, objectName, expires, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (c *MinioClient) PresignedPutObject(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	u, err := c.client.PresignedPutObject(ctx, bucketName, objectName, expires)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (c *MinioClient) ListObjects(ctx context.Context, bucketName, prefix string) ([]*ObjectStat, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	var list []*ObjectStat
+	for info := range c.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
+		Prefix:    formatPrefix(c.config.Prefix, prefix),
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+
+		list = append(list, &ObjectStat{
+			Key:          trimPrefix(c.config.Prefix, info.Key),
+			Etag:         info.ETag,
+			LastModified: info.LastModified,
+			Size:         info.Size,
+			ContextType:  info.ContentType,
+		})
+	}
+	return list, nil
+}
+
+func (c *MinioClient) InitiateMultipartUpload(ctx context.Context, bucketName, objectName string, options ...PutObjectOptions) (string, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	var opt PutObjectOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	return c.core.NewMultipartUpload(ctx, bucketName, objectName, minio.PutObjectOptions{
+		ContentType:  opt.ContentType,
+		UserMetadata: opt.UserMetadata,
+	})
+}
+
+func (c *MinioClient) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, partNumber int, reader io.ReadSeeker, partSize int64) (*ObjectPart, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	part, err := c.core.PutObjectPart(ctx, bucketName, objectName, uploadID, partNumber, reader, partSize, minio.PutObjectPartOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectPart{
+		PartNumber: part.PartNumber,
+		ETag:       part.ETag,
+		Size:       part.Size,
+	}, nil
+}
+
+func (c *MinioClient) CompleteMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []ObjectPart) (*PutObjectResult, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	completed := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, minio.CompletePart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+		})
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	output, err := c.core.CompleteMultipartUpload(ctx, bucketName, objectName, uploadID, completed, minio.PutObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PutObjectResult{
+		URL:  c.config.Domain + "/" + objectName,
+		Key:  output.Key,
+		ETag: output.ETag,
+		Size: output.Size,
+	}, nil
+}
+
+func (c *MinioClient) AbortMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string) error {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	return c.core.AbortMultipartUpload(ctx, bucketName, objectName, uploadID)
+}
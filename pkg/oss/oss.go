@@ -3,7 +3,10 @@ package oss
 import (
 	"context"
 	"io"
+	"mime"
+	"net/http"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,6 +31,15 @@ type IClient interface {
 	RemoveObjectByURL(ctx context.Context, urlStr string) error
 	StatObject(ctx context.Context, bucketName, objectName string) (*ObjectStat, error)
 	StatObjectByURL(ctx context.Context, urlStr string) (*ObjectStat, error)
+	// ListObjects returns all objects whose key starts with prefix. The returned keys
+	// are relative to the client prefix, so they can be passed back to other methods.
+	ListObjects(ctx context.Context, bucketName, prefix string) ([]*ObjectStat, error)
+	PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error)
+	PresignedPutObject(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error)
+	InitiateMultipartUpload(ctx context.Context, bucketName, objectName string, options ...PutObjectOptions) (string, error)
+	UploadPart(ctx context.Context, bucketName, objectName, uploadID string, partNumber int, reader io.ReadSeeker, partSize int64) (*ObjectPart, error)
+	CompleteMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []ObjectPart) (*PutObjectResult, error)
+	AbortMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string) error
 }
 
 type PutObjectOptions struct {
@@ -42,6 +54,12 @@ type PutObjectResult struct {
 	Size int64
 }
 
+type ObjectPart struct {
+	PartNumber int
+	ETag       string
+	Size       int64
+}
+
 type ObjectStat struct {
 	Key          string
 	Etag         string
@@ -58,6 +76,21 @@ func (a *ObjectStat) GetName() string {
 	return filepath.Base(a.Key)
 }
 
+func formatPrefix(prefix, objectPrefix string) string {
+	objectPrefix = strings.TrimPrefix(objectPrefix, "/")
+	if prefix != "" {
+		objectPrefix = prefix + "/" + objectPrefix
+	}
+	return objectPrefix
+}
+
+func trimPrefix(prefix, objectName string) string {
+	if prefix != "" {
+		objectName = strings.TrimPrefix(objectName, prefix+"/")
+	}
+	return objectName
+}
+
 func formatObjectName(prefix, objectName string) string {
 	if objectName == "" {
 		objectName = xid.New().String()
@@ -72,3 +105,47 @@ func formatObjectName(prefix, objectName string) string {
 	}
 	return objectName
 }
+
+// inlineContentTypes are displayed by browsers without running scripts in the origin
+// they are served from. Objects of other types are served as attachments.
+var inlineContentTypes = map[string]bool{
+	"application/pdf": true,
+	"audio/mpeg":      true,
+	"audio/ogg":       true,
+	"audio/wav":       true,
+	"image/bmp":       true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+}
+
+// IsInlineSafe reports whether an object of contentType may be served inline.
+func IsInlineSafe(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && inlineContentTypes[mediaType]
+}
+
+// DetectContentType sniffs the content type of reader and rewinds it. The extension of
+// name only refines a generic result, the type declared by a client is never trusted.
+func DetectContentType(reader io.ReadSeeker, name string) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(reader, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	contentType := http.DetectContentType(buf[:n])
+	if strings.HasPrefix(contentType, "application/octet-stream") || strings.HasPrefix(contentType, "text/plain") {
+		if ct := mime.TypeByExtension(filepath.Ext(name)); ct != "" {
+			return ct, nil
+		}
+	}
+	return contentType, nil
+}
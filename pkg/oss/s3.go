@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -175,3 +176,160 @@ func (c *S3Client) StatObject(ctx context.Context, bucketName, objectName string
 		UserMetadata: metadata,
 	}, nil
 }
+
+func (c *S3Client) PresignedGetObject(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	req, _ := c.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	req.SetContext(ctx)
+	return req.Presign(expires)
+}
+
+func (c *S3Client) PresignedPutObject(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	req, _ := c.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	req.SetContext(ctx)
+	return req.Presign(expires)
+}
+
+func (c *S3Client) ListObjects(ctx context.Context, bucketName, prefix string) ([]*ObjectStat, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	var list []*ObjectStat
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(formatPrefix(c.config.Prefix, prefix)),
+	}
+	err := c.client.ListObjectsV2PagesWithContext(ctx, input, func(output *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, item := range output.Contents {
+			list = append(list, &ObjectStat{
+				Key:          trimPrefix(c.config.Prefix, aws.StringValue(item.Key)),
+				Etag:         strings.Trim(aws.StringValue(item.ETag), `"`),
+				LastModified: aws.TimeValue(item.LastModified),
+				Size:         aws.Int64Value(item.Size),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *S3Client) InitiateMultipartUpload(ctx context.Context, bucketName, objectName string, options ...PutObjectOptions) (string, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	var opt PutObjectOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if opt.ContentType != "" {
+		input.ContentType = aws.String(opt.ContentType)
+	}
+	if len(opt.UserMetadata) > 0 {
+		input.Metadata = aws.StringMap(opt.UserMetadata)
+	}
+
+	output, err := c.client.CreateMultipartUploadWithContext(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.UploadId), nil
+}
+
+func (c *S3Client) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, partNumber int, reader io.ReadSeeker, partSize int64) (*ObjectPart, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	output, err := c.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(objectName),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int64(int64(partNumber)),
+		Body:          reader,
+		ContentLength: aws.Int64(partSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectPart{
+		PartNumber: partNumber,
+		ETag:       aws.StringValue(output.ETag),
+		Size:       partSize,
+	}, nil
+}
+
+func (c *S3Client) CompleteMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []ObjectPart) (*PutObjectResult, error) {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	var size int64
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, &s3.CompletedPart{
+			PartNumber: aws.Int64(int64(part.PartNumber)),
+			ETag:       aws.String(part.ETag),
+		})
+		size += part.Size
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	output, err := c.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(objectName),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PutObjectResult{
+		URL:  c.config.Domain + "/" + objectName,
+		Key:  objectName,
+		ETag: aws.StringValue(output.ETag),
+		Size: size,
+	}, nil
+}
+
+func (c *S3Client) AbortMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string) error {
+	if bucketName == "" {
+		bucketName = c.config.BucketName
+	}
+
+	objectName = formatObjectName(c.config.Prefix, objectName)
+	_, err := c.client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(objectName),
+		UploadId: aws.String(uploadID),
+	})
+	return err
+}
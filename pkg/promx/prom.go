@@ -32,6 +32,8 @@ type PrometheusWrapper struct {
 	counterRequests, counterSendBytes  *prometheus.CounterVec
 	counterRcvdBytes, counterException *prometheus.CounterVec
 	counterEvent, counterSiteEvent     *prometheus.CounterVec
+	counterCache                       *prometheus.CounterVec
+	gaugeCacheSize                     *prometheus.GaugeVec
 }
 
 func (p *PrometheusWrapper) init() {
@@ -109,6 +111,24 @@ func (p *PrometheusWrapper) init() {
 	)
 	p.reg.MustRegister(p.counterSiteEvent)
 
+	p.counterCache = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "counter_cache",
+			Help: "Total number of cache events (hits, misses, evictions)",
+		},
+		[]string{"app", "namespace", "event"},
+	)
+	p.reg.MustRegister(p.counterCache)
+
+	p.gaugeCacheSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gauge_cache_size_bytes",
+			Help: "Size of the cache files on disk",
+		},
+		[]string{"app", "part"},
+	)
+	p.reg.MustRegister(p.gaugeCacheSize)
+
 	if p.c.DefaultCollect {
 		p.reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 		p.reg.MustRegister(collectors.NewGoCollector())
@@ -229,6 +249,20 @@ func (p *PrometheusWrapper) SiteEventLog(module, event, site string) {
 	p.counterSiteEvent.WithLabelValues(p.c.App, module, event, site).Inc()
 }
 
+func (p *PrometheusWrapper) CacheLog(ns, event string) {
+	if !p.c.Enable {
+		return
+	}
+	p.counterCache.WithLabelValues(p.c.App, ns, event).Inc()
+}
+
+func (p *PrometheusWrapper) CacheSizeLog(part string, size int64) {
+	if !p.c.Enable {
+		return
+	}
+	p.gaugeCacheSize.WithLabelValues(p.c.App, part).Set(float64(size))
+}
+
 func (p *PrometheusWrapper) StateLog(module, api, method, code string, state float64) {
 	if !p.c.Enable {
 		return
@@ -0,0 +1,202 @@
+package redisx
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ModeSingle   = "single"
+	ModeSentinel = "sentinel"
+	ModeCluster  = "cluster"
+)
+
+// Config describes a connection to a single server, a Sentinel-managed master or a cluster.
+type Config struct {
+	Mode             string   // single (default), sentinel or cluster
+	Addr             string   // Server address in single mode
+	Addrs            []string // Sentinel addresses in sentinel mode, seed nodes in cluster mode
+	MasterName       string   // Master name in sentinel mode
+	SentinelUsername string
+	SentinelPassword string
+	Username         string
+	Password         string
+	DB               int // Ignored in cluster mode
+	TLS              struct {
+		Enable             bool
+		ServerName         string
+		CAFile             string
+		CertFile           string
+		KeyFile            string
+		InsecureSkipVerify bool
+	}
+	PoolSize        int // Connections per node, 10 per CPU by default
+	MinIdleConns    int
+	MaxIdleConns    int
+	PoolTimeout     int // seconds
+	ConnMaxIdleTime int // seconds
+	ConnMaxLifetime int // seconds
+	DialTimeout     int // milliseconds
+	ReadTimeout     int // milliseconds
+	WriteTimeout    int // milliseconds
+}
+
+// key identifies the connection by every field except the pool settings, so configs
+// that only differ in pool settings share a client.
+func (c Config) key() string {
+	c.PoolSize, c.MinIdleConns, c.MaxIdleConns = 0, 0, 0
+	c.PoolTimeout, c.ConnMaxIdleTime, c.ConnMaxLifetime = 0, 0, 0
+	return fmt.Sprintf("%#v", c)
+}
+
+func (c Config) options() (*redis.UniversalOptions, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:            c.Addrs,
+		MasterName:       c.MasterName,
+		SentinelUsername: c.SentinelUsername,
+		SentinelPassword: c.SentinelPassword,
+		Username:         c.Username,
+		Password:         c.Password,
+		DB:               c.DB,
+		PoolSize:         c.PoolSize,
+		MinIdleConns:     c.MinIdleConns,
+		MaxIdleConns:     c.MaxIdleConns,
+		PoolTimeout:      time.Duration(c.PoolTimeout) * time.Second,
+		ConnMaxIdleTime:  time.Duration(c.ConnMaxIdleTime) * time.Second,
+		ConnMaxLifetime:  time.Duration(c.ConnMaxLifetime) * time.Second,
+		DialTimeout:      time.Duration(c.DialTimeout) * time.Millisecond,
+		ReadTimeout:      time.Duration(c.ReadTimeout) * time.Millisecond,
+		WriteTimeout:     time.Duration(c.WriteTimeout) * time.Millisecond,
+	}
+	if c.Addr != "" && len(opts.Addrs) == 0 {
+		opts.Addrs = []string{c.Addr}
+	}
+	if len(opts.Addrs) == 0 {
+		return nil, fmt.Errorf("redis: no address configured")
+	}
+
+	if c.TLS.Enable {
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+	return opts, nil
+}
+
+func (c Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLS.ServerName,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+	}
+
+	if c.TLS.CAFile != "" {
+		ca, err := os.ReadFile(c.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("redis: no certificates found in %s", c.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.TLS.CertFile != "" || c.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// New opens a client that is not shared with anyone else.
+func New(cfg Config) (redis.UniversalClient, error) {
+	opts, err := cfg.options()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Mode {
+	case "", ModeSingle:
+		return redis.NewClient(opts.Simple()), nil
+	case ModeSentinel:
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("redis: sentinel mode requires a master name")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case ModeCluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	}
+	return nil, fmt.Errorf("redis: unsupported mode %q", cfg.Mode)
+}
+
+var shared = struct {
+	sync.Mutex
+	clients map[string]*sharedClient
+}{clients: make(map[string]*sharedClient)}
+
+// Get returns a client shared by everyone asking for the same connection, so the cache, the token store,
+// the rate limiter and the captcha store use one pool. The connection is closed when every user closed it.
+func Get(cfg Config) (redis.UniversalClient, error) {
+	shared.Lock()
+	defer shared.Unlock()
+
+	key := cfg.key()
+	if c, ok := shared.clients[key]; ok {
+		c.refs++
+		return c, nil
+	}
+
+	cli, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &sharedClient{UniversalClient: cli, key: key, refs: 1}
+	shared.clients[key] = c
+	return c, nil
+}
+
+type sharedClient struct {
+	redis.UniversalClient
+	key  string
+	refs int
+}
+
+func (c *sharedClient) Close() error {
+	shared.Lock()
+	defer shared.Unlock()
+
+	if c.refs <= 0 {
+		return nil
+	}
+	c.refs--
+	if c.refs > 0 {
+		return nil
+	}
+	delete(shared.clients, c.key)
+	return c.UniversalClient.Close()
+}
+
+// ForEachMaster calls fn for every master, which is the only way to run node-local commands like SCAN
+// on a cluster. Other clients have a single master.
+func (c *sharedClient) ForEachMaster(ctx context.Context, fn func(ctx context.Context, client *redis.Client) error) error {
+	switch cli := c.UniversalClient.(type) {
+	case *redis.ClusterClient:
+		return cli.ForEachMaster(ctx, fn)
+	case *redis.Client:
+		return fn(ctx, cli)
+	}
+	return fmt.Errorf("redis: unsupported client %T", c.UniversalClient)
+}
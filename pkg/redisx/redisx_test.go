@@ -0,0 +1,233 @@
+package redisx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string // Client type
+		wantErr bool
+	}{
+		{name: "single", cfg: Config{Addr: "127.0.0.1:6379"}, want: "*redis.Client"},
+		{name: "sentinel", cfg: Config{Mode: ModeSentinel, Addrs: []string{"127.0.0.1:26379"}, MasterName: "mymaster"}, want: "*redis.Client"},
+		{name: "cluster", cfg: Config{Mode: ModeCluster, Addrs: []string{"127.0.0.1:7000", "127.0.0.1:7001"}}, want: "*redis.ClusterClient"},
+		{name: "no address", cfg: Config{}, wantErr: true},
+		{name: "sentinel without master", cfg: Config{Mode: ModeSentinel, Addrs: []string{"127.0.0.1:26379"}}, wantErr: true},
+		{name: "unsupported mode", cfg: Config{Mode: "ring", Addr: "127.0.0.1:6379"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer cli.Close()
+
+			var got string
+			switch cli.(type) {
+			case *redis.Client:
+				got = "*redis.Client"
+			case *redis.ClusterClient:
+				got = "*redis.ClusterClient"
+			}
+			if got != tt.want {
+				t.Fatalf("client = %T, want %s", cli, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	cfg := Config{
+		Addr:         "127.0.0.1:6379",
+		DB:           2,
+		PoolSize:     20,
+		PoolTimeout:  3,
+		DialTimeout:  500,
+		ReadTimeout:  200,
+		WriteTimeout: 300,
+	}
+	opts, err := cfg.options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.Addrs) != 1 || opts.Addrs[0] != cfg.Addr || opts.DB != 2 || opts.PoolSize != 20 {
+		t.Fatalf("options = %+v", opts)
+	}
+	if opts.PoolTimeout != 3*time.Second || opts.DialTimeout != 500*time.Millisecond ||
+		opts.ReadTimeout != 200*time.Millisecond || opts.WriteTimeout != 300*time.Millisecond {
+		t.Fatalf("timeouts = %s, %s, %s, %s", opts.PoolTimeout, opts.DialTimeout, opts.ReadTimeout, opts.WriteTimeout)
+	}
+	if opts.TLSConfig != nil {
+		t.Fatal("TLS configured without being enabled")
+	}
+
+	// Addrs wins over Addr.
+	cfg.Addrs = []string{"10.0.0.1:6379"}
+	if opts, _ := cfg.options(); len(opts.Addrs) != 1 || opts.Addrs[0] != "10.0.0.1:6379" {
+		t.Fatalf("addrs = %v", opts.Addrs)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalidFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		caFile    string
+		certFile  string
+		keyFile   string
+		wantCerts int
+		wantRoots bool
+		wantErr   bool
+	}{
+		{name: "system roots"},
+		{name: "custom ca", caFile: certFile, wantRoots: true},
+		{name: "client certificate", certFile: certFile, keyFile: keyFile, wantCerts: 1},
+		{name: "invalid ca", caFile: invalidFile, wantErr: true},
+		{name: "missing ca", caFile: filepath.Join(dir, "missing.pem"), wantErr: true},
+		{name: "certificate without key", certFile: certFile, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Addr: "127.0.0.1:6379"}
+			cfg.TLS.Enable = true
+			cfg.TLS.ServerName = "redis.local"
+			cfg.TLS.CAFile = tt.caFile
+			cfg.TLS.CertFile = tt.certFile
+			cfg.TLS.KeyFile = tt.keyFile
+
+			opts, err := cfg.options()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if opts.TLSConfig == nil || opts.TLSConfig.ServerName != "redis.local" {
+				t.Fatalf("TLS config = %+v", opts.TLSConfig)
+			}
+			if (opts.TLSConfig.RootCAs != nil) != tt.wantRoots {
+				t.Fatalf("custom roots = %v, want %v", opts.TLSConfig.RootCAs != nil, tt.wantRoots)
+			}
+			if len(opts.TLSConfig.Certificates) != tt.wantCerts {
+				t.Fatalf("%d client certificates, want %d", len(opts.TLSConfig.Certificates), tt.wantCerts)
+			}
+		})
+	}
+}
+
+func TestGetShared(t *testing.T) {
+	cfg := Config{Addr: "127.0.0.1:6379", PoolSize: 10}
+
+	a, err := Get(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pool settings do not split the pool.
+	cfg.PoolSize = 20
+	b, err := Get(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatal("configs differing in pool settings got different clients")
+	}
+
+	other, err := Get(Config{Addr: "127.0.0.1:6379", DB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == a {
+		t.Fatal("configs of different databases share a client")
+	}
+	_ = other.Close()
+
+	// The client stays open until every user closed it.
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := shared.clients[cfg.key()]; !ok {
+		t.Fatal("client released while still in use")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := shared.clients[cfg.key()]; ok {
+		t.Fatal("client kept after every user closed it")
+	}
+	// Closing again is a no-op.
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Get(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c == a {
+		t.Fatal("closed client handed out again")
+	}
+}
+
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.local"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
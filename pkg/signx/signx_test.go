@@ -0,0 +1,135 @@
+package signx
+
+import (
+	"context"
+	"errors"
+	"gin-admin/pkg/cachex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStringToSign(t *testing.T) {
+	query := url.Values{"b": {"2", "1"}, "a": {"x y"}, "c&": {""}}
+	got := StringToSign("post", "/api/v1/users", query, []byte("{}"), "1700000000", "n1")
+	want := strings.Join([]string{
+		"POST",
+		"/api/v1/users",
+		"a=x+y&b=1&b=2&c%26=",
+		"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+		"1700000000",
+		"n1",
+	}, "\n")
+	if got != want {
+		t.Fatalf("StringToSign() = %q, want %q", got, want)
+	}
+
+	// An empty body hashes like no body.
+	if StringToSign("GET", "/", nil, nil, "1", "n") != StringToSign("GET", "/", url.Values{}, []byte{}, "1", "n") {
+		t.Fatal("nil and empty body or query sign differently")
+	}
+}
+
+func newTestVerifier() *Verifier {
+	return NewVerifier(VerifierConfig{
+		ClockSkew: time.Minute,
+		Cache:     cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Minute}),
+		Secret: func(ctx context.Context, appKey string) (string, bool, error) {
+			if appKey == "app" {
+				return "secret", true, nil
+			}
+			return "", false, nil
+		},
+	})
+}
+
+func newSignedRequest(t *testing.T, appKey, secret, body string) *http.Request {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/users?b=2&a=1", strings.NewReader(body))
+	if err := SignRequest(r, appKey, secret); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		appKey  string
+		secret  string
+		change  func(r *http.Request) // Applied after signing
+		body    string                // Passed to Verify instead of the signed body, if set
+		wantErr error
+	}{
+		{"valid", "app", "secret", nil, "", nil},
+		{"upper case signature", "app", "secret", func(r *http.Request) {
+			r.Header.Set(HeaderSignature, strings.ToUpper(r.Header.Get(HeaderSignature)))
+		}, "", nil},
+		{"unknown app key", "other", "secret", nil, "", ErrInvalidSignature},
+		{"wrong secret", "app", "wrong", nil, "", ErrInvalidSignature},
+		{"missing nonce", "app", "secret", func(r *http.Request) { r.Header.Del(HeaderNonce) }, "", ErrInvalidSignature},
+		{"nonce too long", "app", "secret", func(r *http.Request) {
+			r.Header.Set(HeaderNonce, strings.Repeat("n", maxNonceLen+1))
+		}, "", ErrInvalidSignature},
+		{"changed query", "app", "secret", func(r *http.Request) { r.URL.RawQuery = "a=2&b=2" }, "", ErrInvalidSignature},
+		{"changed body", "app", "secret", nil, `{"name":"other"}`, ErrInvalidSignature},
+		{"invalid timestamp", "app", "secret", func(r *http.Request) { r.Header.Set(HeaderTimestamp, "now") }, "", ErrInvalidSignature},
+		{"expired timestamp", "app", "secret", func(r *http.Request) {
+			r.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10))
+		}, "", ErrExpiredTimestamp},
+		{"future timestamp", "app", "secret", func(r *http.Request) {
+			r.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(2*time.Minute).Unix(), 10))
+		}, "", ErrExpiredTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const signed = `{"name":"user"}`
+			r := newSignedRequest(t, tt.appKey, tt.secret, signed)
+			if tt.change != nil {
+				tt.change(r)
+			}
+			body := signed
+			if tt.body != "" {
+				body = tt.body
+			}
+
+			appKey, err := newTestVerifier().Verify(context.Background(), r, []byte(body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && appKey != tt.appKey {
+				t.Fatalf("Verify() = %q, want %q", appKey, tt.appKey)
+			}
+		})
+	}
+}
+
+func TestVerifyReplayedNonce(t *testing.T) {
+	v := newTestVerifier()
+	ctx := context.Background()
+	r := newSignedRequest(t, "app", "secret", "")
+
+	if _, err := v.Verify(ctx, r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(ctx, r, nil); !errors.Is(err, ErrReplayedNonce) {
+		t.Fatalf("replay = %v, want ErrReplayedNonce", err)
+	}
+
+	// A request with a bad signature does not burn the nonce of a valid one.
+	r = newSignedRequest(t, "app", "secret", "")
+	forged := r.Clone(ctx)
+	forged.Header.Set(HeaderSignature, strings.Repeat("0", 64))
+	if _, err := v.Verify(ctx, forged, nil); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("forged = %v, want ErrInvalidSignature", err)
+	}
+	if _, err := v.Verify(ctx, r, nil); err != nil {
+		t.Fatalf("valid after forged = %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"gin-admin/pkg/logging"
+	"sync"
+
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+)
+
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+}
+
+type Subscriber struct {
+	Key    string
+	Labels []string
+	ch     chan Event
+}
+
+func (s *Subscriber) Events() <-chan Event {
+	return s.ch
+}
+
+func (s *Subscriber) HasLabel(labels ...string) bool {
+	for _, l := range labels {
+		for _, sl := range s.Labels {
+			if l == sl {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Relay carries events between the brokers of all instances, e.g. Redis pub/sub.
+type Relay interface {
+	Publish(ctx context.Context, channel, msg string) error
+	Subscribe(ctx context.Context, channel string, fn func(msg string)) (unsubscribe func() error)
+}
+
+// relayMessage is an event as sent over the relay. An empty Key means a broadcast to
+// the subscribers holding any of Labels, or to everyone when Labels is empty.
+type relayMessage struct {
+	Instance string          `json:"instance"`
+	Key      string          `json:"key,omitempty"`
+	Labels   []string        `json:"labels,omitempty"`
+	ID       string          `json:"id,omitempty"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Broker fans out events to the subscribers connected to this process. With a relay,
+// events are also forwarded to the brokers of the other instances.
+type Broker struct {
+	lock        sync.RWMutex
+	bufferSize  int
+	subs        map[string]map[*Subscriber]struct{}
+	instance    string
+	relay       Relay
+	channel     string
+	unsubscribe func() error
+}
+
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = 16
+	}
+	return &Broker{
+		bufferSize: bufferSize,
+		subs:       make(map[string]map[*Subscriber]struct{}),
+		instance:   xid.New().String(),
+	}
+}
+
+// SetRelay forwards published events to the other instances through channel and
+// delivers theirs to the local subscribers. It must be called before the broker is used.
+func (b *Broker) SetRelay(relay Relay, channel string) {
+	b.relay = relay
+	b.channel = channel
+	b.unsubscribe = relay.Subscribe(context.Background(), channel, b.onRelay)
+}
+
+func (b *Broker) Close() error {
+	if b.unsubscribe != nil {
+		return b.unsubscribe()
+	}
+	return nil
+}
+
+func (b *Broker) Subscribe(key string, labels ...string) *Subscriber {
+	s := &Subscriber{
+		Key:    key,
+		Labels: labels,
+		ch:     make(chan Event, b.bufferSize),
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if _, ok := b.subs[key]; !ok {
+		b.subs[key] = make(map[*Subscriber]struct{})
+	}
+	b.subs[key][s] = struct{}{}
+	return s
+}
+
+func (b *Broker) Unsubscribe(s *Subscriber) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if m, ok := b.subs[s.Key]; ok {
+		if _, ok := m[s]; ok {
+			delete(m, s)
+			close(s.ch)
+		}
+		if len(m) == 0 {
+			delete(b.subs, s.Key)
+		}
+	}
+}
+
+// Online reports whether key has a subscriber connected to this process.
+func (b *Broker) Online(key string) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return len(b.subs[key]) > 0
+}
+
+// Publish sends the event to all subscribers of key and returns the number of local deliveries.
+// Slow subscribers whose buffer is full miss the event instead of blocking the publisher.
+func (b *Broker) Publish(key string, ev Event) int {
+	b.forward(relayMessage{Key: key}, ev)
+	return b.publish(key, ev)
+}
+
+// Broadcast sends the event to every subscriber holding any of labels, or to all
+// subscribers when no label is given, and returns the number of local deliveries.
+func (b *Broker) Broadcast(ev Event, labels ...string) int {
+	b.forward(relayMessage{Labels: labels}, ev)
+	return b.BroadcastLocal(ev, labels...)
+}
+
+// BroadcastLocal is Broadcast without forwarding the event to the other instances.
+func (b *Broker) BroadcastLocal(ev Event, labels ...string) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	n := 0
+	for _, m := range b.subs {
+		for s := range m {
+			if len(labels) > 0 && !s.HasLabel(labels...) {
+				continue
+			}
+			if b.send(s, ev) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func (b *Broker) publish(key string, ev Event) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	n := 0
+	for s := range b.subs[key] {
+		if b.send(s, ev) {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *Broker) send(s *Subscriber, ev Event) bool {
+	select {
+	case s.ch <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Broker) forward(msg relayMessage, ev Event) {
+	if b.relay == nil {
+		return
+	}
+
+	ctx := context.Background()
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		logging.Context(ctx).Error("Failed to encode sse event", zap.Error(err), zap.String("event", ev.Event))
+		return
+	}
+	msg.Instance = b.instance
+	msg.ID = ev.ID
+	msg.Event = ev.Event
+	msg.Data = data
+
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		logging.Context(ctx).Error("Failed to encode sse relay message", zap.Error(err))
+		return
+	}
+	if err := b.relay.Publish(ctx, b.channel, string(buf)); err != nil {
+		logging.Context(ctx).Error("Failed to relay sse event", zap.Error(err), zap.String("event", ev.Event))
+	}
+}
+
+func (b *Broker) onRelay(payload string) {
+	var msg relayMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		logging.Context(context.Background()).Error("Failed to decode sse relay message", zap.Error(err))
+		return
+	} else if msg.Instance == b.instance {
+		return
+	}
+
+	ev := Event{ID: msg.ID, Event: msg.Event, Data: msg.Data}
+	if msg.Key != "" {
+		b.publish(msg.Key, ev)
+		return
+	}
+	b.BroadcastLocal(ev, msg.Labels...)
+}
@@ -0,0 +1,498 @@
+// Package tus implements the server side of the tus 1.0 resumable upload protocol
+// (core, creation, termination and expiration extensions) on top of gin.
+package tus
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/xid"
+)
+
+const (
+	Version    = "1.0.0"
+	Extensions = "creation,termination,expiration"
+
+	offsetContentType = "application/offset+octet-stream"
+	expiresFormat     = http.TimeFormat
+)
+
+var (
+	ErrNotFound = errors.New("upload not found")
+	ErrLocked   = errors.New("upload locked")
+)
+
+type Upload struct {
+	ID        string            `json:"id"`
+	Size      int64             `json:"size"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Owner     string            `json:"owner,omitempty"`
+	Completed bool              `json:"completed"`
+	ResultID  string            `json:"result_id,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// FinalizeFunc is called once all bytes of an upload have been received. The returned ID
+// (e.g. a file record) is reported to the client in the Upload-Result header.
+type FinalizeFunc func(ctx context.Context, upload *Upload, reader io.ReadSeeker) (string, error)
+
+type Config struct {
+	Dir             string        // Directory that keeps upload info and received chunks
+	MaxSize         int64         // Max upload size in bytes, 0 means unlimited
+	Expiration      time.Duration // Unfinished uploads are removed after this idle time
+	CleanupInterval time.Duration
+	Finalize        FinalizeFunc
+	Owner           func(c *gin.Context) string // Uploads can only be resumed by their owner
+}
+
+type Handler struct {
+	config Config
+	locks  sync.Map
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+// NewHandler creates a handler whose state lives entirely in config.Dir, so uploads
+// can be resumed after the process restarts. The locks serializing the requests of an
+// upload are kept in memory, so every instance sharing config.Dir must route the
+// requests of an upload to the same process.
+func NewHandler(config Config) (*Handler, error) {
+	if config.Dir == "" {
+		config.Dir = filepath.Join(os.TempDir(), "tus")
+	}
+	if config.Expiration <= 0 {
+		config.Expiration = 24 * time.Hour
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = time.Hour
+	}
+	if config.Finalize == nil {
+		return nil, errors.New("tus: finalize func is required")
+	}
+
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	h := &Handler{
+		config: config,
+		ticker: time.NewTicker(config.CleanupInterval),
+		done:   make(chan struct{}),
+	}
+	go h.cleanupLoop()
+	return h, nil
+}
+
+func (h *Handler) Register(g *gin.RouterGroup) {
+	g.OPTIONS("", h.Options)
+	g.POST("", h.Create)
+	g.HEAD(":id", h.Head)
+	g.PATCH(":id", h.Patch)
+	g.DELETE(":id", h.Terminate)
+}
+
+func (h *Handler) Release() {
+	h.ticker.Stop()
+	close(h.done)
+}
+
+func (h *Handler) infoPath(id string) string {
+	return filepath.Join(h.config.Dir, id+".info")
+}
+
+func (h *Handler) dataPath(id string) string {
+	return filepath.Join(h.config.Dir, id+".bin")
+}
+
+func (h *Handler) exists(id string) bool {
+	if _, err := xid.FromString(id); err != nil {
+		return false
+	}
+	_, err := os.Stat(h.infoPath(id))
+	return err == nil
+}
+
+// lock locks an existing upload. Locks are only kept while their upload exists,
+// so unknown IDs cannot grow the lock map.
+func (h *Handler) lock(id string) (func(), error) {
+	if !h.exists(id) {
+		return nil, ErrNotFound
+	}
+
+	v, _ := h.locks.LoadOrStore(id, new(sync.Mutex))
+	mu := v.(*sync.Mutex)
+	if !mu.TryLock() {
+		return nil, ErrLocked
+	}
+	return func() {
+		// The upload may have been removed meanwhile, by this request or another one.
+		if !h.exists(id) {
+			h.locks.CompareAndDelete(id, mu)
+		}
+		mu.Unlock()
+	}, nil
+}
+
+func (h *Handler) lockUpload(c *gin.Context) (func(), bool) {
+	unlock, err := h.lock(c.Param("id"))
+	if err == ErrNotFound {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusLocked)
+		return nil, false
+	}
+	return unlock, true
+}
+
+func (h *Handler) load(id string) (*Upload, error) {
+	if _, err := xid.FromString(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	b, err := os.ReadFile(h.infoPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var upload Upload
+	if err := json.Unmarshal(b, &upload); err != nil {
+		return nil, err
+	}
+
+	// The data file is the source of truth for the offset: the process may have
+	// stopped after appending a chunk but before saving the info file.
+	if !upload.Completed {
+		if stat, err := os.Stat(h.dataPath(id)); err == nil {
+			upload.Offset = stat.Size()
+		}
+	}
+	return &upload, nil
+}
+
+func (h *Handler) save(upload *Upload) error {
+	b, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	tmp := h.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.infoPath(upload.ID))
+}
+
+func (h *Handler) remove(id string) {
+	_ = os.Remove(h.dataPath(id))
+	_ = os.Remove(h.infoPath(id))
+}
+
+func (h *Handler) owner(c *gin.Context) string {
+	if h.config.Owner == nil {
+		return ""
+	}
+	return h.config.Owner(c)
+}
+
+func (h *Handler) checkResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", Version)
+	if c.GetHeader("Tus-Resumable") != Version {
+		c.Header("Tus-Version", Version)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) getUpload(c *gin.Context) (*Upload, bool) {
+	upload, err := h.load(c.Param("id"))
+	if err == ErrNotFound || (err == nil && upload.Owner != h.owner(c)) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	if !upload.Completed && time.Now().After(upload.ExpiresAt) {
+		h.remove(upload.ID)
+		c.AbortWithStatus(http.StatusGone)
+		return nil, false
+	}
+	return upload, true
+}
+
+func (h *Handler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", Version)
+	c.Header("Tus-Version", Version)
+	c.Header("Tus-Extension", Extensions)
+	if h.config.MaxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(h.config.MaxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	if !h.checkResumable(c) {
+		return
+	}
+
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	} else if h.config.MaxSize > 0 && size > h.config.MaxSize {
+		c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	upload := &Upload{
+		ID:        xid.New().String(),
+		Size:      size,
+		Metadata:  metadata,
+		Owner:     h.owner(c),
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.config.Expiration),
+	}
+
+	f, err := os.Create(h.dataPath(upload.ID))
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	_ = f.Close()
+
+	if err := h.save(upload); err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if size == 0 {
+		if !h.finalize(c, upload) {
+			return
+		}
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(expiresFormat))
+	c.Status(http.StatusCreated)
+}
+
+func (h *Handler) Head(c *gin.Context) {
+	if !h.checkResumable(c) {
+		return
+	}
+
+	upload, ok := h.getUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if len(upload.Metadata) > 0 {
+		c.Header("Upload-Metadata", formatMetadata(upload.Metadata))
+	}
+	if upload.Completed {
+		c.Header("Upload-Result", upload.ResultID)
+	} else {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(expiresFormat))
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) Patch(c *gin.Context) {
+	if !h.checkResumable(c) {
+		return
+	}
+
+	if c.ContentType() != offsetContentType {
+		c.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	unlock, ok := h.lockUpload(c)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	upload, ok := h.getUpload(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset != upload.Offset {
+		c.AbortWithStatus(http.StatusConflict)
+		return
+	}
+
+	if !upload.Completed && upload.Offset < upload.Size {
+		f, err := os.OpenFile(h.dataPath(upload.ID), os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		// A broken connection still keeps whatever was received, so the client can resume from there.
+		n, err := io.Copy(f, io.LimitReader(c.Request.Body, upload.Size-upload.Offset))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		upload.Offset += n
+		upload.ExpiresAt = time.Now().Add(h.config.Expiration)
+		if serr := h.save(upload); err == nil {
+			err = serr
+		}
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	// Finalization is retried by a zero-length PATCH if it failed previously.
+	if !upload.Completed && upload.Offset == upload.Size {
+		if !h.finalize(c, upload) {
+			return
+		}
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if !upload.Completed {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(expiresFormat))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) finalize(c *gin.Context, upload *Upload) bool {
+	f, err := os.Open(h.dataPath(upload.ID))
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return false
+	}
+	defer f.Close()
+
+	resultID, err := h.config.Finalize(c.Request.Context(), upload, f)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return false
+	}
+
+	upload.Completed = true
+	upload.ResultID = resultID
+	if err := h.save(upload); err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return false
+	}
+	_ = os.Remove(h.dataPath(upload.ID))
+
+	c.Header("Upload-Result", resultID)
+	return true
+}
+
+func (h *Handler) Terminate(c *gin.Context) {
+	if !h.checkResumable(c) {
+		return
+	}
+
+	unlock, ok := h.lockUpload(c)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	upload, ok := h.getUpload(c)
+	if !ok {
+		return
+	}
+
+	h.remove(upload.ID)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) cleanupLoop() {
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-h.ticker.C:
+			h.cleanup()
+		}
+	}
+}
+
+// cleanup removes expired uploads; completed ones are kept until expiry so that
+// clients can still query the result.
+func (h *Handler) cleanup() {
+	matches, err := filepath.Glob(filepath.Join(h.config.Dir, "*.info"))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, name := range matches {
+		id := strings.TrimSuffix(filepath.Base(name), ".info")
+		unlock, err := h.lock(id)
+		if err != nil {
+			continue
+		}
+
+		upload, err := h.load(id)
+		if err == nil && now.After(upload.ExpiresAt) {
+			h.remove(id)
+		}
+		unlock()
+	}
+}
+
+func parseMetadata(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(pair, " ")
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = string(b)
+	}
+	return m, nil
+}
+
+func formatMetadata(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+	return strings.Join(pairs, ",")
+}
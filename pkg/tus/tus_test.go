@@ -0,0 +1,366 @@
+package tus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testServer struct {
+	t       *testing.T
+	handler *Handler
+	engine  *gin.Engine
+
+	mu        sync.Mutex
+	finalized map[string]string // Upload ID -> content
+}
+
+func newTestServer(t *testing.T, config Config) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	s := &testServer{t: t, finalized: make(map[string]string)}
+	config.Dir = t.TempDir()
+	config.Finalize = func(ctx context.Context, upload *Upload, reader io.ReadSeeker) (string, error) {
+		b, err := io.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.finalized[upload.ID] = string(b)
+		return "file-" + upload.ID, nil
+	}
+	config.Owner = func(c *gin.Context) string {
+		return c.GetHeader("X-Owner")
+	}
+
+	h, err := NewHandler(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Release)
+
+	s.handler = h
+	s.engine = gin.New()
+	h.Register(s.engine.Group("/files"))
+	return s
+}
+
+func (s *testServer) do(method, target string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", Version)
+	for k, v := range headers {
+		if v == "" {
+			req.Header.Del(k)
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, req)
+	return w
+}
+
+func (s *testServer) create(size string, headers map[string]string) string {
+	s.t.Helper()
+
+	h := map[string]string{"Upload-Length": size}
+	for k, v := range headers {
+		h[k] = v
+	}
+	w := s.do(http.MethodPost, "/files", h, "")
+	if w.Code != http.StatusCreated {
+		s.t.Fatalf("create status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	return path.Base(w.Header().Get("Location"))
+}
+
+func (s *testServer) patch(id, offset, body string, headers map[string]string) *httptest.ResponseRecorder {
+	h := map[string]string{
+		"Content-Type":  offsetContentType,
+		"Upload-Offset": offset,
+	}
+	for k, v := range headers {
+		h[k] = v
+	}
+	return s.do(http.MethodPatch, "/files/"+id, h, body)
+}
+
+func TestUpload(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	id := s.create("11", map[string]string{"Upload-Metadata": "filename aGVsbG8udHh0"})
+
+	w := s.patch(id, "0", "hello ", nil)
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("patch = %d, offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	w = s.do(http.MethodHead, "/files/"+id, nil, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("head status = %d", w.Code)
+	}
+	if got := w.Header().Get("Upload-Offset"); got != "6" {
+		t.Fatalf("Upload-Offset = %q, want 6", got)
+	}
+	if got := w.Header().Get("Upload-Length"); got != "11" {
+		t.Fatalf("Upload-Length = %q, want 11", got)
+	}
+	if got := w.Header().Get("Upload-Metadata"); got != "filename aGVsbG8udHh0" {
+		t.Fatalf("Upload-Metadata = %q", got)
+	}
+
+	// Bytes beyond the declared length are ignored.
+	w = s.patch(id, "6", "world!!!", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("patch status = %d", w.Code)
+	}
+	if got := w.Header().Get("Upload-Result"); got != "file-"+id {
+		t.Fatalf("Upload-Result = %q", got)
+	}
+	if got := s.finalized[id]; got != "hello world" {
+		t.Fatalf("finalized content = %q, want %q", got, "hello world")
+	}
+
+	w = s.do(http.MethodHead, "/files/"+id, nil, "")
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Result") != "file-"+id {
+		t.Fatalf("head after completion = %d, result %q", w.Code, w.Header().Get("Upload-Result"))
+	}
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "created",
+			headers:    map[string]string{"Upload-Length": "10"},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "missing version",
+			headers:    map[string]string{"Upload-Length": "10", "Tus-Resumable": ""},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "missing length",
+			headers:    map[string]string{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative length",
+			headers:    map[string]string{"Upload-Length": "-1"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too large",
+			headers:    map[string]string{"Upload-Length": "101"},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "invalid metadata",
+			headers:    map[string]string{"Upload-Length": "10", "Upload-Metadata": "filename !!"},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, Config{MaxSize: 100})
+			w := s.do(http.MethodPost, "/files", tt.headers, "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCreateEmpty(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	w := s.do(http.MethodPost, "/files", map[string]string{"Upload-Length": "0"}, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d", w.Code)
+	}
+	id := path.Base(w.Header().Get("Location"))
+	if got := w.Header().Get("Upload-Result"); got != "file-"+id {
+		t.Fatalf("Upload-Result = %q", got)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string // Created upload if empty
+		offset     string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "offset mismatch",
+			offset:     "3",
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "invalid content type",
+			offset:     "0",
+			headers:    map[string]string{"Content-Type": "application/octet-stream"},
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "other owner",
+			offset:     "0",
+			headers:    map[string]string{"X-Owner": "bob"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown upload",
+			id:         "cn7ujv6k3kfl3pqdhmbg",
+			offset:     "0",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid id",
+			id:         "..%2Fsecret",
+			offset:     "0",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, Config{})
+			id := tt.id
+			if id == "" {
+				id = s.create("10", map[string]string{"X-Owner": "alice"})
+			}
+
+			headers := map[string]string{"X-Owner": "alice"}
+			for k, v := range tt.headers {
+				headers[k] = v
+			}
+			w := s.patch(id, tt.offset, "data", headers)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestPatchLocked(t *testing.T) {
+	s := newTestServer(t, Config{})
+	id := s.create("10", nil)
+
+	unlock, err := s.handler.lock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := s.patch(id, "0", "data", nil); w.Code != http.StatusLocked {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusLocked)
+	}
+	unlock()
+
+	if w := s.patch(id, "0", "data", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("status after unlock = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestLocksOnlyKeptForExistingUploads(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	for _, id := range []string{"cn7ujv6k3kfl3pqdhmbg", "not-an-id", "cn7ujv6k3kfl3pqdhmc0"} {
+		s.patch(id, "0", "data", nil)
+		s.do(http.MethodDelete, "/files/"+id, nil, "")
+	}
+
+	id := s.create("10", nil)
+	s.patch(id, "0", "data", nil)
+	if w := s.do(http.MethodDelete, "/files/"+id, nil, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("terminate status = %d", w.Code)
+	}
+	if w := s.do(http.MethodHead, "/files/"+id, nil, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("head after terminate = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	n := 0
+	s.handler.locks.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if n != 0 {
+		t.Fatalf("%d locks left, want 0", n)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	s := newTestServer(t, Config{Expiration: 20 * time.Millisecond})
+	expired := s.create("10", nil)
+	completed := s.create("0", nil)
+	time.Sleep(40 * time.Millisecond)
+
+	if w := s.patch(expired, "0", "data", nil); w.Code != http.StatusGone {
+		t.Fatalf("patch expired status = %d, want %d", w.Code, http.StatusGone)
+	}
+	if w := s.do(http.MethodHead, "/files/"+expired, nil, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("head expired status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	// Completed uploads are kept until the cleanup removes them.
+	if w := s.do(http.MethodHead, "/files/"+completed, nil, ""); w.Code != http.StatusOK {
+		t.Fatalf("head completed status = %d, want %d", w.Code, http.StatusOK)
+	}
+	s.handler.cleanup()
+	if w := s.do(http.MethodHead, "/files/"+completed, nil, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("head after cleanup = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", header: "", want: map[string]string{}},
+		{name: "pairs", header: "filename aGVsbG8udHh0, type dGV4dC9wbGFpbg==", want: map[string]string{"filename": "hello.txt", "type": "text/plain"}},
+		{name: "key only", header: "private", want: map[string]string{"private": ""}},
+		{name: "invalid base64", header: "filename !!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMetadata(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+
+			back, err := parseMetadata(formatMetadata(got))
+			if err != nil || len(back) != len(got) {
+				t.Fatalf("round trip = %v, %v", back, err)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package util
 
 import (
 	"context"
-	"gin-admin/pkg/encoding/json"
 
 	"gorm.io/gorm"
 )
@@ -83,19 +82,6 @@ type UserCache struct {
 	RoleIDs []string
 }
 
-func ParseUserCache(s string) UserCache {
-	var a UserCache
-	if s == "" {
-		return a
-	}
-	_ = json.Unmarshal([]byte(s), &a)
-	return a
-}
-
-func (a UserCache) String() string {
-	return json.MarshalToString(a)
-}
-
 func NewUserCache(ctx context.Context, userCache UserCache) context.Context {
 	return context.WithValue(ctx, userCacheCtx{}, userCache)
 }
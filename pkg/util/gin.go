@@ -1,6 +1,8 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"gin-admin/pkg/encoding/json"
 	"gin-admin/pkg/errors"
@@ -67,10 +69,37 @@ func ResJSON(c *gin.Context, status int, v interface{}) {
 	}
 
 	c.Set(ResBodyKey, buf)
+	if status == http.StatusOK && c.GetBool(ETagKey) && notModified(c, buf) {
+		c.Status(http.StatusNotModified)
+		c.Abort()
+		return
+	}
 	c.Data(status, "application/json; charset=utf-8", buf)
 	c.Abort()
 }
 
+// notModified sets the ETag of a GET response and reports whether the client already has it.
+func notModified(c *gin.Context, buf []byte) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	sum := sha256.Sum256(buf)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if c.Writer.Header().Get("Cache-Control") == "" {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+
+	for _, v := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 func ResSuccess(c *gin.Context, v interface{}) {
 	ResJSON(c, 200, ResponseResult{
 		Success: true,
@@ -86,8 +115,10 @@ func ResOk(c *gin.Context) {
 
 func ResPage(c *gin.Context, v interface{}, pr *PaginationResult) {
 	var total int64
+	var truncated bool
 	if pr != nil {
 		total = pr.Total
+		truncated = pr.Truncated
 	}
 
 	reflectValue := reflect.Indirect(reflect.ValueOf(v))
@@ -96,9 +127,10 @@ func ResPage(c *gin.Context, v interface{}, pr *PaginationResult) {
 	}
 
 	ResJSON(c, http.StatusOK, ResponseResult{
-		Success: true,
-		Data:    v,
-		Total:   total,
+		Success:   true,
+		Data:      v,
+		Total:     total,
+		Truncated: truncated,
 	})
 }
 
@@ -107,7 +139,7 @@ func ResError(c *gin.Context, err error, status ...int) {
 	if e, ok := errors.As(err); ok {
 		ierr = e
 	} else {
-		ierr = errors.FromError(errors.InternalServiceError("", err.Error()))
+		ierr = errors.FromError(errors.InternalServiceError("", "%s", err.Error()))
 	}
 	code := int(ierr.Code)
 	if len(status) > 0 {
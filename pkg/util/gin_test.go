@@ -0,0 +1,62 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNotModified(t *testing.T) {
+	body := []byte(`{"success":true}`)
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	tests := []struct {
+		name         string
+		method       string
+		ifNoneMatch  string
+		cacheControl string // Set by the handler before the response
+		want         bool
+		wantETag     bool
+		wantCache    string
+	}{
+		{"no validator", http.MethodGet, "", "", false, true, "private, no-cache"},
+		{"matching etag", http.MethodGet, etag, "", true, true, "private, no-cache"},
+		{"weak etag matches", http.MethodGet, "W/" + etag, "", true, true, "private, no-cache"},
+		{"one of several", http.MethodGet, `"other", ` + etag, "", true, true, "private, no-cache"},
+		{"wildcard", http.MethodGet, "*", "", true, true, "private, no-cache"},
+		{"other etag", http.MethodGet, `"other"`, "", false, true, "private, no-cache"},
+		{"head request", http.MethodHead, etag, "", true, true, "private, no-cache"},
+		{"handler cache control is kept", http.MethodGet, "", "no-store", false, true, "no-store"},
+		{"not a read", http.MethodPost, etag, "", false, false, ""},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(tt.method, "/", nil)
+			if tt.ifNoneMatch != "" {
+				c.Request.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.cacheControl != "" {
+				c.Header("Cache-Control", tt.cacheControl)
+			}
+
+			if got := notModified(c, body); got != tt.want {
+				t.Fatalf("notModified() = %v, want %v", got, tt.want)
+			}
+			if got := w.Header().Get("ETag"); (got == etag) != tt.wantETag {
+				t.Fatalf("ETag %q", got)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Fatalf("Cache-Control %q, want %q", got, tt.wantCache)
+			}
+		})
+	}
+}
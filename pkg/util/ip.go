@@ -0,0 +1,49 @@
+package util
+
+import (
+	"gin-admin/pkg/errors"
+	"net/netip"
+	"strings"
+)
+
+// ParseIPPrefixes parses a list of CIDRs separated by commas or whitespace. A plain address is taken as
+// a prefix holding only that address, IPv4 addresses mapped into IPv6 are unmapped.
+func ParseIPPrefixes(s string) ([]netip.Prefix, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+
+	prefixes := make([]netip.Prefix, 0, len(fields))
+	for _, field := range fields {
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, errors.Errorf("invalid IP address %q", field)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, errors.Errorf("invalid CIDR %q", field)
+		}
+		if addr := prefix.Addr(); addr.Is4In6() {
+			prefix = netip.PrefixFrom(addr.Unmap(), max(prefix.Bits()-96, 0))
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ContainsIP reports whether any of the prefixes contains the address.
+func ContainsIP(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
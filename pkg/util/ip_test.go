@@ -0,0 +1,65 @@
+package util
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestParseIPPrefixes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{}, false},
+		{"10.0.0.1", []string{"10.0.0.1/32"}, false},
+		{"2001:db8::1", []string{"2001:db8::1/128"}, false},
+		{"10.1.2.3/16", []string{"10.1.0.0/16"}, false},
+		{"2001:db8::1/32", []string{"2001:db8::/32"}, false},
+		{"::ffff:10.0.0.1", []string{"10.0.0.1/32"}, false},
+		{"::ffff:10.1.2.3/112", []string{"10.1.0.0/16"}, false},
+		{"::ffff:0.0.0.0/64", []string{"0.0.0.0/0"}, false},
+		{"10.0.0.1, 10.0.0.2\n10.0.0.3\t10.0.0.4,,", []string{"10.0.0.1/32", "10.0.0.2/32", "10.0.0.3/32", "10.0.0.4/32"}, false},
+		{"10.0.0.256", nil, true},
+		{"10.0.0.0/33", nil, true},
+		{"localhost", nil, true},
+		{"10.0.0.1, bad", nil, true},
+	}
+
+	for _, tt := range tests {
+		prefixes, err := ParseIPPrefixes(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIPPrefixes(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		got := make([]string, 0, len(prefixes))
+		for _, p := range prefixes {
+			got = append(got, p.String())
+		}
+		if !tt.wantErr && !slices.Equal(got, tt.want) {
+			t.Errorf("ParseIPPrefixes(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestContainsIP(t *testing.T) {
+	prefixes, _ := ParseIPPrefixes("10.0.0.0/8, 2001:db8::/32")
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	}
+
+	for _, tt := range tests {
+		if got := ContainsIP(prefixes, netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("ContainsIP(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
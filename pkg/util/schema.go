@@ -4,21 +4,24 @@ import "gin-admin/pkg/errors"
 
 const (
 	ReqVBodyKey       = "req-body"
-	ResBodyKey        = "req-body"
+	ResBodyKey        = "res-body"
+	ETagKey           = "etag" // Set by the ETag middleware to let ResJSON answer conditional GETs
 	TreePathDelimiter = "."
 )
 
 type ResponseResult struct {
-	Success bool
-	Data    interface{}
-	Total   int64
-	Error   *errors.Error
+	Success   bool
+	Data      interface{}
+	Total     int64
+	Truncated bool `json:",omitempty"` // Total is a lower bound, counting stopped at a limit
+	Error     *errors.Error
 }
 
 type PaginationResult struct {
-	Total    int64
-	Current  int
-	PageSize int
+	Total     int64
+	Truncated bool // Total is a lower bound, counting stopped at a limit
+	Current   int
+	PageSize  int
 }
 
 type PaginationParam struct {